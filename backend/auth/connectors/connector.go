@@ -0,0 +1,59 @@
+// Package connectors implements OAuth2/OIDC social login, following the
+// same "pluggable connector" shape dex uses: each identity provider is a
+// small Connector behind a common interface, registered by name so
+// handlers.LoginOAuth/OAuthCallback don't need a provider-specific branch.
+package connectors
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/holycan/smart-parking-system/config"
+)
+
+// UserInfo is the subset of the provider's profile handlers.OAuthCallback
+// needs to create-or-link a local account.
+type UserInfo struct {
+	// Sub is the provider's stable, opaque subject identifier for this
+	// user (Google's "sub", GitHub's numeric user id as a string).
+	Sub   string
+	Email string
+	// EmailVerified gates whether the email can be trusted to link an
+	// existing local account without further proof of ownership.
+	EmailVerified bool
+	Name          string
+}
+
+// Connector is one OAuth2/OIDC identity provider.
+type Connector interface {
+	// LoginURL returns the provider's consent-screen URL, embedding state
+	// so the callback can be matched back to the request that started it,
+	// and a PKCE challenge derived from codeVerifier so the authorization
+	// code can't be redeemed by anyone but the party that started this flow.
+	LoginURL(state, codeVerifier string) string
+	// HandleCallback exchanges an authorization code for the authenticated
+	// user's profile. codeVerifier must be the same value passed to the
+	// LoginURL call that produced code, or the exchange fails.
+	HandleCallback(ctx context.Context, code, codeVerifier string) (UserInfo, error)
+}
+
+var registry = map[string]func(config.OAuthProviderConfig) Connector{
+	"google": func(cfg config.OAuthProviderConfig) Connector { return newGoogleConnector(cfg) },
+	"github": func(cfg config.OAuthProviderConfig) Connector { return newGitHubConnector(cfg) },
+}
+
+// Get builds the Connector for provider using cfg, or an error if provider
+// isn't one this package supports.
+func Get(provider string, cfg config.OAuthConfig) (Connector, error) {
+	providerCfg, ok := cfg.Providers()[provider]
+	if !ok {
+		return nil, fmt.Errorf("connectors: unknown provider %q", provider)
+	}
+
+	newConnector, ok := registry[provider]
+	if !ok {
+		return nil, fmt.Errorf("connectors: unknown provider %q", provider)
+	}
+
+	return newConnector(providerCfg), nil
+}
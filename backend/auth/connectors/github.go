@@ -0,0 +1,123 @@
+package connectors
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/github"
+
+	"github.com/holycan/smart-parking-system/config"
+)
+
+var defaultGitHubScopes = []string{"read:user", "user:email"}
+
+type githubConnector struct {
+	oauthConfig *oauth2.Config
+}
+
+func newGitHubConnector(cfg config.OAuthProviderConfig) *githubConnector {
+	scopes := cfg.Scopes
+	if len(scopes) == 0 {
+		scopes = defaultGitHubScopes
+	}
+	return &githubConnector{
+		oauthConfig: &oauth2.Config{
+			ClientID:     cfg.ClientID,
+			ClientSecret: cfg.ClientSecret,
+			RedirectURL:  cfg.RedirectURL,
+			Scopes:       scopes,
+			Endpoint:     github.Endpoint,
+		},
+	}
+}
+
+func (g *githubConnector) LoginURL(state, codeVerifier string) string {
+	return g.oauthConfig.AuthCodeURL(state, oauth2.S256ChallengeOption(codeVerifier))
+}
+
+type githubUserResponse struct {
+	ID    int64  `json:"id"`
+	Login string `json:"login"`
+	Name  string `json:"name"`
+	Email string `json:"email"`
+}
+
+type githubEmailResponse struct {
+	Email    string `json:"email"`
+	Primary  bool   `json:"primary"`
+	Verified bool   `json:"verified"`
+}
+
+// HandleCallback exchanges code for a token, then makes two GitHub API
+// calls: /user for the profile (whose email is often null for users who
+// keep it private), and /user/emails to find their verified primary
+// address.
+func (g *githubConnector) HandleCallback(ctx context.Context, code, codeVerifier string) (UserInfo, error) {
+	token, err := g.oauthConfig.Exchange(ctx, code, oauth2.VerifierOption(codeVerifier))
+	if err != nil {
+		return UserInfo{}, fmt.Errorf("connectors: github token exchange failed: %w", err)
+	}
+
+	client := g.oauthConfig.Client(ctx, token)
+
+	var user githubUserResponse
+	if err := getJSON(client, "https://api.github.com/user", &user); err != nil {
+		return UserInfo{}, fmt.Errorf("connectors: github user request failed: %w", err)
+	}
+
+	email, verified, err := g.primaryEmail(client, user.Email)
+	if err != nil {
+		return UserInfo{}, err
+	}
+
+	return UserInfo{
+		Sub:           strconv.FormatInt(user.ID, 10),
+		Email:         email,
+		EmailVerified: verified,
+		Name:          user.Name,
+	}, nil
+}
+
+// primaryEmail returns profileEmail as-is if set (GitHub doesn't expose
+// its verification status directly), otherwise looks up the verified
+// primary address from /user/emails.
+func (g *githubConnector) primaryEmail(client *http.Client, profileEmail string) (email string, verified bool, err error) {
+	var emails []githubEmailResponse
+	if err := getJSON(client, "https://api.github.com/user/emails", &emails); err != nil {
+		if profileEmail != "" {
+			return profileEmail, false, nil
+		}
+		return "", false, fmt.Errorf("connectors: github emails request failed: %w", err)
+	}
+
+	for _, e := range emails {
+		if e.Primary {
+			return e.Email, e.Verified, nil
+		}
+	}
+
+	if profileEmail != "" {
+		return profileEmail, false, nil
+	}
+	return "", false, fmt.Errorf("connectors: github account has no accessible email")
+}
+
+func getJSON(client *http.Client, url string, out interface{}) error {
+	resp, err := client.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("unexpected status %d: %s", resp.StatusCode, body)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(out)
+}
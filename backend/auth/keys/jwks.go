@@ -0,0 +1,56 @@
+package keys
+
+import (
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"encoding/base64"
+	"math/big"
+)
+
+// JWK is one entry of a JSON Web Key Set, holding only the fields this
+// package ever populates (RSA's n/e or EC's crv/x/y - never both on the
+// same key).
+type JWK struct {
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Kid string `json:"kid,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+// JWKS is a JSON Web Key Set as served from /.well-known/jwks.json.
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS returns ks's public verification key as a JWKS, or ok=false for
+// HS256, since a shared HMAC secret has no public component to publish.
+func (ks *KeySet) JWKS() (set JWKS, ok bool) {
+	switch pub := ks.VerifyKey.(type) {
+	case *rsa.PublicKey:
+		return JWKS{Keys: []JWK{{
+			Kty: "RSA",
+			Use: "sig",
+			Alg: ks.Algorithm,
+			Kid: ks.KeyID,
+			N:   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+		}}}, true
+	case *ecdsa.PublicKey:
+		return JWKS{Keys: []JWK{{
+			Kty: "EC",
+			Use: "sig",
+			Alg: ks.Algorithm,
+			Kid: ks.KeyID,
+			Crv: pub.Curve.Params().Name,
+			X:   base64.RawURLEncoding.EncodeToString(pub.X.Bytes()),
+			Y:   base64.RawURLEncoding.EncodeToString(pub.Y.Bytes()),
+		}}}, true
+	default:
+		return JWKS{}, false
+	}
+}
@@ -0,0 +1,152 @@
+// Package keys resolves config.JWTConfig into the signing and verification
+// material middleware.GenerateToken, middleware.AuthMiddleware, and
+// middleware.AuthWebSocketMiddleware use, and exposes the public half of an
+// asymmetric key as a JWKS (handlers.JWKS) for external verifiers - an ANPR
+// camera gateway or a mobile client - that need to check a token's
+// signature without holding the key that signed it. Following the same
+// Current/Init singleton pattern as tracing.Current and routing.Current.
+package keys
+
+import (
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/holycan/smart-parking-system/config"
+)
+
+// KeySet is the resolved signing/verification material for one
+// config.JWTConfig. SigningKey and VerifyKey are the same []byte for
+// HS256, and a private/public key pair for RS256 and ES256.
+type KeySet struct {
+	Algorithm  string
+	KeyID      string
+	SigningKey interface{}
+	VerifyKey  interface{}
+}
+
+// Current is the process-wide KeySet GenerateToken and the auth
+// middlewares sign/verify with. Set by Init; nil until then.
+var Current *KeySet
+
+var mu sync.RWMutex
+
+// Init resolves cfg into a KeySet and installs it as Current. Call once at
+// startup; config.validate already rejects a cfg that Init would fail on,
+// so the error return is for completeness rather than an expected path.
+func Init(cfg config.JWTConfig) error {
+	ks, err := Load(cfg)
+	if err != nil {
+		return err
+	}
+	mu.Lock()
+	Current = ks
+	mu.Unlock()
+	return nil
+}
+
+// Load resolves cfg into a KeySet without touching Current, so callers
+// (and tests) can validate a config before swapping it in.
+func Load(cfg config.JWTConfig) (*KeySet, error) {
+	switch cfg.Algorithm {
+	case "", "HS256":
+		return &KeySet{
+			Algorithm:  "HS256",
+			KeyID:      cfg.KeyID,
+			SigningKey: []byte(cfg.Secret),
+			VerifyKey:  []byte(cfg.Secret),
+		}, nil
+	case "RS256":
+		priv, err := loadRSAPrivateKey(cfg.PrivateKeyPath)
+		if err != nil {
+			return nil, err
+		}
+		return &KeySet{Algorithm: "RS256", KeyID: cfg.KeyID, SigningKey: priv, VerifyKey: &priv.PublicKey}, nil
+	case "ES256":
+		priv, err := loadECPrivateKey(cfg.PrivateKeyPath)
+		if err != nil {
+			return nil, err
+		}
+		return &KeySet{Algorithm: "ES256", KeyID: cfg.KeyID, SigningKey: priv, VerifyKey: &priv.PublicKey}, nil
+	default:
+		return nil, fmt.Errorf("keys: unsupported JWT algorithm %q", cfg.Algorithm)
+	}
+}
+
+// SigningMethod returns the golang-jwt SigningMethod matching ks.Algorithm.
+func (ks *KeySet) SigningMethod() jwt.SigningMethod {
+	switch ks.Algorithm {
+	case "RS256":
+		return jwt.SigningMethodRS256
+	case "ES256":
+		return jwt.SigningMethodES256
+	default:
+		return jwt.SigningMethodHS256
+	}
+}
+
+// Verify checks that token was signed with the algorithm ks expects -
+// guarding against an attacker-supplied "alg" choosing a weaker method -
+// and returns the key ParseWithClaims should verify it against.
+func (ks *KeySet) Verify(token *jwt.Token) (interface{}, error) {
+	if token.Method.Alg() != ks.Algorithm {
+		return nil, fmt.Errorf("keys: unexpected signing method %q", token.Method.Alg())
+	}
+	return ks.VerifyKey, nil
+}
+
+func loadRSAPrivateKey(path string) (*rsa.PrivateKey, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("keys: failed to parse RSA private key %s: %w", path, err)
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("keys: %s does not contain an RSA private key", path)
+	}
+	return key, nil
+}
+
+func loadECPrivateKey(path string) (*ecdsa.PrivateKey, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+	if key, err := x509.ParseECPrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("keys: failed to parse EC private key %s: %w", path, err)
+	}
+	key, ok := parsed.(*ecdsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("keys: %s does not contain an EC private key", path)
+	}
+	return key, nil
+}
+
+func readPEM(path string) (*pem.Block, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("keys: failed to read %s: %w", path, err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("keys: %s is not a valid PEM file", path)
+	}
+	return block, nil
+}
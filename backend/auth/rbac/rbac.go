@@ -0,0 +1,160 @@
+// Package rbac resolves a user's assigned roles to a deduped, sorted set
+// of permissions, and manages role grants/revocations, backing the
+// RequirePermission middleware and the admin role-management endpoints.
+package rbac
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/holycan/smart-parking-system/database"
+)
+
+// Resolve returns the role names assigned to userID and the deduped,
+// sorted set of permissions those roles grant, for embedding in the
+// access token at login time.
+func Resolve(userID string) (roles []string, perms []string, err error) {
+	roles, err = ListUserRoles(userID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	rows, err := database.App.Query(
+		`SELECT DISTINCT p.name FROM permissions p
+		 JOIN role_permissions rp ON rp.permission_id = p.id
+		 JOIN user_roles ur ON ur.role_id = rp.role_id
+		 WHERE ur.user_id = $1`,
+		userID,
+	)
+	if err != nil {
+		return nil, nil, fmt.Errorf("rbac: failed to resolve permissions: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, nil, err
+		}
+		perms = append(perms, name)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	sort.Strings(perms)
+	return roles, perms, nil
+}
+
+// ListUserRoles returns the role names currently assigned to userID.
+func ListUserRoles(userID string) ([]string, error) {
+	rows, err := database.App.Query(
+		"SELECT r.name FROM roles r JOIN user_roles ur ON ur.role_id = r.id WHERE ur.user_id = $1",
+		userID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("rbac: failed to list roles: %w", err)
+	}
+	defer rows.Close()
+
+	var roles []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		roles = append(roles, name)
+	}
+	return roles, rows.Err()
+}
+
+// GrantRole assigns roleName to targetUserID and records actorUserID as
+// the grantor in role_audit_log. It's a no-op (no audit entry written) if
+// targetUserID already holds the role.
+func GrantRole(actorUserID, targetUserID, roleName string) error {
+	roleID, err := roleIDByName(roleName)
+	if err != nil {
+		return err
+	}
+
+	result, err := database.App.Exec(
+		"INSERT INTO user_roles (user_id, role_id) VALUES ($1, $2) ON CONFLICT DO NOTHING",
+		targetUserID, roleID,
+	)
+	if err != nil {
+		return fmt.Errorf("rbac: failed to grant role %q: %w", roleName, err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return nil
+	}
+
+	return auditRoleChange(actorUserID, targetUserID, roleName, "grant")
+}
+
+// RevokeRole removes roleName from targetUserID and records actorUserID as
+// the revoker in role_audit_log. It's a no-op (no audit entry written) if
+// targetUserID didn't hold the role.
+func RevokeRole(actorUserID, targetUserID, roleName string) error {
+	roleID, err := roleIDByName(roleName)
+	if err != nil {
+		return err
+	}
+
+	result, err := database.App.Exec(
+		"DELETE FROM user_roles WHERE user_id = $1 AND role_id = $2",
+		targetUserID, roleID,
+	)
+	if err != nil {
+		return fmt.Errorf("rbac: failed to revoke role %q: %w", roleName, err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return nil
+	}
+
+	return auditRoleChange(actorUserID, targetUserID, roleName, "revoke")
+}
+
+// EnsureDefaultRole assigns the base "user" role to userID. It's called
+// at signup time and isn't routed through GrantRole/role_audit_log since
+// it's automatic provisioning, not an administrative action.
+func EnsureDefaultRole(userID string) error {
+	roleID, err := roleIDByName("user")
+	if err != nil {
+		return err
+	}
+
+	if _, err := database.App.Exec(
+		"INSERT INTO user_roles (user_id, role_id) VALUES ($1, $2) ON CONFLICT DO NOTHING",
+		userID, roleID,
+	); err != nil {
+		return fmt.Errorf("rbac: failed to assign default role: %w", err)
+	}
+	return nil
+}
+
+func roleIDByName(roleName string) (string, error) {
+	var roleID string
+	err := database.App.QueryRow("SELECT id FROM roles WHERE name = $1", roleName).Scan(&roleID)
+	if err == sql.ErrNoRows {
+		return "", fmt.Errorf("rbac: unknown role %q", roleName)
+	}
+	if err != nil {
+		return "", fmt.Errorf("rbac: failed to look up role %q: %w", roleName, err)
+	}
+	return roleID, nil
+}
+
+func auditRoleChange(actorUserID, targetUserID, roleName, action string) error {
+	_, err := database.App.Exec(
+		"INSERT INTO role_audit_log (id, actor_user_id, target_user_id, role_name, action, created_at) VALUES ($1, $2, $3, $4, $5, $6)",
+		uuid.New().String(), actorUserID, targetUserID, roleName, action, time.Now(),
+	)
+	if err != nil {
+		return fmt.Errorf("rbac: failed to write audit log: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,1163 @@
+// Package config centralizes application configuration behind a typed
+// Config struct instead of the os.Getenv calls previously scattered across
+// database, lock, and middleware. Load resolves settings in increasing
+// order of precedence: built-in defaults, an optional config.yaml file,
+// environment variables, then CLI flags.
+package config
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/joho/godotenv"
+)
+
+// DBConfig holds everything database.Initialize needs to open a connection.
+type DBConfig struct {
+	Driver     string
+	Host       string
+	Port       string
+	User       string
+	Password   string
+	Name       string
+	SSLMode    string
+	SQLitePath string
+}
+
+// HTTPConfig holds the settings main.go's startServer and setupRouter need.
+type HTTPConfig struct {
+	Port        string
+	TLSCertFile string
+	TLSKeyFile  string
+	// TrustedProxies is the set of IPs/CIDRs gin.Engine.SetTrustedProxies
+	// trusts to set X-Forwarded-For, so c.ClientIP() resolves to the real
+	// client behind a load balancer instead of the proxy's own address.
+	// Empty disables proxy header trust entirely (gin falls back to
+	// r.RemoteAddr), which is the safe default outside of a known
+	// deployment topology.
+	TrustedProxies []string
+	// TrustedPlatform tells gin to trust a specific platform header
+	// (gin.PlatformGoogleAppEngine, gin.PlatformCloudflare, ...) for
+	// ClientIP resolution instead of walking X-Forwarded-For. Empty uses
+	// the normal TrustedProxies-based resolution.
+	TrustedPlatform string
+	// ShutdownTimeoutSeconds bounds how long gracefulShutdown and
+	// runWorkerCommand wait for in-flight work (HTTP requests, queue
+	// consumers) to finish before giving up and closing the database
+	// connection out from under them anyway.
+	ShutdownTimeoutSeconds int
+}
+
+// JWTConfig holds the signing material auth/keys.Init resolves into a
+// keys.KeySet for middleware.AuthMiddleware, middleware.AuthWebSocketMiddleware,
+// and middleware.GenerateToken.
+type JWTConfig struct {
+	// Secret is the HMAC key used when Algorithm is "HS256" (the default).
+	Secret string
+	// Algorithm is "HS256", "RS256", or "ES256". Asymmetric algorithms let
+	// a service verify tokens from the JWKS at /.well-known/jwks.json
+	// without holding the signing key itself - useful for something like
+	// an ANPR camera gateway that only needs to check a token, never issue
+	// one.
+	Algorithm string
+	// PrivateKeyPath is a PEM-encoded RSA or EC private key file, required
+	// when Algorithm is "RS256"/"ES256". The public key used for
+	// verification and the JWKS is derived from it.
+	PrivateKeyPath string
+	// KeyID tags every token this instance signs (the JWT "kid" header) and
+	// the JWKS entry for its public key, so a verifier can tell which key a
+	// token was signed with while a rotation is in progress.
+	KeyID string
+}
+
+// RedisConfig holds the address lock.InitializeRedisLock and the
+// idempotency middleware both need for their Redis client.
+type RedisConfig struct {
+	Addr string
+}
+
+// PaymentsConfig is a stub: no payment provider is integrated yet, but the
+// field exists so the billing work coming later has a typed home instead
+// of another ad-hoc os.Getenv call.
+type PaymentsConfig struct {
+	ProviderAPIKey string
+}
+
+// RoutingConfig holds the settings routing.NewValhallaClient needs to turn
+// an origin/destination pair into driving directions.
+type RoutingConfig struct {
+	// Enabled controls whether handlers.ProcessCheckIn attempts to resolve
+	// a route at all. Off by default since it depends on a Valhalla
+	// instance being reachable at BaseURL.
+	Enabled bool
+	// BaseURL is the Valhalla server's address, e.g. "http://localhost:8002".
+	BaseURL string
+	// TimeoutSeconds bounds how long a single /route call is allowed to
+	// take before ProcessCheckIn gives up and proceeds without a route.
+	TimeoutSeconds int
+}
+
+// NotificationsConfig holds the SMTP settings internal/email.NewSender
+// uses to deliver password-reset, verification, MFA-code, and booking
+// emails.
+type NotificationsConfig struct {
+	SMTPHost     string
+	SMTPPort     string
+	SMTPUser     string
+	SMTPPassword string
+	// SMTPFrom is the From: address on outgoing mail.
+	SMTPFrom string
+	// SMTPTLSMode is one of "starttls" (default; smtp.SendMail negotiates
+	// STARTTLS if the server offers it), "tls" (connect over implicit TLS
+	// from the start), or "none" (plaintext, for local dev mail catchers).
+	SMTPTLSMode string
+}
+
+// OAuthProviderConfig holds one social-login provider's OAuth2 app
+// credentials, as registered in that provider's developer console.
+type OAuthProviderConfig struct {
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	// Scopes defaults to the provider's minimal "identify me" scope if
+	// empty - see connectors.newGoogleConnector/newGitHubConnector.
+	Scopes []string
+}
+
+// OAuthConfig holds the per-provider settings auth/connectors.Get uses to
+// build a Connector for GET /api/auth/oauth/:provider/login and /callback.
+type OAuthConfig struct {
+	Google OAuthProviderConfig
+	GitHub OAuthProviderConfig
+}
+
+// Providers returns cfg's providers keyed by the same provider name used
+// in the :provider route parameter.
+func (cfg OAuthConfig) Providers() map[string]OAuthProviderConfig {
+	return map[string]OAuthProviderConfig{
+		"google": cfg.Google,
+		"github": cfg.GitHub,
+	}
+}
+
+// MFAConfig holds the key internal/totp uses to encrypt stored TOTP
+// secrets at rest.
+type MFAConfig struct {
+	// EncryptionKey is a base64 or raw 32-byte AES-256 key. Required in
+	// any non-development environment since MFA secrets must never be
+	// stored in plaintext outside local dev.
+	EncryptionKey string
+	// TOTPSkewSteps is how many 30-second steps before/after the current
+	// one still validate, to tolerate clock drift between server and
+	// authenticator app.
+	TOTPSkewSteps int
+}
+
+// AuthRateLimitConfig holds the per-endpoint request thresholds
+// middleware.AuthRateLimit enforces to keep auth endpoints from being
+// brute-forced or used to enumerate registered emails.
+type AuthRateLimitConfig struct {
+	LoginAttempts              int
+	LoginWindowSeconds         int
+	RegisterAttempts           int
+	RegisterWindowSeconds      int
+	PasswordResetAttempts      int
+	PasswordResetWindowSeconds int
+	MFAAttempts                int
+	MFAWindowSeconds           int
+}
+
+// RateLimitConfig selects the middleware.Limiter implementation
+// routes.RegisterRoutes' general API limiter and ws.Client.readPump's
+// per-connection frame budget build on top of.
+type RateLimitConfig struct {
+	// Backend is "memory" (default) for the in-process token bucket, fine
+	// for a single-node dev setup, or "redis" to share buckets across
+	// replicas via middleware.RedisRateLimiter - required once the API is
+	// scaled horizontally, since otherwise a client hitting N pods gets
+	// N times the intended rate.
+	Backend string
+
+	// RequestsPerSecond/Burst bound the general API limiter applied to
+	// every request in routes.RegisterRoutes.
+	RequestsPerSecond int
+	Burst             int
+
+	// WSFramesPerSecond/WSFrameBurst bound inbound WebSocket control
+	// frames per connection, dropping a client that exceeds it with a
+	// 1008 policy-violation close - see ws.Client.readPump.
+	WSFramesPerSecond int
+	WSFrameBurst      int
+}
+
+// LockoutConfig holds the progressive account-lockout thresholds
+// middleware.RecordFailedLogin applies on top of AuthRateLimit once an
+// individual account, not just a source IP, looks like it's under attack.
+type LockoutConfig struct {
+	// Threshold is how many consecutive failed logins trigger a lockout.
+	Threshold int
+	// BaseBackoffSeconds is the lockout duration at Threshold failures; it
+	// doubles with each additional failure, capped at MaxBackoffSeconds.
+	BaseBackoffSeconds int
+	MaxBackoffSeconds  int
+}
+
+// GRPCConfig holds the settings for the grpc.Server started alongside the
+// HTTP server in main.go.
+type GRPCConfig struct {
+	// Enabled controls whether main.go starts the gRPC listener at all.
+	Enabled bool
+	Port    string
+	// Reflection registers the gRPC reflection service, letting tools like
+	// grpcurl and evans introspect the API without a local copy of the
+	// .proto files. Safe to leave on in development; off by default in
+	// production (see validate).
+	Reflection bool
+}
+
+// JobsConfig holds the settings for the jobs.Manager background scheduler.
+type JobsConfig struct {
+	// MaxConcurrentWorkers bounds how many jobs can run at once.
+	MaxConcurrentWorkers int
+	// RunOnStartup runs every job once immediately at boot, in addition to
+	// its regular schedule. Useful in development so you don't have to wait
+	// for the next tick to see a job's effect.
+	RunOnStartup bool
+	// Schedules overrides a built-in job's cron expression by name (see
+	// jobs.defaultJobs for the names), without touching code. A job not
+	// present here keeps its built-in schedule.
+	Schedules map[string]string
+	// ReminderIntervalsMinutes is how long before a reservation's end time
+	// jobs.sendReservationReminders fires a WS notification, e.g. {60, 30,
+	// 15, 5} to remind at each of those minute marks.
+	ReminderIntervalsMinutes []int
+	// OverstayGraceMinutes is how long past entering 'overstay'
+	// jobs.autoCheckoutOverstayed waits before forcing a checkout.
+	OverstayGraceMinutes int
+	// OverstaySurchargePerMinute is accrued into a reservation's total_cost
+	// for every minute jobs.detectOverstays finds it past checkin_time +
+	// duration.
+	OverstaySurchargePerMinute float64
+}
+
+// FileStorageConfig holds the settings filestorage.Init uses to resolve a
+// Backend for vehicle-registration and reservation-attachment uploads.
+type FileStorageConfig struct {
+	// Backend selects the implementation: "local" (default) writes under
+	// LocalBasePath; "s3" dials the configured S3/MinIO endpoint.
+	Backend       string
+	LocalBasePath string
+	// PublicBaseURL prefixes the signed URLs the local backend returns,
+	// e.g. "http://localhost:8080", so a client can resolve them without
+	// knowing the server's internal routing.
+	PublicBaseURL string
+	// SigningKey authenticates the local backend's signed URLs; treat it
+	// as secret, the same as JWT.Secret.
+	SigningKey string
+
+	S3Endpoint        string
+	S3Bucket          string
+	S3AccessKeyID     string
+	S3SecretAccessKey string
+	S3UseSSL          bool
+
+	// SignedURLTTLSeconds bounds how long a download URL returned by
+	// handlers.DownloadReservationDocument stays valid.
+	SignedURLTTLSeconds int
+	// MaxUploadSizeMB caps a single document upload; larger requests are
+	// rejected before filestorage.Current.Save is even called.
+	MaxUploadSizeMB int64
+	// AllowedMimeTypes is the whitelist handlers.UploadReservationDocument
+	// checks an upload's detected content type against.
+	AllowedMimeTypes []string
+}
+
+// TracingConfig holds the settings internal/tracing.Init uses to export
+// OpenTelemetry spans.
+type TracingConfig struct {
+	// Enabled controls whether internal/tracing.Init installs a real
+	// OTLP-exporting tracer provider. Off by default since it depends on
+	// a collector (Jaeger/Tempo) being reachable at OTLPEndpoint.
+	Enabled bool
+	// ServiceName tags every span with the emitting service, so Jaeger can
+	// tell this API apart from the worker/grpc processes sharing the same
+	// collector.
+	ServiceName string
+	// OTLPEndpoint is the collector's OTLP/gRPC address, e.g.
+	// "localhost:4317".
+	OTLPEndpoint string
+	// SampleRatio is the fraction (0.0-1.0) of traces recorded; 1.0 records
+	// every request.
+	SampleRatio float64
+}
+
+// MetricsConfig holds the optional basic-auth credentials gating GET
+// /metrics, since a Prometheus scrape endpoint left open on a public
+// deployment leaks request-rate and error-rate data to anyone who finds it.
+type MetricsConfig struct {
+	// BasicAuthUser and BasicAuthPassword, when both set, require HTTP
+	// basic auth on /metrics. Either left empty leaves the endpoint open -
+	// the default, since most deployments scrape it from inside their own
+	// network.
+	BasicAuthUser     string
+	BasicAuthPassword string
+}
+
+// CORSConfig holds the origins setupRouter allows to make cross-origin
+// requests against the API.
+type CORSConfig struct {
+	// AllowedOrigins is the frontend origins to accept, e.g.
+	// "http://localhost:3000,https://app.example.com". Empty falls back to
+	// the localhost dev origins in defaults().
+	AllowedOrigins []string
+}
+
+// WSConfig holds the settings ws.NewWebSocketManager uses for its
+// per-topic replay buffers and WebSocket frame compression.
+type WSConfig struct {
+	// ReplayBufferSize bounds how many recent messages each topic keeps in
+	// memory for Client.readPump's RESUME control frame to replay after a
+	// reconnect, so a phone that briefly loses connectivity mid-reservation
+	// can backfill what it missed instead of silently losing it.
+	ReplayBufferSize int
+
+	// CompressionLevel is the flate level (1=flate.BestSpeed..9=
+	// flate.BestCompression) negotiated permessage-deflate frames are
+	// compressed at - see ws.Client.writePump.
+	CompressionLevel int
+
+	// CompressionThreshold is the minimum encoded frame size, in bytes,
+	// before writePump bothers enabling permessage-deflate for it. Below
+	// this, the deflate framing overhead costs more than it saves on a
+	// payload this small.
+	CompressionThreshold int
+}
+
+// StreamConfig holds the settings the SSE handlers in
+// handlers/parking_handler.go use for long-lived occupancy streams (see
+// internal/occupancy).
+type StreamConfig struct {
+	// IdleTimeoutSeconds bounds how long a stream stays open with no
+	// incoming notification before the handler closes it, so an abandoned
+	// client doesn't hold a goroutine and a Hub subscription forever.
+	IdleTimeoutSeconds int
+	// KeepaliveIntervalSeconds is how often a ": keepalive" comment is sent
+	// to keep the connection from being torn down by an idle-timing proxy.
+	KeepaliveIntervalSeconds int
+}
+
+// EventsConfig holds the settings services.Emit uses to fan parking_events
+// rows out to an operator-owned system, on top of the DB row it always
+// writes and the WS notification it always sends.
+type EventsConfig struct {
+	// WebhookURL, when non-empty, receives an HTTP POST of every Event
+	// Emit records, JSON-encoded. Delivery is best-effort: a failed POST is
+	// logged, not retried - the parking_events table is always the
+	// authoritative record, this is just a live mirror for an external
+	// system.
+	WebhookURL string
+	// WebhookTimeoutSeconds bounds how long Emit's webhook POST is allowed
+	// to take before it's abandoned.
+	WebhookTimeoutSeconds int
+}
+
+// Config is the fully-resolved application configuration produced by Load.
+type Config struct {
+	// Env is "development", "staging", or "production". Non-development
+	// environments get stricter validation (see validate).
+	Env string
+
+	DB            DBConfig
+	HTTP          HTTPConfig
+	JWT           JWTConfig
+	Redis         RedisConfig
+	Payments      PaymentsConfig
+	Notifications NotificationsConfig
+	Jobs          JobsConfig
+	MFA           MFAConfig
+	OAuth         OAuthConfig
+	AuthRateLimit AuthRateLimitConfig
+	RateLimit     RateLimitConfig
+	Lockout       LockoutConfig
+	GRPC          GRPCConfig
+	Routing       RoutingConfig
+	FileStorage   FileStorageConfig
+	Tracing       TracingConfig
+	Stream        StreamConfig
+	Events        EventsConfig
+	WS            WSConfig
+	CORS          CORSConfig
+	Metrics       MetricsConfig
+}
+
+var (
+	mu      sync.RWMutex
+	current *Config
+)
+
+// defaults returns the built-in configuration before any file, env, or flag
+// overrides are applied.
+func defaults() *Config {
+	return &Config{
+		Env: "development",
+		DB: DBConfig{
+			Driver:     "postgres",
+			Host:       "localhost",
+			Port:       "5432",
+			User:       "holycan",
+			Name:       "smart_parking_db",
+			SSLMode:    "disable",
+			SQLitePath: "smart_parking.db",
+		},
+		HTTP: HTTPConfig{
+			Port:                   "8080",
+			ShutdownTimeoutSeconds: 10,
+		},
+		JWT: JWTConfig{
+			Algorithm: "HS256",
+		},
+		WS: WSConfig{
+			ReplayBufferSize:     1024,
+			CompressionLevel:     5,
+			CompressionThreshold: 1024,
+		},
+		Redis: RedisConfig{
+			Addr: "localhost:6379",
+		},
+		Jobs: JobsConfig{
+			MaxConcurrentWorkers:       4,
+			RunOnStartup:               false,
+			Schedules:                  map[string]string{},
+			ReminderIntervalsMinutes:   []int{60, 30, 15, 5},
+			OverstayGraceMinutes:       30,
+			OverstaySurchargePerMinute: 0.50,
+		},
+		Notifications: NotificationsConfig{
+			SMTPTLSMode: "starttls",
+		},
+		CORS: CORSConfig{
+			AllowedOrigins: []string{"http://localhost:3000", "http://localhost:8080"},
+		},
+		MFA: MFAConfig{
+			TOTPSkewSteps: 1,
+		},
+		AuthRateLimit: AuthRateLimitConfig{
+			LoginAttempts:              5,
+			LoginWindowSeconds:         15 * 60,
+			RegisterAttempts:           10,
+			RegisterWindowSeconds:      60 * 60,
+			PasswordResetAttempts:      3,
+			PasswordResetWindowSeconds: 60 * 60,
+			MFAAttempts:                5,
+			MFAWindowSeconds:           5 * 60,
+		},
+		RateLimit: RateLimitConfig{
+			Backend:           "memory",
+			RequestsPerSecond: 20,
+			Burst:             40,
+			WSFramesPerSecond: 10,
+			WSFrameBurst:      20,
+		},
+		Lockout: LockoutConfig{
+			Threshold:          5,
+			BaseBackoffSeconds: 60,
+			MaxBackoffSeconds:  60 * 60,
+		},
+		GRPC: GRPCConfig{
+			Enabled:    false,
+			Port:       "9090",
+			Reflection: true,
+		},
+		Routing: RoutingConfig{
+			Enabled:        false,
+			BaseURL:        "http://localhost:8002",
+			TimeoutSeconds: 5,
+		},
+		FileStorage: FileStorageConfig{
+			Backend:             "local",
+			LocalBasePath:       "./static/documents",
+			PublicBaseURL:       "http://localhost:8080",
+			S3UseSSL:            true,
+			SignedURLTTLSeconds: 300,
+			MaxUploadSizeMB:     10,
+			AllowedMimeTypes:    []string{"application/pdf", "image/jpeg", "image/png"},
+		},
+		Tracing: TracingConfig{
+			Enabled:      false,
+			ServiceName:  "smart-parking-system",
+			OTLPEndpoint: "localhost:4317",
+			SampleRatio:  1.0,
+		},
+		Stream: StreamConfig{
+			IdleTimeoutSeconds:       300,
+			KeepaliveIntervalSeconds: 15,
+		},
+		Events: EventsConfig{
+			WebhookTimeoutSeconds: 5,
+		},
+	}
+}
+
+// Load resolves the Config from defaults -> config.yaml (if present) ->
+// environment variables -> CLI flags, validates it, and stores it as the
+// package-level singleton returned by Get. args is normally os.Args[1:];
+// passing it explicitly keeps Load testable.
+func Load(args []string) (*Config, error) {
+	if err := godotenv.Load(); err != nil {
+		log.Println("Warning: .env file not found, using environment variables")
+	}
+
+	cfg := defaults()
+
+	applyFile(cfg, "config.yaml")
+	applyEnv(cfg)
+	if err := applyFlags(cfg, args); err != nil {
+		return nil, err
+	}
+
+	if err := validate(cfg); err != nil {
+		return nil, err
+	}
+
+	mu.Lock()
+	current = cfg
+	mu.Unlock()
+
+	return cfg, nil
+}
+
+// Get returns the current configuration. It panics if Load hasn't run yet,
+// since every caller depends on it being populated at startup.
+func Get() *Config {
+	mu.RLock()
+	defer mu.RUnlock()
+	if current == nil {
+		panic("config: Get called before Load")
+	}
+	return current
+}
+
+// Ensure returns the current configuration, loading it with defaults/env
+// only (no CLI flags) if nothing has called Load yet. This lets packages
+// like database depend on config.Get() without requiring every test and
+// standalone tool to call config.Load(os.Args[1:]) first.
+func Ensure() *Config {
+	mu.RLock()
+	loaded := current != nil
+	mu.RUnlock()
+	if loaded {
+		return Get()
+	}
+
+	cfg, err := Load(nil)
+	if err != nil {
+		panic(fmt.Sprintf("config: default load failed: %v", err))
+	}
+	return cfg
+}
+
+// applyFile reads simple "key: value" pairs from a YAML-shaped config file,
+// one per line, ignoring blank lines and lines starting with '#'. It's
+// intentionally not a full YAML parser — section nesting is flattened via
+// dotted keys (e.g. "db.host: localhost") — since the only consumer is this
+// package and a real parser is more than config.yaml needs right now.
+func applyFile(cfg *Config, path string) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return
+	}
+
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		setField(cfg, strings.TrimSpace(key), strings.Trim(strings.TrimSpace(value), `"'`))
+	}
+}
+
+// applyEnv overlays environment variables on top of file-provided values.
+func applyEnv(cfg *Config) {
+	setIfPresent(&cfg.Env, "APP_ENV")
+
+	setIfPresent(&cfg.DB.Driver, "DB_DRIVER")
+	setIfPresent(&cfg.DB.Host, "DB_HOST")
+	setIfPresent(&cfg.DB.Port, "DB_PORT")
+	setIfPresent(&cfg.DB.User, "DB_USER")
+	setIfPresent(&cfg.DB.Password, "DB_PASSWORD")
+	setIfPresent(&cfg.DB.Name, "DB_NAME")
+	setIfPresent(&cfg.DB.SSLMode, "DB_SSL_MODE")
+	setIfPresent(&cfg.DB.SQLitePath, "DB_SQLITE_PATH")
+
+	setIfPresent(&cfg.HTTP.Port, "PORT")
+	setIfPresent(&cfg.HTTP.TLSCertFile, "TLS_CERT_FILE")
+	setIfPresent(&cfg.HTTP.TLSKeyFile, "TLS_KEY_FILE")
+	setSliceIfPresent(&cfg.HTTP.TrustedProxies, "HTTP_TRUSTED_PROXIES")
+	setIfPresent(&cfg.HTTP.TrustedPlatform, "HTTP_TRUSTED_PLATFORM")
+	setIntIfPresent(&cfg.HTTP.ShutdownTimeoutSeconds, "HTTP_SHUTDOWN_TIMEOUT_SECONDS")
+
+	setIfPresent(&cfg.JWT.Secret, "JWT_SECRET")
+	setIfPresent(&cfg.JWT.Algorithm, "JWT_ALGORITHM")
+	setIfPresent(&cfg.JWT.PrivateKeyPath, "JWT_PRIVATE_KEY_PATH")
+	setIfPresent(&cfg.JWT.KeyID, "JWT_KEY_ID")
+
+	setIntIfPresent(&cfg.WS.ReplayBufferSize, "WS_REPLAY_BUFFER_SIZE")
+	setIntIfPresent(&cfg.WS.CompressionLevel, "WS_COMPRESSION_LEVEL")
+	setIntIfPresent(&cfg.WS.CompressionThreshold, "WS_COMPRESSION_THRESHOLD")
+
+	setIfPresent(&cfg.Redis.Addr, "REDIS_ADDR")
+
+	setIfPresent(&cfg.Payments.ProviderAPIKey, "PAYMENTS_PROVIDER_API_KEY")
+
+	setIfPresent(&cfg.Notifications.SMTPHost, "SMTP_HOST")
+	setIfPresent(&cfg.Notifications.SMTPPort, "SMTP_PORT")
+	setIfPresent(&cfg.Notifications.SMTPUser, "SMTP_USER")
+	setIfPresent(&cfg.Notifications.SMTPPassword, "SMTP_PASSWORD")
+	setIfPresent(&cfg.Notifications.SMTPFrom, "SMTP_FROM")
+	setIfPresent(&cfg.Notifications.SMTPTLSMode, "SMTP_TLS_MODE")
+
+	setIfPresent(&cfg.MFA.EncryptionKey, "MFA_ENCRYPTION_KEY")
+	setIntIfPresent(&cfg.MFA.TOTPSkewSteps, "MFA_TOTP_SKEW_STEPS")
+
+	setIfPresent(&cfg.OAuth.Google.ClientID, "OAUTH_GOOGLE_CLIENT_ID")
+	setIfPresent(&cfg.OAuth.Google.ClientSecret, "OAUTH_GOOGLE_CLIENT_SECRET")
+	setIfPresent(&cfg.OAuth.Google.RedirectURL, "OAUTH_GOOGLE_REDIRECT_URL")
+	setSliceIfPresent(&cfg.OAuth.Google.Scopes, "OAUTH_GOOGLE_SCOPES")
+
+	setIfPresent(&cfg.OAuth.GitHub.ClientID, "OAUTH_GITHUB_CLIENT_ID")
+	setIfPresent(&cfg.OAuth.GitHub.ClientSecret, "OAUTH_GITHUB_CLIENT_SECRET")
+	setIfPresent(&cfg.OAuth.GitHub.RedirectURL, "OAUTH_GITHUB_REDIRECT_URL")
+	setSliceIfPresent(&cfg.OAuth.GitHub.Scopes, "OAUTH_GITHUB_SCOPES")
+
+	setIntIfPresent(&cfg.Jobs.MaxConcurrentWorkers, "JOBS_MAX_CONCURRENT_WORKERS")
+	setBoolIfPresent(&cfg.Jobs.RunOnStartup, "JOBS_RUN_ON_STARTUP")
+	setIntSliceIfPresent(&cfg.Jobs.ReminderIntervalsMinutes, "JOBS_REMINDER_INTERVALS_MINUTES")
+	setIntIfPresent(&cfg.Jobs.OverstayGraceMinutes, "JOBS_OVERSTAY_GRACE_MINUTES")
+	setFloatIfPresent(&cfg.Jobs.OverstaySurchargePerMinute, "JOBS_OVERSTAY_SURCHARGE_PER_MINUTE")
+	setJobIntervalIfPresent(cfg, "report_parking_metrics", "METRICS_RECONCILE_INTERVAL_SECONDS")
+
+	setIntIfPresent(&cfg.AuthRateLimit.LoginAttempts, "AUTH_RATE_LIMIT_LOGIN_ATTEMPTS")
+	setIntIfPresent(&cfg.AuthRateLimit.LoginWindowSeconds, "AUTH_RATE_LIMIT_LOGIN_WINDOW_SECONDS")
+	setIntIfPresent(&cfg.AuthRateLimit.RegisterAttempts, "AUTH_RATE_LIMIT_REGISTER_ATTEMPTS")
+	setIntIfPresent(&cfg.AuthRateLimit.RegisterWindowSeconds, "AUTH_RATE_LIMIT_REGISTER_WINDOW_SECONDS")
+	setIntIfPresent(&cfg.AuthRateLimit.PasswordResetAttempts, "AUTH_RATE_LIMIT_PASSWORD_RESET_ATTEMPTS")
+	setIntIfPresent(&cfg.AuthRateLimit.PasswordResetWindowSeconds, "AUTH_RATE_LIMIT_PASSWORD_RESET_WINDOW_SECONDS")
+	setIntIfPresent(&cfg.AuthRateLimit.MFAAttempts, "AUTH_RATE_LIMIT_MFA_ATTEMPTS")
+	setIntIfPresent(&cfg.AuthRateLimit.MFAWindowSeconds, "AUTH_RATE_LIMIT_MFA_WINDOW_SECONDS")
+	setIfPresent(&cfg.RateLimit.Backend, "RATE_LIMIT_BACKEND")
+	setIntIfPresent(&cfg.RateLimit.RequestsPerSecond, "RATE_LIMIT_REQUESTS_PER_SECOND")
+	setIntIfPresent(&cfg.RateLimit.Burst, "RATE_LIMIT_BURST")
+	setIntIfPresent(&cfg.RateLimit.WSFramesPerSecond, "RATE_LIMIT_WS_FRAMES_PER_SECOND")
+	setIntIfPresent(&cfg.RateLimit.WSFrameBurst, "RATE_LIMIT_WS_FRAME_BURST")
+
+	setIntIfPresent(&cfg.Lockout.Threshold, "LOCKOUT_THRESHOLD")
+	setIntIfPresent(&cfg.Lockout.BaseBackoffSeconds, "LOCKOUT_BASE_BACKOFF_SECONDS")
+	setIntIfPresent(&cfg.Lockout.MaxBackoffSeconds, "LOCKOUT_MAX_BACKOFF_SECONDS")
+
+	setBoolIfPresent(&cfg.GRPC.Enabled, "GRPC_ENABLED")
+	setIfPresent(&cfg.GRPC.Port, "GRPC_PORT")
+	setBoolIfPresent(&cfg.GRPC.Reflection, "GRPC_REFLECTION")
+
+	setBoolIfPresent(&cfg.Routing.Enabled, "ROUTING_ENABLED")
+	setIfPresent(&cfg.Routing.BaseURL, "ROUTING_BASE_URL")
+	setIntIfPresent(&cfg.Routing.TimeoutSeconds, "ROUTING_TIMEOUT_SECONDS")
+
+	setIfPresent(&cfg.FileStorage.Backend, "FILESTORAGE_BACKEND")
+	setIfPresent(&cfg.FileStorage.LocalBasePath, "FILESTORAGE_LOCAL_BASE_PATH")
+	setIfPresent(&cfg.FileStorage.PublicBaseURL, "FILESTORAGE_PUBLIC_BASE_URL")
+	setIfPresent(&cfg.FileStorage.SigningKey, "FILESTORAGE_SIGNING_KEY")
+	setIfPresent(&cfg.FileStorage.S3Endpoint, "FILESTORAGE_S3_ENDPOINT")
+	setIfPresent(&cfg.FileStorage.S3Bucket, "FILESTORAGE_S3_BUCKET")
+	setIfPresent(&cfg.FileStorage.S3AccessKeyID, "FILESTORAGE_S3_ACCESS_KEY_ID")
+	setIfPresent(&cfg.FileStorage.S3SecretAccessKey, "FILESTORAGE_S3_SECRET_ACCESS_KEY")
+	setBoolIfPresent(&cfg.FileStorage.S3UseSSL, "FILESTORAGE_S3_USE_SSL")
+	setIntIfPresent(&cfg.FileStorage.SignedURLTTLSeconds, "FILESTORAGE_SIGNED_URL_TTL_SECONDS")
+	var maxUploadSizeMB int
+	maxUploadSizeMB = int(cfg.FileStorage.MaxUploadSizeMB)
+	setIntIfPresent(&maxUploadSizeMB, "FILESTORAGE_MAX_UPLOAD_SIZE_MB")
+	cfg.FileStorage.MaxUploadSizeMB = int64(maxUploadSizeMB)
+	setSliceIfPresent(&cfg.FileStorage.AllowedMimeTypes, "FILESTORAGE_ALLOWED_MIME_TYPES")
+
+	setBoolIfPresent(&cfg.Tracing.Enabled, "TRACING_ENABLED")
+	setIfPresent(&cfg.Tracing.ServiceName, "TRACING_SERVICE_NAME")
+	setIfPresent(&cfg.Tracing.OTLPEndpoint, "TRACING_OTLP_ENDPOINT")
+	setFloatIfPresent(&cfg.Tracing.SampleRatio, "TRACING_SAMPLE_RATIO")
+
+	setIntIfPresent(&cfg.Stream.IdleTimeoutSeconds, "STREAM_IDLE_TIMEOUT_SECONDS")
+	setIntIfPresent(&cfg.Stream.KeepaliveIntervalSeconds, "STREAM_KEEPALIVE_INTERVAL_SECONDS")
+
+	setIfPresent(&cfg.Events.WebhookURL, "EVENTS_WEBHOOK_URL")
+	setIntIfPresent(&cfg.Events.WebhookTimeoutSeconds, "EVENTS_WEBHOOK_TIMEOUT_SECONDS")
+
+	setSliceIfPresent(&cfg.CORS.AllowedOrigins, "CORS_ALLOWED_ORIGINS")
+
+	setIfPresent(&cfg.Metrics.BasicAuthUser, "METRICS_BASIC_AUTH_USER")
+	setIfPresent(&cfg.Metrics.BasicAuthPassword, "METRICS_BASIC_AUTH_PASSWORD")
+}
+
+func setIfPresent(dst *string, key string) {
+	if v := os.Getenv(key); v != "" {
+		*dst = v
+	}
+}
+
+func setIntIfPresent(dst *int, key string) {
+	v := os.Getenv(key)
+	if v == "" {
+		return
+	}
+	parsed, err := strconv.Atoi(v)
+	if err != nil {
+		log.Printf("config: ignoring invalid %s=%q: %v", key, v, err)
+		return
+	}
+	*dst = parsed
+}
+
+// setJobIntervalIfPresent overlays an env var naming an interval in
+// seconds onto cfg.Jobs.Schedules[jobName], translating it into the
+// "@every Ns" robfig/cron expression jobs.Manager expects — the same
+// per-job override jobs.schedule.<name> in config.yaml would set, just
+// expressed as a plain interval since that's a more natural knob for this
+// one job than a full cron expression.
+func setJobIntervalIfPresent(cfg *Config, jobName, key string) {
+	v := os.Getenv(key)
+	if v == "" {
+		return
+	}
+	seconds, err := strconv.Atoi(v)
+	if err != nil || seconds <= 0 {
+		log.Printf("config: ignoring invalid %s=%q: must be a positive number of seconds", key, v)
+		return
+	}
+	if cfg.Jobs.Schedules == nil {
+		cfg.Jobs.Schedules = map[string]string{}
+	}
+	cfg.Jobs.Schedules[jobName] = fmt.Sprintf("@every %ds", seconds)
+}
+
+// setSliceIfPresent overlays a comma-separated env var onto dst, e.g.
+// OAUTH_GOOGLE_SCOPES=openid,email,profile.
+func setSliceIfPresent(dst *[]string, key string) {
+	v := os.Getenv(key)
+	if v == "" {
+		return
+	}
+	parts := strings.Split(v, ",")
+	for i, p := range parts {
+		parts[i] = strings.TrimSpace(p)
+	}
+	*dst = parts
+}
+
+// setIntSliceIfPresent overlays a comma-separated env var of integers onto
+// dst, e.g. JOBS_REMINDER_INTERVALS_MINUTES=60,30,15,5.
+func setIntSliceIfPresent(dst *[]int, key string) {
+	v := os.Getenv(key)
+	if v == "" {
+		return
+	}
+	parts := strings.Split(v, ",")
+	parsed := make([]int, 0, len(parts))
+	for _, p := range parts {
+		n, err := strconv.Atoi(strings.TrimSpace(p))
+		if err != nil {
+			log.Printf("config: ignoring invalid %s=%q: %v", key, v, err)
+			return
+		}
+		parsed = append(parsed, n)
+	}
+	*dst = parsed
+}
+
+func setFloatIfPresent(dst *float64, key string) {
+	v := os.Getenv(key)
+	if v == "" {
+		return
+	}
+	parsed, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		log.Printf("config: ignoring invalid %s=%q: %v", key, v, err)
+		return
+	}
+	*dst = parsed
+}
+
+func setBoolIfPresent(dst *bool, key string) {
+	v := os.Getenv(key)
+	if v == "" {
+		return
+	}
+	parsed, err := strconv.ParseBool(v)
+	if err != nil {
+		log.Printf("config: ignoring invalid %s=%q: %v", key, v, err)
+		return
+	}
+	*dst = parsed
+}
+
+// applyFlags overlays CLI flags, the highest-precedence layer. Flags are
+// optional; an unset flag leaves the env/file/default value untouched.
+func applyFlags(cfg *Config, args []string) error {
+	fs := flag.NewFlagSet("config", flag.ContinueOnError)
+	httpPort := fs.String("http-port", cfg.HTTP.Port, "HTTP listen port")
+	dbHost := fs.String("db-host", cfg.DB.Host, "database host")
+	dbName := fs.String("db-name", cfg.DB.Name, "database name")
+	env := fs.String("env", cfg.Env, "application environment")
+
+	// Unrecognized flags (e.g. the "migrate" subcommand and its args) are
+	// expected here since Load doesn't own the whole CLI surface — ignore
+	// them rather than failing startup.
+	fs.Usage = func() {}
+	if err := fs.Parse(args); err != nil && err != flag.ErrHelp {
+		return nil
+	}
+
+	cfg.HTTP.Port = *httpPort
+	cfg.DB.Host = *dbHost
+	cfg.DB.Name = *dbName
+	cfg.Env = *env
+	return nil
+}
+
+// setField maps a dotted config.yaml key onto the matching struct field.
+func setField(cfg *Config, key, value string) {
+	if strings.HasPrefix(key, "jobs.schedule.") {
+		if cfg.Jobs.Schedules == nil {
+			cfg.Jobs.Schedules = map[string]string{}
+		}
+		cfg.Jobs.Schedules[strings.TrimPrefix(key, "jobs.schedule.")] = value
+		return
+	}
+
+	switch key {
+	case "env":
+		cfg.Env = value
+	case "db.driver":
+		cfg.DB.Driver = value
+	case "db.host":
+		cfg.DB.Host = value
+	case "db.port":
+		cfg.DB.Port = value
+	case "db.user":
+		cfg.DB.User = value
+	case "db.password":
+		cfg.DB.Password = value
+	case "db.name":
+		cfg.DB.Name = value
+	case "db.ssl_mode":
+		cfg.DB.SSLMode = value
+	case "db.sqlite_path":
+		cfg.DB.SQLitePath = value
+	case "http.port":
+		cfg.HTTP.Port = value
+	case "http.tls_cert_file":
+		cfg.HTTP.TLSCertFile = value
+	case "http.tls_key_file":
+		cfg.HTTP.TLSKeyFile = value
+	case "http.trusted_proxies":
+		cfg.HTTP.TrustedProxies = strings.Split(value, ",")
+	case "http.trusted_platform":
+		cfg.HTTP.TrustedPlatform = value
+	case "http.shutdown_timeout_seconds":
+		if parsed, err := strconv.Atoi(value); err == nil {
+			cfg.HTTP.ShutdownTimeoutSeconds = parsed
+		}
+	case "jwt.secret":
+		cfg.JWT.Secret = value
+	case "jwt.algorithm":
+		cfg.JWT.Algorithm = value
+	case "jwt.private_key_path":
+		cfg.JWT.PrivateKeyPath = value
+	case "jwt.key_id":
+		cfg.JWT.KeyID = value
+	case "ws.replay_buffer_size":
+		if parsed, err := strconv.Atoi(value); err == nil {
+			cfg.WS.ReplayBufferSize = parsed
+		}
+	case "ws.compression_level":
+		if parsed, err := strconv.Atoi(value); err == nil {
+			cfg.WS.CompressionLevel = parsed
+		}
+	case "ws.compression_threshold":
+		if parsed, err := strconv.Atoi(value); err == nil {
+			cfg.WS.CompressionThreshold = parsed
+		}
+	case "rate_limit.backend":
+		cfg.RateLimit.Backend = value
+	case "rate_limit.requests_per_second":
+		if parsed, err := strconv.Atoi(value); err == nil {
+			cfg.RateLimit.RequestsPerSecond = parsed
+		}
+	case "rate_limit.burst":
+		if parsed, err := strconv.Atoi(value); err == nil {
+			cfg.RateLimit.Burst = parsed
+		}
+	case "rate_limit.ws_frames_per_second":
+		if parsed, err := strconv.Atoi(value); err == nil {
+			cfg.RateLimit.WSFramesPerSecond = parsed
+		}
+	case "rate_limit.ws_frame_burst":
+		if parsed, err := strconv.Atoi(value); err == nil {
+			cfg.RateLimit.WSFrameBurst = parsed
+		}
+	case "redis.addr":
+		cfg.Redis.Addr = value
+	case "payments.provider_api_key":
+		cfg.Payments.ProviderAPIKey = value
+	case "notifications.smtp_host":
+		cfg.Notifications.SMTPHost = value
+	case "notifications.smtp_port":
+		cfg.Notifications.SMTPPort = value
+	case "notifications.smtp_user":
+		cfg.Notifications.SMTPUser = value
+	case "notifications.smtp_password":
+		cfg.Notifications.SMTPPassword = value
+	case "notifications.smtp_from":
+		cfg.Notifications.SMTPFrom = value
+	case "notifications.smtp_tls_mode":
+		cfg.Notifications.SMTPTLSMode = value
+	case "mfa.encryption_key":
+		cfg.MFA.EncryptionKey = value
+	case "mfa.totp_skew_steps":
+		if parsed, err := strconv.Atoi(value); err == nil {
+			cfg.MFA.TOTPSkewSteps = parsed
+		}
+	case "oauth.google.client_id":
+		cfg.OAuth.Google.ClientID = value
+	case "oauth.google.client_secret":
+		cfg.OAuth.Google.ClientSecret = value
+	case "oauth.google.redirect_url":
+		cfg.OAuth.Google.RedirectURL = value
+	case "oauth.google.scopes":
+		cfg.OAuth.Google.Scopes = strings.Split(value, ",")
+	case "oauth.github.client_id":
+		cfg.OAuth.GitHub.ClientID = value
+	case "oauth.github.client_secret":
+		cfg.OAuth.GitHub.ClientSecret = value
+	case "oauth.github.redirect_url":
+		cfg.OAuth.GitHub.RedirectURL = value
+	case "oauth.github.scopes":
+		cfg.OAuth.GitHub.Scopes = strings.Split(value, ",")
+	case "jobs.max_concurrent_workers":
+		if parsed, err := strconv.Atoi(value); err == nil {
+			cfg.Jobs.MaxConcurrentWorkers = parsed
+		}
+	case "jobs.run_on_startup":
+		if parsed, err := strconv.ParseBool(value); err == nil {
+			cfg.Jobs.RunOnStartup = parsed
+		}
+	case "jobs.reminder_intervals_minutes":
+		parts := strings.Split(value, ",")
+		intervals := make([]int, 0, len(parts))
+		for _, p := range parts {
+			if parsed, err := strconv.Atoi(strings.TrimSpace(p)); err == nil {
+				intervals = append(intervals, parsed)
+			}
+		}
+		cfg.Jobs.ReminderIntervalsMinutes = intervals
+	case "jobs.overstay_grace_minutes":
+		if parsed, err := strconv.Atoi(value); err == nil {
+			cfg.Jobs.OverstayGraceMinutes = parsed
+		}
+	case "jobs.overstay_surcharge_per_minute":
+		if parsed, err := strconv.ParseFloat(value, 64); err == nil {
+			cfg.Jobs.OverstaySurchargePerMinute = parsed
+		}
+	case "auth_rate_limit.login_attempts":
+		if parsed, err := strconv.Atoi(value); err == nil {
+			cfg.AuthRateLimit.LoginAttempts = parsed
+		}
+	case "auth_rate_limit.login_window_seconds":
+		if parsed, err := strconv.Atoi(value); err == nil {
+			cfg.AuthRateLimit.LoginWindowSeconds = parsed
+		}
+	case "auth_rate_limit.register_attempts":
+		if parsed, err := strconv.Atoi(value); err == nil {
+			cfg.AuthRateLimit.RegisterAttempts = parsed
+		}
+	case "auth_rate_limit.register_window_seconds":
+		if parsed, err := strconv.Atoi(value); err == nil {
+			cfg.AuthRateLimit.RegisterWindowSeconds = parsed
+		}
+	case "auth_rate_limit.password_reset_attempts":
+		if parsed, err := strconv.Atoi(value); err == nil {
+			cfg.AuthRateLimit.PasswordResetAttempts = parsed
+		}
+	case "auth_rate_limit.password_reset_window_seconds":
+		if parsed, err := strconv.Atoi(value); err == nil {
+			cfg.AuthRateLimit.PasswordResetWindowSeconds = parsed
+		}
+	case "auth_rate_limit.mfa_attempts":
+		if parsed, err := strconv.Atoi(value); err == nil {
+			cfg.AuthRateLimit.MFAAttempts = parsed
+		}
+	case "auth_rate_limit.mfa_window_seconds":
+		if parsed, err := strconv.Atoi(value); err == nil {
+			cfg.AuthRateLimit.MFAWindowSeconds = parsed
+		}
+	case "lockout.threshold":
+		if parsed, err := strconv.Atoi(value); err == nil {
+			cfg.Lockout.Threshold = parsed
+		}
+	case "lockout.base_backoff_seconds":
+		if parsed, err := strconv.Atoi(value); err == nil {
+			cfg.Lockout.BaseBackoffSeconds = parsed
+		}
+	case "lockout.max_backoff_seconds":
+		if parsed, err := strconv.Atoi(value); err == nil {
+			cfg.Lockout.MaxBackoffSeconds = parsed
+		}
+	case "grpc.enabled":
+		if parsed, err := strconv.ParseBool(value); err == nil {
+			cfg.GRPC.Enabled = parsed
+		}
+	case "grpc.port":
+		cfg.GRPC.Port = value
+	case "grpc.reflection":
+		if parsed, err := strconv.ParseBool(value); err == nil {
+			cfg.GRPC.Reflection = parsed
+		}
+	case "routing.enabled":
+		if parsed, err := strconv.ParseBool(value); err == nil {
+			cfg.Routing.Enabled = parsed
+		}
+	case "routing.base_url":
+		cfg.Routing.BaseURL = value
+	case "routing.timeout_seconds":
+		if parsed, err := strconv.Atoi(value); err == nil {
+			cfg.Routing.TimeoutSeconds = parsed
+		}
+	case "filestorage.backend":
+		cfg.FileStorage.Backend = value
+	case "filestorage.local_base_path":
+		cfg.FileStorage.LocalBasePath = value
+	case "filestorage.public_base_url":
+		cfg.FileStorage.PublicBaseURL = value
+	case "filestorage.signing_key":
+		cfg.FileStorage.SigningKey = value
+	case "filestorage.s3_endpoint":
+		cfg.FileStorage.S3Endpoint = value
+	case "filestorage.s3_bucket":
+		cfg.FileStorage.S3Bucket = value
+	case "filestorage.s3_access_key_id":
+		cfg.FileStorage.S3AccessKeyID = value
+	case "filestorage.s3_secret_access_key":
+		cfg.FileStorage.S3SecretAccessKey = value
+	case "filestorage.s3_use_ssl":
+		if parsed, err := strconv.ParseBool(value); err == nil {
+			cfg.FileStorage.S3UseSSL = parsed
+		}
+	case "filestorage.signed_url_ttl_seconds":
+		if parsed, err := strconv.Atoi(value); err == nil {
+			cfg.FileStorage.SignedURLTTLSeconds = parsed
+		}
+	case "filestorage.max_upload_size_mb":
+		if parsed, err := strconv.Atoi(value); err == nil {
+			cfg.FileStorage.MaxUploadSizeMB = int64(parsed)
+		}
+	case "filestorage.allowed_mime_types":
+		cfg.FileStorage.AllowedMimeTypes = strings.Split(value, ",")
+	case "tracing.enabled":
+		if parsed, err := strconv.ParseBool(value); err == nil {
+			cfg.Tracing.Enabled = parsed
+		}
+	case "tracing.service_name":
+		cfg.Tracing.ServiceName = value
+	case "tracing.otlp_endpoint":
+		cfg.Tracing.OTLPEndpoint = value
+	case "tracing.sample_ratio":
+		if parsed, err := strconv.ParseFloat(value, 64); err == nil {
+			cfg.Tracing.SampleRatio = parsed
+		}
+	case "stream.idle_timeout_seconds":
+		if parsed, err := strconv.Atoi(value); err == nil {
+			cfg.Stream.IdleTimeoutSeconds = parsed
+		}
+	case "stream.keepalive_interval_seconds":
+		if parsed, err := strconv.Atoi(value); err == nil {
+			cfg.Stream.KeepaliveIntervalSeconds = parsed
+		}
+	case "events.webhook_url":
+		cfg.Events.WebhookURL = value
+	case "events.webhook_timeout_seconds":
+		if parsed, err := strconv.Atoi(value); err == nil {
+			cfg.Events.WebhookTimeoutSeconds = parsed
+		}
+	case "cors.allowed_origins":
+		cfg.CORS.AllowedOrigins = strings.Split(value, ",")
+	case "metrics.basic_auth_user":
+		cfg.Metrics.BasicAuthUser = value
+	case "metrics.basic_auth_password":
+		cfg.Metrics.BasicAuthPassword = value
+	}
+}
+
+// hardcodedPasswordFallback is the old default that used to live in
+// database.Initialize. It must never be accepted as a real password again.
+const hardcodedPasswordFallback = "ramaa212!"
+
+// validate fails fast on configuration that would otherwise surface as a
+// confusing connection error (or, worse, a silently-accepted weak secret)
+// much later at runtime.
+func validate(cfg *Config) error {
+	if cfg.DB.Password == hardcodedPasswordFallback {
+		return fmt.Errorf("config: DB_PASSWORD must not use the old hard-coded default; set a real password")
+	}
+
+	if cfg.Env != "development" && cfg.DB.Password == "" {
+		return fmt.Errorf("config: DB_PASSWORD is required when APP_ENV=%s", cfg.Env)
+	}
+
+	switch cfg.JWT.Algorithm {
+	case "HS256":
+		// Required in every environment, not just production - a JWT
+		// signed with an empty key is trivially forgeable, and there's no
+		// safe built-in default left to fall back to (see the removed
+		// middleware.jwtSecretOrDefault).
+		if cfg.JWT.Secret == "" {
+			return fmt.Errorf("config: JWT_SECRET is required")
+		}
+	case "RS256", "ES256":
+		if cfg.JWT.PrivateKeyPath == "" {
+			return fmt.Errorf("config: JWT_PRIVATE_KEY_PATH is required when JWT_ALGORITHM=%s", cfg.JWT.Algorithm)
+		}
+	default:
+		return fmt.Errorf("config: JWT_ALGORITHM must be one of HS256, RS256, ES256, got %q", cfg.JWT.Algorithm)
+	}
+
+	if cfg.Env != "development" && cfg.MFA.EncryptionKey == "" {
+		return fmt.Errorf("config: MFA_ENCRYPTION_KEY is required when APP_ENV=%s", cfg.Env)
+	}
+
+	return nil
+}
+
+// Redacted returns a copy of the DSN-style connection description safe to
+// log: the password is replaced with a fixed placeholder so connection
+// strings never leak credentials into application logs.
+func (d DBConfig) Redacted() string {
+	password := "(empty)"
+	if d.Password != "" {
+		password = "***"
+	}
+	return fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=%s",
+		d.Host, d.Port, d.User, password, d.Name, d.SSLMode)
+}
+
+// DSN builds the real connection string used to open the database, with
+// the actual password in place. Only pass this to sql.Open, never to a
+// logger — use Redacted for anything user- or log-facing.
+func (d DBConfig) DSN() string {
+	return fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=%s",
+		d.Host, d.Port, d.User, d.Password, d.Name, d.SSLMode)
+}
@@ -0,0 +1,43 @@
+package config
+
+import (
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// WatchReload listens for SIGHUP and re-resolves config.yaml + environment
+// variables into the running Config. DB settings are deliberately left
+// untouched on reload: swapping database.App's connection string out from
+// under in-flight queries isn't safe, so a DB config change still requires
+// a restart. HTTP, JWT, payments, and notifications settings can change
+// without a restart and are applied in place.
+func WatchReload() {
+	sighup := make(chan os.Signal, 1)
+	signal.Notify(sighup, syscall.SIGHUP)
+
+	go func() {
+		for range sighup {
+			log.Println("config: SIGHUP received, reloading non-DB settings")
+
+			reloaded := defaults()
+			applyFile(reloaded, "config.yaml")
+			applyEnv(reloaded)
+
+			if err := validate(reloaded); err != nil {
+				log.Printf("config: reload rejected: %v", err)
+				continue
+			}
+
+			mu.Lock()
+			current.HTTP = reloaded.HTTP
+			current.JWT = reloaded.JWT
+			current.Payments = reloaded.Payments
+			current.Notifications = reloaded.Notifications
+			mu.Unlock()
+
+			log.Println("config: reload complete")
+		}
+	}()
+}
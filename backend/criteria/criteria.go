@@ -0,0 +1,261 @@
+// Package criteria centralizes the filter-building SQL that used to be
+// hand-duplicated across the list handlers in handlers/parking_handler.go.
+// GetParkingLots and FilterParkingSpaces each rebuilt a second, separate
+// COUNT query alongside their SELECT - one by re-running the same filter
+// loop twice, the other by string.Replace-ing the SELECT's already-built
+// projection - both of which quietly break the moment the SELECT and
+// COUNT drift out of sync. ParkingLotCriteria and ParkingSpaceCriteria
+// build their WHERE clause exactly once, and Where/OrderBy/Build expose
+// it to both queries so that can't happen again.
+package criteria
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// SortDirection is the direction a criteria orders its results in.
+type SortDirection string
+
+const (
+	SortAscending  SortDirection = "ASC"
+	SortDescending SortDirection = "DESC"
+)
+
+// direction normalizes d to a valid SortDirection, defaulting to ascending.
+func direction(d SortDirection) SortDirection {
+	if d == SortDescending {
+		return SortDescending
+	}
+	return SortAscending
+}
+
+// ParkingLotCriteria filters, sorts, and paginates the parking_lots list
+// query behind GetParkingLots.
+type ParkingLotCriteria struct {
+	City  string
+	State string
+
+	// Q full-text searches name, address, and city via the generated
+	// search_vector GIN index from migration 0014, falling back to
+	// pg_trgm similarity for typo tolerance when the tsquery matches
+	// nothing.
+	Q string
+
+	DateMinCreated *time.Time
+	DateMaxCreated *time.Time
+
+	// Sort is one of "name", "created_at", "price", or "availability";
+	// unrecognized values fall back to "name". "distance" additionally
+	// requires Lat/Lng, since it's only meaningful relative to a point -
+	// without them it also falls back to "name".
+	Sort      string
+	Direction SortDirection
+	Lat       *float64
+	Lng       *float64
+
+	// Skip and Limit drive plain OFFSET pagination via Build. Handlers
+	// using keyset cursor pagination instead (see pagination.Predicate)
+	// should call Where/OrderBy directly and layer the cursor predicate
+	// in between, the same way GetParkingLots does.
+	Skip  int
+	Limit int
+}
+
+// Where returns the shared WHERE clause (empty string if there are no
+// filters) and its positional args, starting at $1. Both the SELECT and
+// COUNT query for a criteria must be built from this same call so they
+// can never drift apart.
+func (c ParkingLotCriteria) Where() (clause string, args []interface{}) {
+	var parts []string
+	paramIndex := 1
+
+	if c.City != "" {
+		parts = append(parts, fmt.Sprintf("city = $%d", paramIndex))
+		args = append(args, c.City)
+		paramIndex++
+	}
+	if c.State != "" {
+		parts = append(parts, fmt.Sprintf("state = $%d", paramIndex))
+		args = append(args, c.State)
+		paramIndex++
+	}
+	if c.Q != "" {
+		parts = append(parts, fmt.Sprintf(
+			"(search_vector @@ plainto_tsquery('simple', $%d) "+
+				"OR similarity(name || ' ' || address || ' ' || city, $%d) > 0.2)",
+			paramIndex, paramIndex,
+		))
+		args = append(args, c.Q)
+		paramIndex++
+	}
+	if c.DateMinCreated != nil {
+		parts = append(parts, fmt.Sprintf("created_at >= $%d", paramIndex))
+		args = append(args, *c.DateMinCreated)
+		paramIndex++
+	}
+	if c.DateMaxCreated != nil {
+		parts = append(parts, fmt.Sprintf("created_at <= $%d", paramIndex))
+		args = append(args, *c.DateMaxCreated)
+		paramIndex++
+	}
+
+	if len(parts) == 0 {
+		return "", args
+	}
+	return " WHERE " + strings.Join(parts, " AND "), args
+}
+
+// lotSortColumns maps a Sort value to the expression it orders by.
+// "availability" orders by how many of the lot's spaces are free, via a
+// correlated subquery rather than a join, so it doesn't change how many
+// rows the main query returns.
+var lotSortColumns = map[string]string{
+	"name":       "name",
+	"created_at": "created_at",
+	"price":      "hourly_rate",
+	"availability": "(SELECT COUNT(*) FROM parking_spaces ps " +
+		"WHERE ps.parking_lot_id = parking_lots.id AND ps.is_occupied = false)",
+}
+
+// OrderBy returns the ORDER BY clause (without the "ORDER BY" keyword's
+// leading clause separator) for c.Sort/c.Direction, with id appended as a
+// tie-breaker so paginated results stay stable across pages. "distance"
+// requires Lat and Lng; without them it falls back to "name" the same as
+// any other unrecognized Sort value.
+func (c ParkingLotCriteria) OrderBy() (clause string, args []interface{}) {
+	dir := direction(c.Direction)
+
+	if c.Sort == "distance" && c.Lat != nil && c.Lng != nil {
+		return fmt.Sprintf(
+			"ST_Distance(location, ST_SetSRID(ST_MakePoint($1, $2), 4326)::geography) %s, id %s",
+			dir, dir,
+		), []interface{}{*c.Lng, *c.Lat}
+	}
+
+	col, ok := lotSortColumns[c.Sort]
+	if !ok {
+		col = "name"
+	}
+	return fmt.Sprintf("%s %s, id %s", col, dir, dir), nil
+}
+
+// Build assembles the full SELECT and COUNT queries for c, sharing the
+// same WHERE clause and args; the COUNT query never carries ORDER BY or
+// OFFSET/LIMIT since neither affects the count. selectSQL's ORDER BY
+// expression may need its own leading args (distance sort does), which
+// Build places right after the WHERE args to keep the placeholder
+// numbering contiguous - callers appending a cursor predicate on top
+// should use Where/OrderBy directly instead, as GetParkingLots does.
+func (c ParkingLotCriteria) Build() (selectSQL, countSQL string, args []interface{}) {
+	where, whereArgs := c.Where()
+	args = whereArgs
+
+	selectSQL = "SELECT id, name, address, city, state, zip_code, latitude, longitude, " +
+		"total_spaces, hourly_rate, open_time, close_time, is_open_24h, created_at, updated_at " +
+		"FROM parking_lots" + where
+	countSQL = "SELECT COUNT(*) FROM parking_lots" + where
+
+	// OrderBy's own placeholders ($1/$2 for distance sort) are rewritten
+	// to land after the WHERE clause's args before being appended.
+	orderBy, orderArgs := c.OrderBy()
+	for i := range orderArgs {
+		orderBy = strings.Replace(orderBy, fmt.Sprintf("$%d", i+1), fmt.Sprintf("$%d", len(args)+i+1), 1)
+	}
+	args = append(args, orderArgs...)
+
+	selectSQL += " ORDER BY " + orderBy
+
+	if c.Limit > 0 {
+		selectSQL += fmt.Sprintf(" LIMIT $%d", len(args)+1)
+		args = append(args, c.Limit)
+	}
+	if c.Skip > 0 {
+		selectSQL += fmt.Sprintf(" OFFSET $%d", len(args)+1)
+		args = append(args, c.Skip)
+	}
+
+	return selectSQL, countSQL, args
+}
+
+// ParkingSpaceCriteria filters and paginates the parking_spaces list query
+// behind FilterParkingSpaces.
+type ParkingSpaceCriteria struct {
+	ParkingLotID  string
+	AvailableOnly bool
+	VehicleType   string
+
+	DateMinCreated *time.Time
+	DateMaxCreated *time.Time
+
+	Skip  int
+	Limit int
+}
+
+// Where returns the shared WHERE clause and its positional args, starting
+// at $1, for the "ps"/"pl" aliases FilterParkingSpaces joins
+// parking_spaces against parking_lots as.
+func (c ParkingSpaceCriteria) Where() (clause string, args []interface{}) {
+	var parts []string
+	paramIndex := 1
+
+	if c.ParkingLotID != "" {
+		parts = append(parts, fmt.Sprintf("ps.parking_lot_id = $%d", paramIndex))
+		args = append(args, c.ParkingLotID)
+		paramIndex++
+	}
+	if c.AvailableOnly {
+		parts = append(parts, "ps.is_occupied = false AND ps.is_blocked = false")
+	}
+	if c.VehicleType != "" {
+		parts = append(parts, fmt.Sprintf("ps.type = $%d", paramIndex))
+		args = append(args, c.VehicleType)
+		paramIndex++
+	}
+	if c.DateMinCreated != nil {
+		parts = append(parts, fmt.Sprintf("ps.created_at >= $%d", paramIndex))
+		args = append(args, *c.DateMinCreated)
+		paramIndex++
+	}
+	if c.DateMaxCreated != nil {
+		parts = append(parts, fmt.Sprintf("ps.created_at <= $%d", paramIndex))
+		args = append(args, *c.DateMaxCreated)
+		paramIndex++
+	}
+
+	if len(parts) == 0 {
+		return "", args
+	}
+	return " WHERE " + strings.Join(parts, " AND "), args
+}
+
+// Build assembles the full SELECT and COUNT queries for c, sharing the
+// same WHERE clause and args - see ParkingLotCriteria.Build.
+func (c ParkingSpaceCriteria) Build() (selectSQL, countSQL string, args []interface{}) {
+	where, args := c.Where()
+
+	selectSQL = `
+		SELECT ps.id, ps.parking_lot_id, ps.space_number, ps.type, ps.is_occupied,
+		       ps.is_blocked, pl.hourly_rate, ps.created_at, ps.updated_at,
+		       pl.name AS parking_lot_name, pl.address
+		FROM parking_spaces ps
+		JOIN parking_lots pl ON ps.parking_lot_id = pl.id` + where
+	countSQL = `
+		SELECT COUNT(*)
+		FROM parking_spaces ps
+		JOIN parking_lots pl ON ps.parking_lot_id = pl.id` + where
+
+	selectSQL += " ORDER BY pl.name ASC, ps.space_number ASC, ps.id ASC"
+
+	if c.Limit > 0 {
+		selectSQL += fmt.Sprintf(" LIMIT $%d", len(args)+1)
+		args = append(args, c.Limit)
+	}
+	if c.Skip > 0 {
+		selectSQL += fmt.Sprintf(" OFFSET $%d", len(args)+1)
+		args = append(args, c.Skip)
+	}
+
+	return selectSQL, countSQL, args
+}
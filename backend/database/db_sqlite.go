@@ -0,0 +1,162 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+
+	"github.com/holycan/smart-parking-system/config"
+)
+
+// initializeSQLite opens (or creates) a local SQLite file and applies the
+// dialect-translated schema. This path is meant for single-binary dev/test
+// runs; it intentionally skips PostGIS-dependent features (see the GIST
+// geospatial index in initSchema) since SQLite has no equivalent extension
+// loaded by default.
+func initializeSQLite(explicitPath string) error {
+	path := explicitPath
+	if path == "" {
+		path = config.Ensure().DB.SQLitePath
+	}
+
+	if db != nil {
+		if err := db.Close(); err != nil {
+			log.Printf("Error closing existing database connection: %v", err)
+		}
+	}
+
+	log.Printf("Connecting to SQLite database: %s", path)
+	var err error
+	db, err = sql.Open("sqlite", path)
+	if err != nil {
+		log.Printf("Error opening SQLite database: %v", err)
+		return err
+	}
+
+	// SQLite allows only one writer at a time; a single connection avoids
+	// "database is locked" errors under concurrent access.
+	db.SetMaxOpenConns(1)
+
+	if err = db.Ping(); err != nil {
+		log.Printf("Error pinging SQLite database: %v", err)
+		return err
+	}
+
+	log.Println("SQLite database connection established successfully")
+	ActiveDriver = DriverSQLite
+	App = NewStore(db)
+
+	if err := initSchemaSQLite(db); err != nil {
+		log.Printf("Error initializing SQLite schema: %v", err)
+		return err
+	}
+
+	return nil
+}
+
+// initSchemaSQLite creates the dialect-translated equivalent of initSchema's
+// core tables. UUID becomes TEXT, TIMESTAMP WITH TIME ZONE becomes DATETIME,
+// and DECIMAL becomes REAL, matching how SQLite's type affinity works.
+func initSchemaSQLite(db *sql.DB) error {
+	statements := []string{
+		`CREATE TABLE IF NOT EXISTS users (
+			id TEXT PRIMARY KEY,
+			name TEXT NOT NULL,
+			email TEXT UNIQUE NOT NULL,
+			password TEXT NOT NULL,
+			phone TEXT,
+			role TEXT NOT NULL DEFAULT 'user',
+			is_verified INTEGER DEFAULT 0,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE TABLE IF NOT EXISTS parking_lots (
+			id TEXT PRIMARY KEY,
+			name TEXT NOT NULL,
+			address TEXT NOT NULL,
+			latitude REAL NOT NULL,
+			longitude REAL NOT NULL,
+			total_spaces INTEGER NOT NULL,
+			hourly_rate REAL NOT NULL,
+			is_open_24h INTEGER DEFAULT 0,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE TABLE IF NOT EXISTS parking_spaces (
+			id TEXT PRIMARY KEY,
+			parking_lot_id TEXT NOT NULL REFERENCES parking_lots(id),
+			space_number TEXT NOT NULL,
+			is_occupied INTEGER DEFAULT 0,
+			vehicle_type TEXT NOT NULL,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE(parking_lot_id, space_number)
+		)`,
+		`CREATE TABLE IF NOT EXISTS vehicles (
+			id TEXT PRIMARY KEY,
+			user_id TEXT NOT NULL REFERENCES users(id),
+			type TEXT NOT NULL,
+			license_plate TEXT NOT NULL,
+			brand TEXT NOT NULL,
+			model TEXT NOT NULL,
+			year INTEGER NOT NULL,
+			color TEXT NOT NULL,
+			is_default INTEGER DEFAULT 0,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE(user_id, license_plate)
+		)`,
+		`CREATE TABLE IF NOT EXISTS reservations (
+			id TEXT PRIMARY KEY,
+			user_id TEXT NOT NULL REFERENCES users(id),
+			parking_lot_id TEXT NOT NULL REFERENCES parking_lots(id),
+			parking_space_id TEXT NOT NULL REFERENCES parking_spaces(id),
+			vehicle_id TEXT REFERENCES vehicles(id),
+			vehicle_type TEXT,
+			license_plate TEXT,
+			reservation_date TEXT,
+			expired_at DATETIME,
+			checkin_time DATETIME,
+			duration INTEGER NOT NULL,
+			status TEXT NOT NULL DEFAULT 'pending',
+			total_cost REAL NOT NULL,
+			payment_status TEXT NOT NULL DEFAULT 'pending',
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE TABLE IF NOT EXISTS reservation_events (
+			id TEXT PRIMARY KEY,
+			reservation_id TEXT NOT NULL REFERENCES reservations(id),
+			actor_user_id TEXT,
+			previous_status TEXT,
+			new_status TEXT NOT NULL,
+			metadata TEXT,
+			occurred_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE TABLE IF NOT EXISTS payments (
+			id TEXT PRIMARY KEY,
+			reservation_id TEXT NOT NULL REFERENCES reservations(id),
+			amount REAL NOT NULL,
+			status TEXT NOT NULL DEFAULT 'pending',
+			payment_method TEXT,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE TABLE IF NOT EXISTS notifications (
+			id TEXT PRIMARY KEY,
+			user_id TEXT NOT NULL REFERENCES users(id),
+			title TEXT NOT NULL,
+			message TEXT NOT NULL,
+			is_read INTEGER DEFAULT 0,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)`,
+	}
+
+	for _, stmt := range statements {
+		if _, err := db.Exec(stmt); err != nil {
+			return fmt.Errorf("failed to apply sqlite schema statement: %w", err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,84 @@
+// Package errs classifies raw database/sql and pq errors into typed
+// sentinels so callers can react to, e.g., a duplicate key without parsing
+// the underlying driver's English error message.
+package errs
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/lib/pq"
+)
+
+// Sentinel classes for common PostgreSQL failure modes. Use errors.Is to
+// test for ErrNotFound, ErrCheckViolation, and ErrSerializationFailure;
+// ErrDuplicateKey and ErrForeignKeyViolation wrap the offending constraint
+// name and should be tested with errors.As.
+var (
+	ErrNotFound             = errors.New("record not found")
+	ErrCheckViolation       = errors.New("check constraint violation")
+	ErrSerializationFailure = errors.New("serialization failure, retry the transaction")
+)
+
+// DuplicateKeyError indicates a unique constraint was violated.
+type DuplicateKeyError struct {
+	Constraint string
+}
+
+func (e *DuplicateKeyError) Error() string {
+	return fmt.Sprintf("duplicate key violates constraint %q", e.Constraint)
+}
+
+// ForeignKeyViolationError indicates a foreign key constraint was violated.
+type ForeignKeyViolationError struct {
+	Constraint string
+}
+
+func (e *ForeignKeyViolationError) Error() string {
+	return fmt.Sprintf("foreign key violation on constraint %q", e.Constraint)
+}
+
+// ErrDuplicateKey returns a DuplicateKeyError for the given constraint name.
+func ErrDuplicateKey(constraint string) error {
+	return &DuplicateKeyError{Constraint: constraint}
+}
+
+// ErrForeignKeyViolation returns a ForeignKeyViolationError for the given constraint name.
+func ErrForeignKeyViolation(constraint string) error {
+	return &ForeignKeyViolationError{Constraint: constraint}
+}
+
+// Classify inspects err and returns the matching typed sentinel. Errors it
+// does not recognize are returned unchanged so callers can still fall back
+// to a generic 500.
+func Classify(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		switch pqErr.Code.Class() {
+		case "23": // Integrity Constraint Violation
+			switch pqErr.Code {
+			case "23505":
+				return ErrDuplicateKey(pqErr.Constraint)
+			case "23503":
+				return ErrForeignKeyViolation(pqErr.Constraint)
+			case "23514":
+				return ErrCheckViolation
+			}
+		case "40": // Transaction Rollback
+			if pqErr.Code == "40001" {
+				return ErrSerializationFailure
+			}
+		}
+	}
+
+	return err
+}
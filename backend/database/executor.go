@@ -0,0 +1,97 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync/atomic"
+)
+
+// Executor is satisfied by *sql.DB, *sql.Tx, and *Store (which embeds
+// *sql.DB), so repository methods can accept whichever the caller has in
+// hand — the pooled connection, an explicit transaction, or the Store
+// itself — through the same parameter, instead of reaching for the old
+// exported DB global directly.
+type Executor interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// Store wraps the pooled *sql.DB. Embedding it means every call site that
+// used to write database.DB.Query(...)/.Exec(...)/.Begin(...) keeps working
+// unchanged against database.App.Query(...) etc. — the only genuinely new
+// capability is WithTx, for code that needs more than one statement to
+// commit atomically.
+type Store struct {
+	*sql.DB
+}
+
+// NewStore wraps an already-open pool.
+func NewStore(db *sql.DB) *Store {
+	return &Store{DB: db}
+}
+
+// txKey marks the *sql.Tx stashed in a WithTx-derived context, so a nested
+// WithTx call can detect it's already inside a transaction.
+type txKey struct{}
+
+// savepointSeq names nested savepoints uniquely within a process; the
+// exact numbering doesn't matter; only uniqueness per open transaction does.
+var savepointSeq atomic.Uint64
+
+// WithTx runs fn inside a transaction, committing on success and rolling
+// back on error or panic. A WithTx call nested (directly or via a deeper
+// call chain) inside another WithTx reuses the outer transaction through a
+// savepoint instead of opening a second one, so service methods that call
+// each other don't need to know whether they're already inside a
+// transaction.
+func (s *Store) WithTx(ctx context.Context, fn func(ctx context.Context, exec Executor) error) (err error) {
+	if tx, ok := ctx.Value(txKey{}).(*sql.Tx); ok {
+		return withSavepoint(ctx, tx, fn)
+	}
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	ctx = context.WithValue(ctx, txKey{}, tx)
+
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		}
+		if err != nil {
+			tx.Rollback()
+			return
+		}
+		err = tx.Commit()
+	}()
+
+	err = fn(ctx, tx)
+	return err
+}
+
+func withSavepoint(ctx context.Context, tx *sql.Tx, fn func(ctx context.Context, exec Executor) error) (err error) {
+	name := fmt.Sprintf("sp_%d", savepointSeq.Add(1))
+	if _, err = tx.ExecContext(ctx, "SAVEPOINT "+name); err != nil {
+		return fmt.Errorf("failed to create savepoint %s: %w", name, err)
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT "+name)
+			panic(p)
+		}
+		if err != nil {
+			tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT "+name)
+			return
+		}
+		_, err = tx.ExecContext(ctx, "RELEASE SAVEPOINT "+name)
+	}()
+
+	err = fn(ctx, tx)
+	return err
+}
@@ -0,0 +1,74 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+
+	"github.com/pressly/goose/v3"
+
+	_ "github.com/holycan/smart-parking-system/database/migrations"
+)
+
+//go:embed migrations/*.sql
+var migrationsFS embed.FS
+
+// RequiredSchemaVersion is the highest migration this binary was built
+// against. CheckSchemaVersion refuses to serve traffic against a database
+// that hasn't been migrated at least this far, so a stale deploy fails
+// loudly at startup instead of issuing queries against columns that don't
+// exist yet.
+const RequiredSchemaVersion = 19
+
+func init() {
+	goose.SetBaseFS(migrationsFS)
+	if err := goose.SetDialect("postgres"); err != nil {
+		panic(fmt.Sprintf("database: failed to set goose dialect: %v", err))
+	}
+	// Use a name that reads like the rest of this schema rather than
+	// goose's default goose_db_version.
+	goose.SetTableName("schema_migrations")
+}
+
+// Migrate applies (or rolls back) the embedded migrations against db.
+// direction is one of "up", "down", or "status"; target is only consulted
+// by "up" and, when non-zero, limits how far goose advances (UpTo) instead
+// of running every pending migration.
+func Migrate(ctx context.Context, db *sql.DB, direction string, target int64) error {
+	switch direction {
+	case "up":
+		if target > 0 {
+			return goose.UpToContext(ctx, db, "migrations", target)
+		}
+		return goose.UpContext(ctx, db, "migrations")
+	case "down":
+		return goose.DownContext(ctx, db, "migrations")
+	case "status":
+		return goose.StatusContext(ctx, db, "migrations")
+	default:
+		return fmt.Errorf("database: unknown migrate direction %q", direction)
+	}
+}
+
+// CreateMigration scaffolds a new timestamped SQL migration file under
+// database/migrations on disk. Unlike Migrate, this doesn't touch the
+// database or the embedded FS (embed.FS is read-only at runtime) — it's a
+// developer-time convenience for starting the next migration.
+func CreateMigration(name string) error {
+	return goose.Create(nil, "migrations", name, "sql")
+}
+
+// CheckSchemaVersion fails startup if the database's applied migration
+// version is behind RequiredSchemaVersion, so an unmigrated database never
+// silently serves requests against a mismatched schema.
+func CheckSchemaVersion(ctx context.Context, db *sql.DB) error {
+	version, err := goose.GetDBVersionContext(ctx, db)
+	if err != nil {
+		return fmt.Errorf("database: failed to read schema version: %w", err)
+	}
+	if version < RequiredSchemaVersion {
+		return fmt.Errorf("database: schema version %d is behind required version %d; run migrate up", version, RequiredSchemaVersion)
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/pressly/goose/v3"
+)
+
+func init() {
+	goose.AddMigrationContext(upSeed, downSeed)
+}
+
+// upSeed inserts demo parking lots/spaces, but only when SEED_DATA=true so
+// production databases never get polluted with sample data on deploy.
+func upSeed(ctx context.Context, tx *sql.Tx) error {
+	if os.Getenv("SEED_DATA") != "true" {
+		return nil
+	}
+	return SeedSampleData(tx)
+}
+
+// SeedSampleData inserts sample parking lots/spaces for testing. It is safe
+// to call more than once: if parking_lots already has rows it does nothing.
+func SeedSampleData(tx *sql.Tx) error {
+	var count int
+	err := tx.QueryRow("SELECT COUNT(*) FROM parking_lots").Scan(&count)
+	if err != nil {
+		return err
+	}
+
+	if count > 0 {
+		log.Println("Sample data already exists, skipping insertion")
+		return nil
+	}
+
+	log.Println("Inserting sample data...")
+
+	_, err = tx.Exec(`
+		INSERT INTO parking_lots (id, name, address, city, state, zip_code, latitude, longitude, total_spaces, hourly_rate, open_time, close_time, is_open_24h, created_at, updated_at)
+		VALUES
+		('11111111-1111-1111-1111-111111111111', 'Downtown Parking', '123 Main St', 'Downtown', 'Selangor', '47500', 3.0319924, 101.373358, 50, 2.50, '00:00', '23:59', true, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP),
+		('22222222-2222-2222-2222-222222222222', 'Shopping Mall Parking', '456 Market Ave', 'Westside', 'Selangor', '47500', 3.0319924, 101.373358, 100, 1.50, '06:00', '22:00', false, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP),
+		('33333333-3333-3333-3333-333333333333', 'Airport Parking', '789 Airport Rd', 'Eastside', 'Selangor', '47500', 3.0319924, 101.373358, 200, 5.00, '00:00', '23:59', true, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+	`)
+	if err != nil {
+		return err
+	}
+
+	for i := 1; i <= 20; i++ {
+		_, err = tx.Exec(`
+			INSERT INTO parking_spaces (id, parking_lot_id, space_number, floor, type, is_occupied, last_updated, created_at, updated_at)
+			VALUES
+			($1, '11111111-1111-1111-1111-111111111111', $2, 1, 'standard', false, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+		`, fmt.Sprintf("1111%04d-1111-1111-1111-111111111111", i), fmt.Sprintf("A%d", i))
+		if err != nil {
+			return err
+		}
+	}
+
+	for i := 1; i <= 30; i++ {
+		_, err = tx.Exec(`
+			INSERT INTO parking_spaces (id, parking_lot_id, space_number, floor, type, is_occupied, last_updated, created_at, updated_at)
+			VALUES
+			($1, '22222222-2222-2222-2222-222222222222', $2, 1, 'standard', false, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+		`, fmt.Sprintf("2222%04d-2222-2222-2222-222222222222", i), fmt.Sprintf("B%d", i))
+		if err != nil {
+			return err
+		}
+	}
+
+	for i := 1; i <= 50; i++ {
+		_, err = tx.Exec(`
+			INSERT INTO parking_spaces (id, parking_lot_id, space_number, floor, type, is_occupied, last_updated, created_at, updated_at)
+			VALUES
+			($1, '33333333-3333-3333-3333-333333333333', $2, 1, 'standard', false, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+		`, fmt.Sprintf("3333%04d-3333-3333-3333-333333333333", i), fmt.Sprintf("C%d", i))
+		if err != nil {
+			return err
+		}
+	}
+
+	log.Println("Sample data inserted successfully")
+	return nil
+}
+
+func downSeed(ctx context.Context, tx *sql.Tx) error {
+	if os.Getenv("SEED_DATA") != "true" {
+		return nil
+	}
+	_, err := tx.Exec(`DELETE FROM parking_spaces WHERE parking_lot_id IN (
+		'11111111-1111-1111-1111-111111111111',
+		'22222222-2222-2222-2222-222222222222',
+		'33333333-3333-3333-3333-333333333333'
+	)`)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`DELETE FROM parking_lots WHERE id IN (
+		'11111111-1111-1111-1111-111111111111',
+		'22222222-2222-2222-2222-222222222222',
+		'33333333-3333-3333-3333-333333333333'
+	)`)
+	return err
+}
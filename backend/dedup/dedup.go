@@ -0,0 +1,98 @@
+// Package dedup recognizes events WebSocketManager has already delivered,
+// so a device retry or (once ws/broker relays it from another replica) a
+// duplicate fan-out doesn't get broadcast twice. A Redis SET/GET per event
+// would work too, but costs a round trip per event and grows without
+// bound; a rolling bloom filter costs neither, at the price of a small,
+// tunable false-positive rate.
+package dedup
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/bits-and-blooms/bloom/v3"
+)
+
+// defaultWindow is how long a fingerprint is remembered before it's
+// allowed to recur, absent an explicit WithDedupWindow.
+const defaultWindow = 5 * time.Minute
+
+// falsePositiveRate is the bloom filter's target false-positive rate -
+// the fraction of genuinely new events that get wrongly suppressed.
+const falsePositiveRate = 0.01
+
+// Deduper recognizes fingerprints it has already seen within a rolling
+// time window. It keeps two bloom filters, the current one and the
+// previous one, and rotates them every window: inserts always go into
+// the current filter, but queries check both, so a fingerprint seen near
+// the end of one window is still caught at the start of the next instead
+// of being forgotten the instant the window rolls over.
+type Deduper struct {
+	mu       sync.Mutex
+	window   time.Duration
+	expected uint
+	cur      *bloom.BloomFilter
+	prev     *bloom.BloomFilter
+	rotated  time.Time
+}
+
+// Option configures a Deduper constructed via New.
+type Option func(*Deduper)
+
+// WithDedupWindow overrides the default rotation window, so a test can
+// drive rotation deterministically instead of waiting on wall-clock time.
+func WithDedupWindow(window time.Duration) Option {
+	return func(d *Deduper) {
+		d.window = window
+	}
+}
+
+// New creates a Deduper whose bloom filters are sized via
+// bloom.NewWithEstimates for expectedEventsPerWindow events per rotation
+// at a 1% false-positive rate.
+func New(expectedEventsPerWindow uint, opts ...Option) *Deduper {
+	d := &Deduper{
+		window:   defaultWindow,
+		expected: expectedEventsPerWindow,
+		rotated:  time.Now(),
+	}
+	for _, opt := range opts {
+		opt(d)
+	}
+	d.cur = bloom.NewWithEstimates(d.expected, falsePositiveRate)
+	return d
+}
+
+// Seen reports whether fingerprint has already been recorded within the
+// current rolling window, recording it if not.
+func (d *Deduper) Seen(fingerprint string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.rotateIfDue()
+
+	if d.cur.TestString(fingerprint) || (d.prev != nil && d.prev.TestString(fingerprint)) {
+		return true
+	}
+	d.cur.AddString(fingerprint)
+	return false
+}
+
+func (d *Deduper) rotateIfDue() {
+	if time.Since(d.rotated) < d.window {
+		return
+	}
+	d.prev = d.cur
+	d.cur = bloom.NewWithEstimates(d.expected, falsePositiveRate)
+	d.rotated = time.Now()
+}
+
+// Fingerprint builds the dedup key for an event from its type, the
+// parking space and reservation it concerns (either may be empty, e.g. a
+// ParkingEvent has no reservation), and its timestamp rounded to the
+// second - fine-grained enough to tell distinct events apart, coarse
+// enough to collapse a device's sub-second retries onto the same key.
+func Fingerprint(eventType, parkingSpaceID, reservationID string, ts time.Time) string {
+	return fmt.Sprintf("%s|%s|%s|%d", eventType, parkingSpaceID, reservationID, ts.Unix())
+}
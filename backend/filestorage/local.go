@@ -0,0 +1,63 @@
+package filestorage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/holycan/smart-parking-system/config"
+)
+
+// localBackend is the default Backend: files live under basePath on this
+// process's own disk, one file per key, with nested directories created as
+// needed. SignedURL points back at handlers.ServeSignedFile instead of a
+// real object store's presigned-URL endpoint.
+type localBackend struct {
+	basePath      string
+	publicBaseURL string
+}
+
+func newLocalBackend(cfg config.FileStorageConfig) *localBackend {
+	return &localBackend{basePath: cfg.LocalBasePath, publicBaseURL: cfg.PublicBaseURL}
+}
+
+func (b *localBackend) Save(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	path := filepath.Join(b.basePath, key)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("filestorage: failed to create directory for %s: %w", key, err)
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("filestorage: failed to create %s: %w", key, err)
+	}
+	defer f.Close()
+
+	if _, err := io.Copy(f, r); err != nil {
+		return fmt.Errorf("filestorage: failed to write %s: %w", key, err)
+	}
+	return nil
+}
+
+func (b *localBackend) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	f, err := os.Open(filepath.Join(b.basePath, key))
+	if err != nil {
+		return nil, fmt.Errorf("filestorage: failed to open %s: %w", key, err)
+	}
+	return f, nil
+}
+
+func (b *localBackend) SignedURL(ctx context.Context, key string, ttl time.Duration) (string, error) {
+	expires := time.Now().Add(ttl).Unix()
+	return fmt.Sprintf("%s/files/%s?expires=%d&sig=%s", b.publicBaseURL, key, expires, signKey(key, expires)), nil
+}
+
+func (b *localBackend) Delete(ctx context.Context, key string) error {
+	if err := os.Remove(filepath.Join(b.basePath, key)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("filestorage: failed to delete %s: %w", key, err)
+	}
+	return nil
+}
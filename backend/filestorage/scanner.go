@@ -0,0 +1,27 @@
+package filestorage
+
+import (
+	"context"
+	"io"
+)
+
+// Scanner inspects an uploaded file for malware before handlers.
+// UploadReservationDocument marks its row clean. Implemented by NoopScanner
+// until a real engine (e.g. ClamAV over clamd's TCP protocol) is wired in.
+type Scanner interface {
+	Scan(ctx context.Context, r io.Reader) (clean bool, err error)
+}
+
+// NoopScanner always reports a file as clean. It exists so
+// UploadReservationDocument has something to call today without blocking
+// on antivirus integration, and so CurrentScanner can be swapped for a real
+// implementation later without touching the handler.
+type NoopScanner struct{}
+
+func (NoopScanner) Scan(ctx context.Context, r io.Reader) (bool, error) {
+	return true, nil
+}
+
+// CurrentScanner is the process-wide Scanner consulted by
+// handlers.UploadReservationDocument. Defaults to NoopScanner.
+var CurrentScanner Scanner = NoopScanner{}
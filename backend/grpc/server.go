@@ -0,0 +1,469 @@
+// Package grpc implements the ParkingService gRPC API defined in
+// proto/parking/v1/parking.proto. Server delegates to the same
+// services/ws packages the Gin handlers in handlers/ use, so the gate,
+// reservation, and notification behavior stays identical across both
+// transports instead of being re-derived here.
+package grpc
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"github.com/google/uuid"
+	googlegrpc "google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/holycan/smart-parking-system/database"
+	"github.com/holycan/smart-parking-system/handlers"
+	"github.com/holycan/smart-parking-system/models"
+	parkingv1 "github.com/holycan/smart-parking-system/proto/parking/v1"
+	"github.com/holycan/smart-parking-system/queue"
+	"github.com/holycan/smart-parking-system/services"
+	"github.com/holycan/smart-parking-system/services/audit"
+	"github.com/holycan/smart-parking-system/utils"
+)
+
+// Server implements parkingv1.ParkingServiceServer.
+type Server struct {
+	parkingv1.UnimplementedParkingServiceServer
+}
+
+// NewGRPCServer constructs a *googlegrpc.Server with Server registered,
+// reflection enabled when cfg asks for it, matching main.go's
+// config-gated startup for the other long-running components.
+func NewGRPCServer(reflectionEnabled bool) *googlegrpc.Server {
+	s := googlegrpc.NewServer()
+	parkingv1.RegisterParkingServiceServer(s, &Server{})
+	if reflectionEnabled {
+		reflection.Register(s)
+	}
+	return s
+}
+
+// userIDFromContext reads the user id gRPC middleware (an auth
+// interceptor mirroring middleware.AuthMiddleware) is expected to attach
+// to the context before these handlers run.
+func userIDFromContext(ctx context.Context) (string, error) {
+	userID, _ := ctx.Value(userIDContextKey).(string)
+	if userID == "" {
+		return "", status.Error(codes.Unauthenticated, "user not authenticated")
+	}
+	return userID, nil
+}
+
+type contextKey string
+
+const userIDContextKey contextKey = "userId"
+
+// CheckIn mirrors handlers.CheckInHandler: it enqueues the request onto
+// the durable check-in queue and returns immediately. The actual spot
+// assignment happens asynchronously in handlers.ProcessCheckIn.
+func (s *Server) CheckIn(ctx context.Context, req *parkingv1.CheckInRequest) (*parkingv1.CheckInResponse, error) {
+	userID, err := userIDFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	booking, err := services.GetBookingByID(req.ReservationId, userID)
+	if err != nil || booking == nil {
+		return nil, status.Error(codes.NotFound, "booking not found")
+	}
+
+	if err := queue.Enqueue(ctx, queue.CheckInJob{
+		UserID:        userID,
+		ReservationID: req.ReservationId,
+		ParkingLotID:  booking.ParkingLotID,
+		OriginLat:     req.OriginLat,
+		OriginLng:     req.OriginLng,
+	}); err != nil {
+		log.Printf("grpc: failed to enqueue check-in for reservation %s: %v", req.ReservationId, err)
+		return nil, status.Error(codes.Internal, "failed to queue check-in request")
+	}
+
+	return &parkingv1.CheckInResponse{Message: "Check-in request accepted and being processed"}, nil
+}
+
+// CheckOut mirrors handlers.CheckOutHandler.
+func (s *Server) CheckOut(ctx context.Context, req *parkingv1.CheckOutRequest) (*parkingv1.CheckOutResponse, error) {
+	userID, err := userIDFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	booking, err := services.GetBookingByID(req.ReservationId, userID)
+	if err != nil || booking == nil {
+		return nil, status.Error(codes.NotFound, "booking not found")
+	}
+
+	if _, err := services.UpdateBookingWithSpot("completed", userID, req.ReservationId,
+		booking.ParkingLotID, booking.ParkingSpaceID); err != nil {
+		log.Printf("grpc: failed to update booking %s: %v", req.ReservationId, err)
+		return nil, status.Error(codes.Internal, "failed to update booking")
+	}
+
+	if err := services.UpdateParkingSpaceOccupied(false, booking.ParkingSpaceID); err != nil {
+		log.Printf("grpc: failed to update parking space %s: %v", booking.ParkingSpaceID, err)
+		return nil, status.Error(codes.Internal, "failed to update parking space status")
+	}
+
+	if err := audit.RecordEvent(database.App, req.ReservationId, userID, booking.Status, "checked_out", nil); err != nil {
+		log.Printf("grpc: failed to record check-out event: %v", err)
+	}
+
+	handlers.NotifyParkingUpdate(booking.ParkingLotID, booking.ParkingSpaceID, false, false)
+	if err := handlers.NotifySpaceAvailability(booking.ParkingSpaceID); err != nil {
+		log.Printf("grpc: failed to send availability notification: %v", err)
+	}
+
+	return &parkingv1.CheckOutResponse{Message: "Check-out processed successfully"}, nil
+}
+
+// Payment mirrors handlers.PaymentHandler.
+func (s *Server) Payment(ctx context.Context, req *parkingv1.PaymentRequest) (*parkingv1.PaymentResponse, error) {
+	userID, err := userIDFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	booking, err := services.GetBookingByID(req.ReservationId, userID)
+	if err != nil || booking == nil {
+		return nil, status.Error(codes.NotFound, "booking not found")
+	}
+
+	if err := services.UpdateBookingPaymentStatus("completed", req.ReservationId); err != nil {
+		log.Printf("grpc: failed to update payment status for booking %s: %v", req.ReservationId, err)
+		return nil, status.Error(codes.Internal, "failed to update booking")
+	}
+
+	handlers.NotifyParkingUpdate(booking.ParkingLotID, booking.ParkingSpaceID, true, true)
+
+	return &parkingv1.PaymentResponse{Message: "Payment completed successfully"}, nil
+}
+
+// CreateReservation mirrors handlers.CreateReservation's insert, minus
+// the vehicle-garage hydration and WebSocket broadcast, which are left to
+// a later request if the gRPC surface needs full parity there.
+func (s *Server) CreateReservation(ctx context.Context, req *parkingv1.CreateReservationRequest) (*parkingv1.Reservation, error) {
+	userID, err := userIDFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var spaceExists bool
+	if err := database.App.QueryRow(
+		"SELECT EXISTS(SELECT 1 FROM parking_spaces WHERE id = $1 AND parking_lot_id = $2)",
+		req.ParkingSpaceId, req.ParkingLotId,
+	).Scan(&spaceExists); err != nil {
+		return nil, status.Error(codes.Internal, "failed to validate parking space")
+	}
+	if !spaceExists {
+		return nil, status.Error(codes.InvalidArgument, "invalid parking space or parking lot")
+	}
+
+	reservationID := uuid.New().String()
+	var vehicleID sql.NullString
+	if req.VehicleId != "" {
+		vehicleID = sql.NullString{String: req.VehicleId, Valid: true}
+	}
+
+	tx, err := database.App.Begin()
+	if err != nil {
+		return nil, status.Error(codes.Internal, "failed to create reservation")
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO reservations (
+			id, user_id, parking_lot_id, parking_space_id, vehicle_id, vehicle_type, license_plate, reservation_date,
+			duration, status, total_cost, payment_status, created_at
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, now())
+	`,
+		reservationID, userID, req.ParkingLotId, req.ParkingSpaceId, vehicleID, req.VehicleType, req.LicensePlate,
+		req.ReservationDate, req.Duration, "pending", req.TotalCost, "pending",
+	)
+	if err != nil {
+		tx.Rollback()
+		log.Printf("grpc: failed to insert reservation: %v", err)
+		return nil, status.Error(codes.Internal, "failed to create reservation")
+	}
+
+	if err := audit.RecordEvent(tx, reservationID, userID, "", "created", map[string]interface{}{
+		"parkingLotId":   req.ParkingLotId,
+		"parkingSpaceId": req.ParkingSpaceId,
+	}); err != nil {
+		tx.Rollback()
+		return nil, status.Error(codes.Internal, "failed to create reservation")
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, status.Error(codes.Internal, "failed to create reservation")
+	}
+
+	return s.GetReservation(ctx, &parkingv1.GetReservationRequest{ReservationId: reservationID})
+}
+
+// GetReservation looks up a reservation owned by the authenticated user.
+func (s *Server) GetReservation(ctx context.Context, req *parkingv1.GetReservationRequest) (*parkingv1.Reservation, error) {
+	userID, err := userIDFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	reservation, err := services.GetBookingByID(req.ReservationId, userID)
+	if err != nil || reservation == nil {
+		return nil, status.Error(codes.NotFound, "reservation not found")
+	}
+
+	return toProtoReservation(reservation), nil
+}
+
+// ListReservations mirrors handlers.GetUserReservations.
+func (s *Server) ListReservations(ctx context.Context, req *parkingv1.ListReservationsRequest) (*parkingv1.ListReservationsResponse, error) {
+	userID, err := userIDFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := database.App.Query(`
+		SELECT id, user_id, parking_lot_id, parking_space_id, vehicle_type, license_plate,
+		       reservation_date, duration, status, total_cost, payment_status, created_at, updated_at
+		FROM reservations
+		WHERE user_id = $1
+		ORDER BY created_at ASC
+	`, userID)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "failed to list reservations")
+	}
+	defer rows.Close()
+
+	resp := &parkingv1.ListReservationsResponse{}
+	for rows.Next() {
+		var r models.Reservation
+		if err := rows.Scan(
+			&r.ID, &r.UserID, &r.ParkingLotID, &r.ParkingSpaceID, &r.VehicleType, &r.LicensePlate,
+			&r.ReservationDate, &r.Duration, &r.Status, &r.TotalCost, &r.PaymentStatus, &r.CreatedAt, &r.UpdatedAt,
+		); err != nil {
+			log.Printf("grpc: failed to scan reservation row: %v", err)
+			continue
+		}
+		resp.Reservations = append(resp.Reservations, toProtoReservation(&r))
+	}
+
+	return resp, rows.Err()
+}
+
+// CancelReservation mirrors handlers.CancelReservation's ownership check
+// and status transition.
+func (s *Server) CancelReservation(ctx context.Context, req *parkingv1.CancelReservationRequest) (*parkingv1.CancelReservationResponse, error) {
+	userID, err := userIDFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var previousStatus string
+	err = database.App.QueryRow(`
+		SELECT status FROM reservations
+		WHERE id = $1 AND user_id = $2 AND status != 'cancelled'
+	`, req.ReservationId, userID).Scan(&previousStatus)
+	if err == sql.ErrNoRows {
+		return nil, status.Error(codes.NotFound, "reservation not found or already cancelled")
+	}
+	if err != nil {
+		return nil, status.Error(codes.Internal, "failed to cancel reservation")
+	}
+
+	tx, err := database.App.Begin()
+	if err != nil {
+		return nil, status.Error(codes.Internal, "failed to cancel reservation")
+	}
+
+	if _, err := tx.Exec(
+		"UPDATE reservations SET status = 'cancelled', updated_at = now() WHERE id = $1",
+		req.ReservationId,
+	); err != nil {
+		tx.Rollback()
+		return nil, status.Error(codes.Internal, "failed to cancel reservation")
+	}
+
+	if err := audit.RecordEvent(tx, req.ReservationId, userID, previousStatus, "cancelled", nil); err != nil {
+		tx.Rollback()
+		return nil, status.Error(codes.Internal, "failed to cancel reservation")
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, status.Error(codes.Internal, "failed to cancel reservation")
+	}
+
+	return &parkingv1.CancelReservationResponse{Message: "Reservation cancelled successfully"}, nil
+}
+
+// GetParkingLot mirrors handlers.GetParkingLotByID.
+func (s *Server) GetParkingLot(ctx context.Context, req *parkingv1.GetParkingLotRequest) (*parkingv1.ParkingLot, error) {
+	var lot models.ParkingLot
+	err := database.App.QueryRow(
+		"SELECT id, name, address, city, latitude, longitude, total_spaces, hourly_rate FROM parking_lots WHERE id = $1",
+		req.ParkingLotId,
+	).Scan(&lot.ID, &lot.Name, &lot.Address, &lot.City, &lot.Latitude, &lot.Longitude, &lot.TotalSpaces, &lot.HourlyRate)
+	if err == sql.ErrNoRows {
+		return nil, status.Error(codes.NotFound, "parking lot not found")
+	}
+	if err != nil {
+		return nil, status.Error(codes.Internal, "failed to fetch parking lot")
+	}
+
+	return toProtoParkingLot(&lot), nil
+}
+
+// ListParkingLots mirrors handlers.GetParkingLots' unfiltered, paginated
+// listing.
+func (s *Server) ListParkingLots(ctx context.Context, req *parkingv1.ListParkingLotsRequest) (*parkingv1.ListParkingLotsResponse, error) {
+	page, pageSize := req.Page, req.PageSize
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 {
+		pageSize = 20
+	}
+
+	var total int32
+	if err := database.App.QueryRow("SELECT COUNT(*) FROM parking_lots").Scan(&total); err != nil {
+		return nil, status.Error(codes.Internal, "failed to count parking lots")
+	}
+
+	rows, err := database.App.Query(
+		"SELECT id, name, address, city, latitude, longitude, total_spaces, hourly_rate FROM parking_lots ORDER BY name ASC LIMIT $1 OFFSET $2",
+		pageSize, (page-1)*pageSize,
+	)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "failed to list parking lots")
+	}
+	defer rows.Close()
+
+	resp := &parkingv1.ListParkingLotsResponse{Total: total}
+	for rows.Next() {
+		var lot models.ParkingLot
+		if err := rows.Scan(&lot.ID, &lot.Name, &lot.Address, &lot.City, &lot.Latitude, &lot.Longitude, &lot.TotalSpaces, &lot.HourlyRate); err != nil {
+			log.Printf("grpc: failed to scan parking lot row: %v", err)
+			continue
+		}
+		resp.ParkingLots = append(resp.ParkingLots, toProtoParkingLot(&lot))
+	}
+
+	return resp, rows.Err()
+}
+
+// GetParkingSpace mirrors handlers.GetParkingSpaceByID.
+func (s *Server) GetParkingSpace(ctx context.Context, req *parkingv1.GetParkingSpaceRequest) (*parkingv1.ParkingSpace, error) {
+	var space models.ParkingSpace
+	err := database.App.QueryRow(
+		"SELECT id, parking_lot_id, space_number, floor, type, is_occupied FROM parking_spaces WHERE id = $1",
+		req.ParkingSpaceId,
+	).Scan(&space.ID, &space.ParkingLotID, &space.SpaceNumber, &space.Floor, &space.Type, &space.IsOccupied)
+	if err == sql.ErrNoRows {
+		return nil, status.Error(codes.NotFound, "parking space not found")
+	}
+	if err != nil {
+		return nil, status.Error(codes.Internal, "failed to fetch parking space")
+	}
+
+	return &parkingv1.ParkingSpace{
+		Id:           space.ID,
+		ParkingLotId: space.ParkingLotID,
+		SpaceNumber:  space.SpaceNumber,
+		Floor:        int32(space.Floor),
+		Type:         space.Type,
+		IsOccupied:   space.IsOccupied,
+	}, nil
+}
+
+// NotificationStream registers a synthetic ws.Client for the caller's
+// user id with utils.WsManager and forwards every message it receives
+// until the stream is cancelled, mirroring the "notification" channel a
+// WebSocket client subscribes to implicitly at /ws.
+func (s *Server) NotificationStream(req *parkingv1.NotificationStreamRequest, stream parkingv1.ParkingService_NotificationStreamServer) error {
+	userID, err := userIDFromContext(stream.Context())
+	if err != nil {
+		return err
+	}
+
+	client := utils.WsManager.RegisterNotificationClient(userID)
+	defer utils.WsManager.UnregisterClient(client)
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			return stream.Context().Err()
+		case msg, ok := <-client.Send:
+			if !ok {
+				return nil
+			}
+			event, err := parseNotificationMessage(msg)
+			if err != nil {
+				log.Printf("grpc: dropping unparseable notification message: %v", err)
+				continue
+			}
+			if err := stream.Send(event); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func toProtoReservation(r *models.Reservation) *parkingv1.Reservation {
+	return &parkingv1.Reservation{
+		Id:              r.ID,
+		UserId:          r.UserID,
+		ParkingLotId:    r.ParkingLotID,
+		ParkingSpaceId:  r.ParkingSpaceID,
+		VehicleType:     r.VehicleType,
+		LicensePlate:    r.LicensePlate,
+		ReservationDate: r.ReservationDate.Format("2006-01-02"),
+		Status:          r.Status,
+		TotalCost:       r.TotalCost,
+		PaymentStatus:   r.PaymentStatus,
+		CreatedAt:       timestamppb.New(r.CreatedAt),
+		UpdatedAt:       timestamppb.New(r.UpdatedAt),
+	}
+}
+
+func toProtoParkingLot(l *models.ParkingLot) *parkingv1.ParkingLot {
+	return &parkingv1.ParkingLot{
+		Id:          l.ID,
+		Name:        l.Name,
+		Address:     l.Address,
+		City:        l.City,
+		Latitude:    l.Latitude,
+		Longitude:   l.Longitude,
+		TotalSpaces: int32(l.TotalSpaces),
+		HourlyRate:  l.HourlyRate,
+	}
+}
+
+// parseNotificationMessage unwraps the ws.WebSocketMessage JSON envelope
+// handleNotificationUpdate writes onto client.Send into a
+// parkingv1.NotificationEvent.
+func parseNotificationMessage(raw []byte) (*parkingv1.NotificationEvent, error) {
+	var envelope struct {
+		Type    string                   `json:"type"`
+		Payload models.NotificationEvent `json:"payload"`
+	}
+	if err := json.Unmarshal(raw, &envelope); err != nil {
+		return nil, fmt.Errorf("grpc: failed to unmarshal notification message: %w", err)
+	}
+
+	p := envelope.Payload
+	return &parkingv1.NotificationEvent{
+		NotificationId: p.NotificationID,
+		UserId:         p.UserID,
+		ParkingSpaceId: p.ParkingSpaceId,
+		ReservationId:  p.ReservationId,
+		Type:           p.Type,
+		Message:        p.Message,
+		CreatedAt:      timestamppb.New(p.CreatedAt),
+	}, nil
+}
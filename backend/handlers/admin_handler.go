@@ -0,0 +1,278 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/holycan/smart-parking-system/auth/rbac"
+	"github.com/holycan/smart-parking-system/database"
+	"github.com/holycan/smart-parking-system/jobs"
+	"github.com/holycan/smart-parking-system/services"
+	"github.com/holycan/smart-parking-system/services/audit"
+)
+
+// ListJobs returns every registered background job's schedule, last run,
+// next run, and last error. Access is enforced by
+// middleware.RequirePermission("admin:jobs:read") on the route itself.
+func ListJobs(c *gin.Context) {
+	if jobs.Current == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Job scheduler is not running"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"jobs": jobs.Current.Statuses()})
+}
+
+// ListUserRoles returns the role names currently assigned to :id.
+func ListUserRoles(c *gin.Context) {
+	targetUserID := c.Param("id")
+
+	roles, err := rbac.ListUserRoles(targetUserID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list roles"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"roles": roles})
+}
+
+// GrantUserRole assigns a role to :id, recording the authenticated caller
+// as the grantor.
+func GrantUserRole(c *gin.Context) {
+	targetUserID := c.Param("id")
+
+	var req struct {
+		Role string `json:"role" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	actorUserID, _ := c.Get("userId")
+	if err := rbac.GrantRole(actorUserID.(string), targetUserID, req.Role); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Role granted successfully"})
+}
+
+// RevokeUserRole removes a role from :id, recording the authenticated
+// caller as the revoker.
+func RevokeUserRole(c *gin.Context) {
+	targetUserID := c.Param("id")
+
+	var req struct {
+		Role string `json:"role" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	actorUserID, _ := c.Get("userId")
+	if err := rbac.RevokeRole(actorUserID.(string), targetUserID, req.Role); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Role revoked successfully"})
+}
+
+// ForceCheckoutReservation ends :id's check-in on an operator's behalf -
+// e.g. a driver who left without using the app - freeing the parking
+// space the same way CheckOutHandler does for a self-service checkout.
+func ForceCheckoutReservation(c *gin.Context) {
+	reservationID := c.Param("id")
+	actorUserID, _ := c.Get("userId")
+
+	booking, err := services.GetBookingByID(reservationID, "")
+	if err != nil || booking == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Reservation not found"})
+		return
+	}
+
+	before := map[string]interface{}{"status": booking.Status}
+
+	if _, err := services.UpdateBookingWithSpot("completed", booking.UserID, reservationID, booking.ParkingLotID, booking.ParkingSpaceID); err != nil {
+		log.Printf("Failed to force-checkout reservation %s: %v", reservationID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to force checkout"})
+		return
+	}
+
+	if err := services.UpdateParkingSpaceOccupied(false, booking.ParkingSpaceID); err != nil {
+		log.Printf("Failed to free parking space %s: %v", booking.ParkingSpaceID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update parking space status"})
+		return
+	}
+
+	if err := audit.RecordEvent(database.App, reservationID, actorUserID.(string), booking.Status, "checked_out", map[string]interface{}{"forced": true}); err != nil {
+		log.Printf("Failed to record force-checkout event: %v", err)
+	}
+	if err := audit.RecordAdminAction(database.App, actorUserID.(string), c.ClientIP(), "force_checkout", reservationID, before, map[string]interface{}{"status": "completed"}); err != nil {
+		log.Printf("Failed to record admin action: %v", err)
+	}
+
+	NotifyParkingUpdate(booking.ParkingLotID, booking.ParkingSpaceID, false, false)
+	if err := NotifySpaceAvailability(booking.ParkingSpaceID); err != nil {
+		log.Printf("Warning: Failed to send availability notification: %v", err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Reservation force-checked-out successfully"})
+}
+
+// BlockParkingSpace takes :id out of rotation for maintenance, so it stops
+// being offered by FindAndLockAvailableSpot without looking like it's
+// simply occupied by a vehicle.
+func BlockParkingSpace(c *gin.Context) {
+	spaceID := c.Param("id")
+	actorUserID, _ := c.Get("userId")
+
+	var req struct {
+		Reason string `json:"reason" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	result, err := database.App.Exec(
+		"UPDATE parking_spaces SET is_blocked = true, blocked_reason = $1, updated_at = NOW() WHERE id = $2",
+		req.Reason, spaceID,
+	)
+	if err != nil {
+		log.Printf("Failed to block parking space %s: %v", spaceID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to block parking space"})
+		return
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Parking space not found"})
+		return
+	}
+
+	if err := audit.RecordAdminAction(database.App, actorUserID.(string), c.ClientIP(), "block_spot", spaceID, nil, map[string]interface{}{"isBlocked": true, "reason": req.Reason}); err != nil {
+		log.Printf("Failed to record admin action: %v", err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Parking space blocked"})
+}
+
+// UnblockParkingSpace returns :id to rotation after maintenance.
+func UnblockParkingSpace(c *gin.Context) {
+	spaceID := c.Param("id")
+	actorUserID, _ := c.Get("userId")
+
+	result, err := database.App.Exec(
+		"UPDATE parking_spaces SET is_blocked = false, blocked_reason = NULL, updated_at = NOW() WHERE id = $1",
+		spaceID,
+	)
+	if err != nil {
+		log.Printf("Failed to unblock parking space %s: %v", spaceID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unblock parking space"})
+		return
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Parking space not found"})
+		return
+	}
+
+	if err := audit.RecordAdminAction(database.App, actorUserID.(string), c.ClientIP(), "unblock_spot", spaceID, map[string]interface{}{"isBlocked": true}, map[string]interface{}{"isBlocked": false}); err != nil {
+		log.Printf("Failed to record admin action: %v", err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Parking space unblocked"})
+}
+
+// OverrideReservationSpot reassigns :id to a different parking space than
+// the one it currently holds - e.g. correcting a support agent's manual
+// re-park - freeing the old space and marking the new one occupied.
+func OverrideReservationSpot(c *gin.Context) {
+	reservationID := c.Param("id")
+	actorUserID, _ := c.Get("userId")
+
+	var req struct {
+		ParkingSpaceID string `json:"parkingSpaceId" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	booking, err := services.GetBookingByID(reservationID, "")
+	if err != nil || booking == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Reservation not found"})
+		return
+	}
+
+	occupied, err := services.IsParkingSpaceOccupied(req.ParkingSpaceID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Target parking space not found"})
+		return
+	}
+	if occupied {
+		c.JSON(http.StatusConflict, gin.H{"error": "Target parking space is already occupied"})
+		return
+	}
+
+	if _, err := database.App.Exec(
+		"UPDATE reservations SET parking_space_id = $1, updated_at = NOW() WHERE id = $2",
+		req.ParkingSpaceID, reservationID,
+	); err != nil {
+		log.Printf("Failed to override spot for reservation %s: %v", reservationID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reassign parking space"})
+		return
+	}
+
+	if err := services.UpdateParkingSpaceOccupied(false, booking.ParkingSpaceID); err != nil {
+		log.Printf("Failed to free previous parking space %s: %v", booking.ParkingSpaceID, err)
+	}
+	if err := services.UpdateParkingSpaceOccupied(true, req.ParkingSpaceID); err != nil {
+		log.Printf("Failed to mark new parking space %s occupied: %v", req.ParkingSpaceID, err)
+	}
+
+	if err := audit.RecordAdminAction(database.App, actorUserID.(string), c.ClientIP(), "override_spot", reservationID,
+		map[string]interface{}{"parkingSpaceId": booking.ParkingSpaceID},
+		map[string]interface{}{"parkingSpaceId": req.ParkingSpaceID},
+	); err != nil {
+		log.Printf("Failed to record admin action: %v", err)
+	}
+
+	NotifyParkingUpdate(booking.ParkingLotID, booking.ParkingSpaceID, false, false)
+	NotifyParkingUpdate(booking.ParkingLotID, req.ParkingSpaceID, true, false)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Reservation reassigned to new parking space"})
+}
+
+// GetLiveOccupancy returns :id's current occupied/blocked/available space
+// counts, queried directly against parking_spaces rather than the
+// materialized parking_lot_occupancy_stats view jobs.refreshOccupancyStats
+// refreshes every 10 minutes - that's too stale for an admin dashboard
+// that wants to know what's true right now.
+func GetLiveOccupancy(c *gin.Context) {
+	parkingLotID := c.Param("id")
+
+	var total, occupied, blocked int
+	err := database.App.QueryRow(`
+		SELECT COUNT(*),
+		       COUNT(CASE WHEN is_occupied THEN 1 END),
+		       COUNT(CASE WHEN is_blocked THEN 1 END)
+		FROM parking_spaces
+		WHERE parking_lot_id = $1
+	`, parkingLotID).Scan(&total, &occupied, &blocked)
+	if err != nil {
+		log.Printf("Failed to compute live occupancy for lot %s: %v", parkingLotID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compute live occupancy"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"parkingLotId":   parkingLotID,
+		"totalSpaces":    total,
+		"occupiedSpaces": occupied,
+		"blockedSpaces":  blocked,
+		"availableSpaces": total - occupied - blocked,
+	})
+}
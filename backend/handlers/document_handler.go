@@ -0,0 +1,215 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/holycan/smart-parking-system/config"
+	"github.com/holycan/smart-parking-system/database"
+	"github.com/holycan/smart-parking-system/filestorage"
+	"github.com/holycan/smart-parking-system/models"
+)
+
+var validDocumentTypes = map[string]bool{
+	models.DocumentTypePermit:     true,
+	models.DocumentTypeDisability: true,
+	models.DocumentTypeEVAuth:     true,
+}
+
+// UploadReservationDocument handles POST /api/reservations/:id/documents,
+// streaming a multipart file upload (permit, disability card, or EV
+// charging authorization) into filestorage.Current after validating its
+// size, mime type, and ownership of the reservation it attaches to.
+func UploadReservationDocument(c *gin.Context) {
+	userID, exists := c.Get("userId")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	reservationID := c.Param("id")
+
+	if owned, err := userOwnsReservation(reservationID, userID.(string)); err != nil {
+		log.Printf("Error checking reservation ownership: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to validate reservation"})
+		return
+	} else if !owned {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Reservation not found"})
+		return
+	}
+
+	docType := c.PostForm("type")
+	if !validDocumentTypes[docType] {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "type must be one of permit, disability_card, ev_charging_authorization"})
+		return
+	}
+
+	cfg := config.Get().FileStorage
+	maxSize := cfg.MaxUploadSizeMB * 1024 * 1024
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxSize)
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "file is required"})
+		return
+	}
+	if fileHeader.Size > maxSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("file exceeds the %dMB limit", cfg.MaxUploadSizeMB)})
+		return
+	}
+
+	contentType := fileHeader.Header.Get("Content-Type")
+	if !allowedMimeType(contentType, cfg.AllowedMimeTypes) {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "unsupported file type"})
+		return
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		log.Printf("Error opening uploaded file: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read uploaded file"})
+		return
+	}
+	defer file.Close()
+
+	clean, err := filestorage.CurrentScanner.Scan(c.Request.Context(), file)
+	if err != nil {
+		log.Printf("Error scanning uploaded file: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to scan uploaded file"})
+		return
+	}
+	if !clean {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "file failed the virus scan"})
+		return
+	}
+	if _, err := file.Seek(0, 0); err != nil {
+		log.Printf("Error rewinding uploaded file: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process uploaded file"})
+		return
+	}
+
+	docID := uuid.New().String()
+	fileKey := fmt.Sprintf("reservations/%s/%s", reservationID, docID)
+
+	if err := filestorage.Current.Save(c.Request.Context(), fileKey, file, fileHeader.Size, contentType); err != nil {
+		log.Printf("Error saving uploaded file: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store uploaded file"})
+		return
+	}
+
+	doc := models.ReservationDocument{
+		ID:               docID,
+		ReservationID:    reservationID,
+		UserID:           userID.(string),
+		Type:             docType,
+		FileKey:          fileKey,
+		OriginalFilename: fileHeader.Filename,
+		ContentType:      contentType,
+		SizeBytes:        fileHeader.Size,
+		ScanStatus:       "clean",
+		CreatedAt:        time.Now(),
+	}
+
+	_, err = database.App.Exec(`
+		INSERT INTO reservation_documents (id, reservation_id, user_id, type, file_key, original_filename, content_type, size_bytes, scan_status, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+	`, doc.ID, doc.ReservationID, doc.UserID, doc.Type, doc.FileKey, doc.OriginalFilename, doc.ContentType, doc.SizeBytes, doc.ScanStatus, doc.CreatedAt)
+	if err != nil {
+		log.Printf("Error recording uploaded document: %v", err)
+		if delErr := filestorage.Current.Delete(c.Request.Context(), fileKey); delErr != nil {
+			log.Printf("Error cleaning up orphaned file %s: %v", fileKey, delErr)
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record uploaded document"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"document": doc})
+}
+
+// DownloadReservationDocument handles GET /api/reservations/:id/documents/:docID,
+// returning a signed, short-lived URL the client can use to fetch the
+// document directly from filestorage.Current rather than proxying the
+// bytes through this handler.
+func DownloadReservationDocument(c *gin.Context) {
+	userID, exists := c.Get("userId")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	reservationID := c.Param("id")
+	docID := c.Param("docID")
+
+	var fileKey string
+	err := database.App.QueryRow(`
+		SELECT file_key FROM reservation_documents
+		WHERE id = $1 AND reservation_id = $2 AND user_id = $3
+	`, docID, reservationID, userID).Scan(&fileKey)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Document not found"})
+			return
+		}
+		log.Printf("Error fetching document: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch document"})
+		return
+	}
+
+	ttl := time.Duration(config.Get().FileStorage.SignedURLTTLSeconds) * time.Second
+	url, err := filestorage.Current.SignedURL(c.Request.Context(), fileKey, ttl)
+	if err != nil {
+		log.Printf("Error generating signed URL: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate download URL"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"url": url, "expiresInSeconds": config.Get().FileStorage.SignedURLTTLSeconds})
+}
+
+// ServeSignedFile handles GET /files/*filepath, the local filestorage
+// backend's stand-in for an object store's presigned-GET endpoint. It's
+// deliberately outside AuthMiddleware — the signature and expiry in the
+// query string are the access control, the same capability-URL model the
+// S3/MinIO backend uses natively.
+func ServeSignedFile(c *gin.Context) {
+	key := strings.TrimPrefix(c.Param("filepath"), "/")
+	expires, err := strconv.ParseInt(c.Query("expires"), 10, 64)
+	if err != nil || !filestorage.VerifySignedKey(key, expires, c.Query("sig")) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "invalid or expired signature"})
+		return
+	}
+
+	f, err := filestorage.Current.Open(c.Request.Context(), key)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "file not found"})
+		return
+	}
+	defer f.Close()
+
+	c.DataFromReader(http.StatusOK, -1, "application/octet-stream", f, nil)
+}
+
+// userOwnsReservation reports whether reservationID belongs to userID.
+func userOwnsReservation(reservationID, userID string) (bool, error) {
+	var exists bool
+	err := database.App.QueryRow(
+		"SELECT EXISTS(SELECT 1 FROM reservations WHERE id = $1 AND user_id = $2)",
+		reservationID, userID,
+	).Scan(&exists)
+	return exists, err
+}
+
+func allowedMimeType(contentType string, allowed []string) bool {
+	for _, a := range allowed {
+		if strings.EqualFold(strings.TrimSpace(a), contentType) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"context"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/holycan/smart-parking-system/config"
+	"github.com/holycan/smart-parking-system/services"
+)
+
+// ListBookingEvents returns a booking's full parking_events history in
+// occurred_at order, for GET /admin/events?booking_id=....
+func ListBookingEvents(c *gin.Context) {
+	bookingID := c.Query("booking_id")
+	if bookingID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "booking_id is required"})
+		return
+	}
+
+	events, err := services.ListEventsForBooking(c.Request.Context(), bookingID)
+	if err != nil {
+		log.Printf("Failed to list events for booking %s: %v", bookingID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load events"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"events": events})
+}
+
+// StreamEvents streams every parking_events row recorded from here on as
+// Server-Sent Events, for an operator's live incident feed. Same
+// idle-deadline/keepalive shape as GetParkingSpacesStreamByLotID, just
+// subscribed to services.SubscribeEvents instead of internal/occupancy.
+func StreamEvents(c *gin.Context) {
+	streamCfg := config.Get().Stream
+	idleTimeout := time.Duration(streamCfg.IdleTimeoutSeconds) * time.Second
+	keepalive := time.NewTicker(time.Duration(streamCfg.KeepaliveIntervalSeconds) * time.Second)
+	defer keepalive.Stop()
+
+	events := services.SubscribeEvents()
+	defer services.UnsubscribeEvents(events)
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), idleTimeout)
+	defer cancel()
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case evt, ok := <-events:
+			if !ok {
+				return false
+			}
+			cancel()
+			ctx, cancel = context.WithTimeout(c.Request.Context(), idleTimeout)
+			c.SSEvent("event", evt)
+			return true
+		case <-keepalive.C:
+			c.SSEvent("keepalive", nil)
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	})
+}
@@ -9,10 +9,19 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/go-redsync/redsync/v4"
+	"go.opentelemetry.io/otel/attribute"
 
+	"github.com/holycan/smart-parking-system/database"
+	"github.com/holycan/smart-parking-system/internal/metrics"
+	"github.com/holycan/smart-parking-system/internal/tracing"
 	"github.com/holycan/smart-parking-system/lock"
 	"github.com/holycan/smart-parking-system/models"
+	"github.com/holycan/smart-parking-system/queue"
+	"github.com/holycan/smart-parking-system/routing"
 	"github.com/holycan/smart-parking-system/services"
+	"github.com/holycan/smart-parking-system/services/audit"
+	fsm "github.com/holycan/smart-parking-system/services/booking"
 	"github.com/holycan/smart-parking-system/utils"
 )
 
@@ -24,6 +33,9 @@ var (
 	ErrUpdateBooking        = errors.New("failed to update booking")
 	ErrUpdateParkingSpace   = errors.New("failed to update parking space status")
 	ErrFetchParkingData     = errors.New("failed to fetch parking space data")
+
+	errMissingIdempotencyKey = errors.New("Idempotency-Key header is required")
+	errReadIdempotencyBody   = errors.New("failed to read request body")
 )
 
 // CheckInHandler handles user check-in requests
@@ -35,10 +47,47 @@ func CheckInHandler(c *gin.Context) {
 		return
 	}
 
-	// Queue the check-in request for processing
-	utils.ParkingQueue <- map[string]interface{}{
-		"user_id":        userID,
-		"reservation_id": id,
+	booking, err := services.GetBookingByID(id, userID)
+	if err != nil || booking == nil {
+		log.Printf("Booking not found: %v", err)
+		respondWithError(c, http.StatusNotFound, ErrBookingNotFound)
+		return
+	}
+
+	// The origin is optional: if supplied, ProcessCheckIn resolves a
+	// routing.Route to whichever space ends up assigned and attaches it to
+	// the spot_assigned WS notification once check-in finishes.
+	var originReq struct {
+		Latitude  *float64 `json:"latitude"`
+		Longitude *float64 `json:"longitude"`
+	}
+	_ = c.ShouldBindJSON(&originReq)
+
+	// Queue the check-in request for durable, at-least-once processing by
+	// a worker (see queue.Consume / the "worker" subcommand in main.go).
+	if err := queue.Enqueue(c.Request.Context(), queue.CheckInJob{
+		UserID:        userID,
+		ReservationID: id,
+		ParkingLotID:  booking.ParkingLotID,
+		OriginLat:     originReq.Latitude,
+		OriginLng:     originReq.Longitude,
+	}); err != nil {
+		log.Printf("Failed to enqueue check-in for reservation %s: %v", id, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to queue check-in request"})
+		return
+	}
+
+	if err := services.Emit(c.Request.Context(), services.Event{
+		BookingID: id,
+		UserID:    userID,
+		LotID:     booking.ParkingLotID,
+		SpaceID:   booking.ParkingSpaceID,
+		EventType: services.EventTypeCheckInQueued,
+		PrevState: booking.Status,
+		NewState:  booking.Status,
+		Actor:     userID,
+	}); err != nil {
+		log.Printf("Failed to record check-in-queued event for booking %s: %v", id, err)
 	}
 
 	c.JSON(http.StatusOK, gin.H{
@@ -70,8 +119,32 @@ func PaymentHandler(c *gin.Context) {
 		return
 	}
 
+	// Advance the lifecycle FSM to Confirmed now that payment has cleared.
+	// This is best-effort: payment_status is the source of truth for whether
+	// the payment itself succeeded, and a booking that's already past
+	// Confirmed (or was created straight into Init with no PaymentPending
+	// step) simply rejects the transition, which isn't a reason to fail a
+	// request that already completed the payment.
+	if _, err := fsm.TransitionBooking(c.Request.Context(), id, userID, fsm.EventConfirm, nil, nil); err != nil {
+		log.Printf("Booking %s not advanced to confirmed after payment: %v", id, err)
+	}
+
 	// Notify clients about the payment
-	notifyParkingUpdate(booking.ParkingLotID, booking.ParkingSpaceID, true, true)
+	NotifyParkingUpdate(booking.ParkingLotID, booking.ParkingSpaceID, true, true)
+
+	if err := services.Emit(c.Request.Context(), services.Event{
+		BookingID: id,
+		UserID:    userID,
+		LotID:     booking.ParkingLotID,
+		SpaceID:   booking.ParkingSpaceID,
+		EventType: services.EventTypePaymentCompleted,
+		PrevState: booking.Status,
+		NewState:  "confirmed",
+		Actor:     userID,
+		Message:   "Payment completed successfully.",
+	}); err != nil {
+		log.Printf("Failed to record payment-completed event for booking %s: %v", id, err)
+	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Payment completed successfully",
@@ -95,28 +168,41 @@ func CheckOutHandler(c *gin.Context) {
 		return
 	}
 
-	// Mark booking as completed
-	if _, err := services.UpdateBookingWithSpot("completed", userID, id,
-		booking.ParkingLotID, booking.ParkingSpaceID); err != nil {
-		log.Printf("Failed to update booking %s: %v", id, err)
+	// Mark the booking checked out and free the parking space atomically -
+	// these used to be two separate UpdateBookingWithSpot/
+	// UpdateParkingSpaceOccupied calls, which could leave the space marked
+	// occupied forever if the process crashed between them.
+	if _, err := fsm.TransitionBooking(c.Request.Context(), id, userID, fsm.EventCheckOut, nil, nil); err != nil {
+		log.Printf("Failed to check out booking %s: %v", id, err)
 		respondWithError(c, http.StatusInternalServerError, ErrUpdateBooking)
 		return
 	}
 
-	// Free up the parking space
-	if err := services.UpdateParkingSpaceOccupied(false, booking.ParkingSpaceID); err != nil {
-		log.Printf("Failed to update parking space %s: %v", booking.ParkingSpaceID, err)
-		respondWithError(c, http.StatusInternalServerError, ErrUpdateParkingSpace)
-		return
+	if err := audit.RecordEvent(database.App, id, userID, booking.Status, "checked_out", nil); err != nil {
+		log.Printf("Failed to record check-out event: %v", err)
 	}
 
 	// Notify clients about the space becoming available
-	notifyParkingUpdate(booking.ParkingLotID, booking.ParkingSpaceID, false, false)
+	NotifyParkingUpdate(booking.ParkingLotID, booking.ParkingSpaceID, false, false)
+
+	if err := services.Emit(c.Request.Context(), services.Event{
+		BookingID: id,
+		UserID:    userID,
+		LotID:     booking.ParkingLotID,
+		SpaceID:   booking.ParkingSpaceID,
+		EventType: services.EventTypeCheckedOut,
+		PrevState: booking.Status,
+		NewState:  "checked_out",
+		Actor:     userID,
+		Message:   availabilityMessage(booking.ParkingSpaceID, "is available now"),
+	}); err != nil {
+		log.Printf("Failed to record check-out event for booking %s: %v", id, err)
+	}
 
-	// Send availability notification
-	if err := notifySpaceAvailability(booking.ParkingSpaceID); err != nil {
-		log.Printf("Warning: Failed to send availability notification: %v", err)
-		// Continue execution - this is not a critical error
+	// Give whoever's been waiting longest for this lot first notice of the
+	// spot that just freed up.
+	if err := services.PromoteNext(c.Request.Context(), booking.ParkingLotID, booking.ParkingSpaceID); err != nil {
+		log.Printf("Warning: Failed to promote next waitlist entry for lot %s: %v", booking.ParkingLotID, err)
 	}
 
 	c.JSON(http.StatusOK, gin.H{
@@ -124,22 +210,50 @@ func CheckOutHandler(c *gin.Context) {
 	})
 }
 
-// ProcessCheckIn handles the asynchronous check-in process with improved spot availability checking
-func ProcessCheckIn(req map[string]interface{}) error {
+// ProcessCheckIn handles the asynchronous check-in process with improved
+// spot availability checking. It's the handler for queue.Consume, called
+// once per dequeued queue.CheckInJob. originLat/originLng are the driver's
+// current location, as optionally supplied to CheckInHandler; when set and
+// routing.Current is configured, a route to the assigned space is resolved
+// and attached to the spot_assigned notification sent at the end.
+func ProcessCheckIn(userID, reservationID string, originLat, originLng *float64) (err error) {
+	start := time.Now()
+	defer func() {
+		outcome := "success"
+		if err == ErrNoAvailableSpot {
+			outcome = "no_available_spot"
+		} else if err != nil {
+			outcome = "error"
+		}
+		metrics.CheckInDurationSeconds.WithLabelValues(outcome).Observe(time.Since(start).Seconds())
+	}()
+
+	// This runs off the worker loop (queue.Consume), never off a traced HTTP
+	// request, so it gets its own root span rather than a child of one -
+	// there's no cross-process trace-context propagation through the Redis
+	// Streams payload for it to continue.
+	rootCtx, rootSpan := tracing.Current.Start(context.Background(), "ProcessCheckIn")
+	rootSpan.SetAttributes(
+		attribute.String("reservation.id", reservationID),
+		attribute.String("user.id", userID),
+	)
+	defer rootSpan.End()
+
 	// Acquire semaphore to limit concurrent processing
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	ctx, cancel := context.WithTimeout(rootCtx, 30*time.Second)
 	defer cancel()
 
 	select {
 	case utils.Semaphore <- struct{}{}:
-		defer func() { <-utils.Semaphore }()
+		metrics.SemaphoreInUse.Inc()
+		defer func() {
+			<-utils.Semaphore
+			metrics.SemaphoreInUse.Dec()
+		}()
 	case <-ctx.Done():
 		return fmt.Errorf("timed out waiting for processing resources")
 	}
 
-	userID := req["user_id"].(string)
-	reservationID := req["reservation_id"].(string)
-
 	// Get booking information
 	booking, err := services.GetBookingByID(reservationID, userID)
 	if err != nil || booking == nil {
@@ -147,99 +261,266 @@ func ProcessCheckIn(req map[string]interface{}) error {
 		return ErrBookingNotFound
 	}
 
-	// Try to lock the originally assigned spot
+	// Try to claim the originally assigned spot
 	spaceID := booking.ParkingSpaceID
 	parkingLotID := booking.ParkingLotID
-
-	// Try acquiring lock first
-	mutex, err := lock.AcquireLock("spot-lock:"+spaceID, 10*time.Second)
-
-	// First fallback: If lock acquisition fails, spot is being processed by another request
-	if err != nil {
-		log.Println("Original spot is locked, finding alternative spot...")
+	var mutex *redsync.Mutex
+
+	// Claim the spot via optimistic compare-and-swap first - no distributed
+	// lock needed for this path. Contention just surfaces as a version
+	// conflict to retry against the space's freshest state; only once
+	// retries are exhausted do we fall back to searching for a different
+	// spot (which still uses lock.AcquireLock, since that path is scanning
+	// many candidate rows rather than CASing one we already know about).
+	_, occupySpan := tracing.Current.Start(rootCtx, "occupy-spot-cas")
+	occupyErr := occupySpotWithRetry(ctx, spaceID, reservationID)
+	occupySpan.End()
+
+	if occupyErr != nil {
+		log.Printf("Could not claim original spot %s via CAS (%v), finding alternative...", spaceID, occupyErr)
 		goto FindAlternative
 	}
 
-	// Even with successful lock, double-check if spot is physically occupied
-	{
-		isOccupied, checkErr := services.IsParkingSpaceOccupied(spaceID)
-		if checkErr != nil {
-			lock.ReleaseLock(mutex)
-			log.Printf("Failed to check spot status: %v", checkErr)
-			goto FindAlternative
-		}
-
-		// Second fallback: If spot is occupied despite successful lock acquisition
-		if isOccupied {
-			lock.ReleaseLock(mutex)
-			notifyFindAlternative(spaceID)
-			log.Printf("Spot %s is already occupied despite successful lock, finding alternative", spaceID)
-			goto FindAlternative
-		}
-	}
-
-	// Successfully locked and verified as unoccupied - proceed with this spot
+	// Successfully claimed via CAS - proceed with this spot
 	goto ProcessSpot
 
 FindAlternative:
 	// Find and lock an available alternate spot
 	{
 		var space map[string]interface{}
-		mutex, space, err = services.FindAndLockAvailableSpot(parkingLotID)
+		mutex, space, err = services.FindAndLockAvailableSpot(ctx, parkingLotID, booking.VehicleType, reservationID)
 
 		if err != nil {
-			notifyNoAvailableAlternativeSpot(spaceID)
+			// Every spot in the lot is taken - queue the driver instead of
+			// failing the check-in outright. services.PromoteNext notifies
+			// whoever's at the head of this same waitlist the moment a spot
+			// here frees up (see CheckOutHandler and services.Expiry).
+			if joinErr := services.JoinWaitlist(ctx, parkingLotID, userID, reservationID); joinErr != nil {
+				log.Printf("Failed to add booking %s to waitlist for lot %s: %v", reservationID, parkingLotID, joinErr)
+			}
+			if err := services.Emit(ctx, services.Event{
+				BookingID: reservationID,
+				UserID:    userID,
+				LotID:     parkingLotID,
+				SpaceID:   spaceID,
+				EventType: services.EventTypeWaitlisted,
+				Actor:     "system",
+				Message:   availabilityMessage(spaceID, "is unavailable or occupied"),
+			}); err != nil {
+				log.Printf("Failed to record waitlisted event for booking %s: %v", reservationID, err)
+			}
 			log.Println("No available parking spots or failed to lock any spot")
+			metrics.SpotAssignmentFailures.WithLabelValues("no_available_spot").Inc()
 			return ErrNoAvailableSpot
 		}
 
 		// Update with new spot details
 		spaceID = space["spot_id"].(string)
 
-		// Double-check that this spot is indeed unoccupied
-		isOccupied, checkErr := services.IsParkingSpaceOccupied(spaceID)
-		if checkErr != nil || isOccupied {
+		if err := occupySpotWithRetry(ctx, spaceID, reservationID); err != nil {
 			lock.ReleaseLock(mutex)
-			notifyNoAvailableAlternativeSpot(spaceID)
-			log.Printf("Alternative spot %s is unavailable or occupied: %v", spaceID, checkErr)
+			if emitErr := services.Emit(ctx, services.Event{
+				BookingID: reservationID,
+				UserID:    userID,
+				LotID:     parkingLotID,
+				SpaceID:   spaceID,
+				EventType: services.EventTypeNoAvailableSpot,
+				Actor:     "system",
+				Message:   availabilityMessage(spaceID, "is unavailable or occupied"),
+			}); emitErr != nil {
+				log.Printf("Failed to record no-available-spot event for booking %s: %v", reservationID, emitErr)
+			}
+			log.Printf("Alternative spot %s could not be claimed via CAS: %v", spaceID, err)
+			metrics.SpotAssignmentFailures.WithLabelValues("no_available_spot").Inc()
 			return ErrNoAvailableSpot
 		}
 
-		notifyAvailableAlternativeSpot(spaceID)
+		if err := services.Emit(ctx, services.Event{
+			BookingID: reservationID,
+			UserID:    userID,
+			LotID:     parkingLotID,
+			SpaceID:   spaceID,
+			EventType: services.EventTypeAlternateSpotAssigned,
+			Actor:     "system",
+			Message:   availabilityMessage(spaceID, "is available, changing your parking spot"),
+		}); err != nil {
+			log.Printf("Failed to record alternate-spot-assigned event for booking %s: %v", reservationID, err)
+		}
 	}
 
 ProcessSpot:
-	defer lock.ReleaseLock(mutex)
+	if mutex != nil {
+		defer lock.ReleaseLock(mutex)
+	}
 
-	// Update booking with the assigned spot
-	_, err = services.UpdateBookingWithSpot("active", userID, reservationID, parkingLotID, spaceID)
+	// Assign the spot and carry the booking through CheckedIn to Active in
+	// one atomic transition each - previously this was two independent
+	// UpdateBookingWithSpot/UpdateParkingSpaceOccupied calls with a
+	// best-effort RevertBookingSpot compensating for the second failing,
+	// which left a window where a crash could mark the space occupied
+	// without the reservation pointing at it (or vice versa).
+	_, dbSpan := tracing.Current.Start(rootCtx, "transition-booking")
+	_, err = fsm.TransitionBooking(ctx, reservationID, userID, fsm.EventLockSpot, &fsm.LockSpotMeta{
+		ParkingLotID:   parkingLotID,
+		ParkingSpaceID: spaceID,
+	}, nil)
+	dbSpan.End()
 	if err != nil {
-		log.Printf("Failed to update booking with spot %s: %v", spaceID, err)
+		log.Printf("Failed to lock spot %s for booking %s: %v", spaceID, reservationID, err)
+		// The CAS above already marked spaceID occupied; since the booking
+		// side of the assignment didn't land (e.g. a redelivered
+		// queue.CheckInJob - see queue.reclaimStuck - losing the race to a
+		// first run that already carried this same booking past
+		// SpotLocked), undo it rather than leaving an occupied space with no
+		// reservation pointing at it.
+		releaseOccupiedSpot(ctx, spaceID)
+		metrics.SpotAssignmentFailures.WithLabelValues("update_booking").Inc()
+		return ErrUpdateBooking
+	}
+	if _, err := fsm.TransitionBooking(ctx, reservationID, userID, fsm.EventCheckIn, nil, nil); err != nil {
+		log.Printf("Failed to mark booking %s checked in: %v", reservationID, err)
+		return ErrUpdateBooking
+	}
+	// The driver made it - disarm the reservation-window deadline so
+	// services.Expiry doesn't race this same booking to Expired.
+	services.Expiry.Cancel(reservationID)
+	if _, err := fsm.TransitionBooking(ctx, reservationID, userID, fsm.EventActivate, nil, nil); err != nil {
+		log.Printf("Failed to activate booking %s: %v", reservationID, err)
 		return ErrUpdateBooking
 	}
 
-	// Mark parking space as occupied
-	if err := services.UpdateParkingSpaceOccupied(true, spaceID); err != nil {
-		log.Printf("Failed to update parking space %s: %v", spaceID, err)
-		// Attempt to revert booking update on failure
-		if revertErr := services.RevertBookingSpot(reservationID, userID); revertErr != nil {
-			log.Printf("Failed to revert booking after space update failure: %v", revertErr)
-		}
-		return ErrUpdateParkingSpace
+	if err := audit.RecordEvent(database.App, reservationID, userID, booking.Status, "checked_in", map[string]interface{}{
+		"parkingSpaceId": spaceID,
+	}); err != nil {
+		log.Printf("Failed to record check-in event: %v", err)
 	}
 
 	// Notify clients about space being occupied
-	notifyParkingUpdate(parkingLotID, spaceID, true, false)
+	NotifyParkingUpdate(parkingLotID, spaceID, true, false)
 
-	// Send occupancy notification
-	if err := notifySpaceOccupancy(spaceID); err != nil {
-		log.Printf("Warning: Failed to send occupancy notification: %v", err)
-		// Continue execution - this is not a critical error
+	_, notifySpan := tracing.Current.Start(rootCtx, "notify-spot-assigned")
+	if err := services.Emit(ctx, spotAssignedEvent(userID, reservationID, parkingLotID, spaceID, booking.Status, originLat, originLng)); err != nil {
+		log.Printf("Failed to record spot-assigned event for booking %s: %v", reservationID, err)
 	}
+	notifySpan.End()
 
 	return nil
 }
 
+// maxOccupyRetries bounds occupySpotWithRetry's compare-and-swap attempts.
+// A conflict means another request claimed the space between our read and
+// our write; a handful of retries resolves ordinary contention, and giving
+// up beyond that means ErrNoAvailableSpot is a more honest answer than
+// looping indefinitely against a space someone else genuinely has.
+const maxOccupyRetries = 3
+
+// occupySpotWithRetry claims spaceID via services.TryOccupyParkingSpace,
+// re-reading the space's current occupancy/resource_version and retrying
+// on ErrVersionConflict up to maxOccupyRetries times. This replaces the
+// old lock -> IsParkingSpaceOccupied -> UpdateParkingSpaceOccupied(true)
+// sequence, which had a window between the occupancy check and the update
+// where a concurrent request could occupy the same space first.
+//
+// Before attempting the CAS, it also checks services.SpotHeldForOther: a
+// spot services.PromoteNext just freed up is held for the reservation it
+// promoted for a short window (see services.HoldSpotForPromotion), so a
+// walk-up or another waitlisted driver's check-in can't outrun the
+// promoted driver's own client rendering its notification.
+func occupySpotWithRetry(ctx context.Context, spaceID, reservationID string) error {
+	if held, err := services.SpotHeldForOther(ctx, spaceID, reservationID); err != nil {
+		log.Printf("Failed to check waitlist hold for space %s: %v", spaceID, err)
+	} else if held {
+		return ErrNoAvailableSpot
+	}
+
+	for i := 0; i < maxOccupyRetries; i++ {
+		occupied, version, err := services.GetParkingSpaceVersion(ctx, database.App, spaceID)
+		if err != nil {
+			return err
+		}
+		if occupied {
+			return ErrNoAvailableSpot
+		}
+
+		err = services.TryOccupyParkingSpace(ctx, database.App, spaceID, false, true, version)
+		if err == nil {
+			services.ClearSpotHold(ctx, spaceID)
+			return nil
+		}
+		if !errors.Is(err, services.ErrVersionConflict) {
+			return err
+		}
+		// Lost the race - loop around and retry against the refreshed state.
+	}
+	return ErrNoAvailableSpot
+}
+
+// releaseOccupiedSpot undoes occupySpotWithRetry's CAS once it's known the
+// booking side of the assignment won't be following it - otherwise spaceID
+// is left is_occupied=true with no reservation pointing at it. Best-effort:
+// retries a handful of times like occupySpotWithRetry itself, and a
+// persistent version conflict just means someone else (a legitimate new
+// check-in) has since claimed the space, which isn't this caller's to undo
+// anymore.
+func releaseOccupiedSpot(ctx context.Context, spaceID string) {
+	for i := 0; i < maxOccupyRetries; i++ {
+		occupied, version, err := services.GetParkingSpaceVersion(ctx, database.App, spaceID)
+		if err != nil {
+			log.Printf("Failed to read parking space %s while rolling back a failed check-in: %v", spaceID, err)
+			return
+		}
+		if !occupied {
+			return
+		}
+		err = services.TryOccupyParkingSpace(ctx, database.App, spaceID, true, false, version)
+		if err == nil {
+			return
+		}
+		if !errors.Is(err, services.ErrVersionConflict) {
+			log.Printf("Failed to release parking space %s while rolling back a failed check-in: %v", spaceID, err)
+			return
+		}
+		// Lost the race - loop around and retry against the refreshed state.
+	}
+	log.Printf("Gave up releasing parking space %s after a failed check-in (kept losing the CAS race)", spaceID)
+}
+
+// spotAssignedEvent builds the services.Event recording a finished
+// check-in, resolving a routing.Route when the driver's origin was
+// supplied and routing.Current is configured. Routing failures are logged
+// and otherwise ignored — the assignment itself already succeeded.
+func spotAssignedEvent(userID, reservationID, lotID, spaceID, prevState string, originLat, originLng *float64) services.Event {
+	evt := services.Event{
+		BookingID: reservationID,
+		UserID:    userID,
+		LotID:     lotID,
+		SpaceID:   spaceID,
+		EventType: services.EventTypeSpotAssigned,
+		PrevState: prevState,
+		NewState:  "active",
+		Actor:     userID,
+		Message:   "You've been checked in and assigned a parking spot.",
+	}
+
+	if routing.Current != nil && originLat != nil && originLng != nil {
+		destLat, destLng, err := services.GetParkingSpaceLocation(spaceID)
+		if err != nil {
+			log.Printf("Warning: failed to look up location for parking space %s: %v", spaceID, err)
+		} else {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			route, err := routing.Current.GetRoute(ctx, routing.Point{Lat: *originLat, Lng: *originLng}, routing.Point{Lat: destLat, Lng: destLng})
+			cancel()
+			if err != nil {
+				log.Printf("Warning: failed to resolve route to parking space %s: %v", spaceID, err)
+			} else {
+				evt.Route = route
+			}
+		}
+	}
+
+	return evt
+}
+
 // getUserIDFromContext extracts the user ID from the context
 func getUserIDFromContext(c *gin.Context) (string, bool) {
 	userID, exists := c.Get("userId")
@@ -254,8 +535,8 @@ func respondWithError(c *gin.Context, statusCode int, err error) {
 	c.JSON(statusCode, gin.H{"error": err.Error()})
 }
 
-// notifyParkingUpdate broadcasts parking space updates
-func notifyParkingUpdate(parkingLotID, spaceID string, isOccupied, isPaid bool) {
+// NotifyParkingUpdate broadcasts parking space updates
+func NotifyParkingUpdate(parkingLotID, spaceID string, isOccupied, isPaid bool) {
 	utils.WsManager.HandleParkingUpdate(models.ParkingEvent{
 		ParkingLotID: parkingLotID,
 		SpaceID:      spaceID,
@@ -265,109 +546,46 @@ func notifyParkingUpdate(parkingLotID, spaceID string, isOccupied, isPaid bool)
 	})
 }
 
-// notifySpaceAvailability sends notification about space becoming available
-func notifySpaceAvailability(spaceID string) error {
-	parkingData, err := services.GetParkingDataById(spaceID)
-	if err != nil {
-		return fmt.Errorf("%w: %v", ErrFetchParkingData, err)
-	}
-
-	message := fmt.Sprintf(
-		"Parking Space %s Floor %s Zone %s is available now!",
-		parkingData["space_number"].(string),
-		parkingData["floor"].(string),
-		parkingData["zone_name"].(string),
-	)
-
-	utils.WsManager.HandleNotificationUpdate(models.NotificationEvent{
-		Type:    "availability_update",
-		Message: message,
-	})
-
-	return nil
-}
-
-func notifyNoAvailableAlternativeSpot(spaceID string) error {
-	parkingData, err := services.GetParkingDataById(spaceID)
-	if err != nil {
-		return fmt.Errorf("%w: %v", ErrFetchParkingData, err)
-	}
-
-	message := fmt.Sprintf(
-		"Alternative Space %s Floor %s Zone %s is unavailable or occupied!",
-		parkingData["space_number"].(string),
-		parkingData["floor"].(string),
-		parkingData["zone_name"].(string),
-	)
-
-	utils.WsManager.HandleNotificationUpdate(models.NotificationEvent{
-		Type:    "availability_update",
-		Message: message,
-	})
-
-	return nil
-}
-
-func notifyAvailableAlternativeSpot(spaceID string) error {
+// NotifySpaceAvailability sends notification about space becoming available.
+// Kept alongside events.Emit (rather than folded into it) since
+// grpc/server.go and admin_handler.go call this directly for spaces that
+// become available outside of a check-out (e.g. an admin unblocking a spot),
+// where there's no booking to attach a parking_events row to.
+func NotifySpaceAvailability(spaceID string) error {
 	parkingData, err := services.GetParkingDataById(spaceID)
 	if err != nil {
 		return fmt.Errorf("%w: %v", ErrFetchParkingData, err)
 	}
 
-	message := fmt.Sprintf(
-		"Alternative Space %s Floor %s Zone %s is available, changing your parking spot",
-		parkingData["space_number"].(string),
-		parkingData["floor"].(string),
-		parkingData["zone_name"].(string),
-	)
-
 	utils.WsManager.HandleNotificationUpdate(models.NotificationEvent{
 		Type:    "availability_update",
-		Message: message,
+		Message: formatAvailabilityMessage(parkingData, "is available now"),
 	})
 
 	return nil
 }
 
-func notifyFindAlternative(spaceID string) error {
+// availabilityMessage renders the human-readable "Parking Space ... <verb>"
+// text shared by NotifySpaceAvailability and ProcessCheckIn's
+// services.Emit calls for the alternate-spot/waitlist paths. A lookup
+// failure is logged and yields an empty string rather than failing the
+// caller - these are all best-effort driver notifications, same as the
+// notify* functions they replace.
+func availabilityMessage(spaceID, verb string) string {
 	parkingData, err := services.GetParkingDataById(spaceID)
 	if err != nil {
-		return fmt.Errorf("%w: %v", ErrFetchParkingData, err)
+		log.Printf("Warning: failed to look up parking data for space %s: %v", spaceID, err)
+		return ""
 	}
-
-	message := fmt.Sprintf(
-		"Parking Space %s Floor %s Zone %s is occupied!, Finding alternative spot",
-		parkingData["space_number"].(string),
-		parkingData["floor"].(string),
-		parkingData["zone_name"].(string),
-	)
-
-	utils.WsManager.HandleNotificationUpdate(models.NotificationEvent{
-		Type:    "availability_update",
-		Message: message,
-	})
-
-	return nil
+	return formatAvailabilityMessage(parkingData, verb)
 }
 
-// notifySpaceOccupancy sends notification about space becoming occupied
-func notifySpaceOccupancy(spaceID string) error {
-	parkingData, err := services.GetParkingDataById(spaceID)
-	if err != nil {
-		return fmt.Errorf("%w: %v", ErrFetchParkingData, err)
-	}
-
-	message := fmt.Sprintf(
-		"Parking Space %s Floor %s Zone %s is occupied",
+func formatAvailabilityMessage(parkingData map[string]interface{}, verb string) string {
+	return fmt.Sprintf(
+		"Parking Space %s Floor %s Zone %s %s!",
 		parkingData["space_number"].(string),
 		parkingData["floor"].(string),
 		parkingData["zone_name"].(string),
+		verb,
 	)
-
-	utils.WsManager.HandleNotificationUpdate(models.NotificationEvent{
-		Type:    "availability_update",
-		Message: message,
-	})
-
-	return nil
 }
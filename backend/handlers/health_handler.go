@@ -0,0 +1,21 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/holycan/smart-parking-system/internal/version"
+)
+
+// Healthz reports liveness plus the running binary's build metadata, so
+// deploy tooling can confirm the expected version actually rolled out
+// without a separate `server version` shell-out.
+func Healthz(c *gin.Context) {
+	info := version.Current()
+	c.JSON(http.StatusOK, gin.H{
+		"status":    "ok",
+		"gitHash":   info.GitHash,
+		"buildTime": info.BuildTime,
+	})
+}
@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/holycan/smart-parking-system/lock"
+)
+
+const idempotencyKeyTTL = 24 * time.Hour
+
+// idempotencyRecord is what RequireIdempotencyKey caches in Redis against a
+// (userID, bookingID, Idempotency-Key) tuple. PayloadHash is what tells a
+// genuine retry (same body) apart from the same key reused against a
+// different request (different body), which RequireIdempotencyKey rejects
+// outright rather than silently processing or replaying the wrong response.
+type idempotencyRecord struct {
+	PayloadHash    string          `json:"payload_hash"`
+	ResponseStatus int             `json:"response_status"`
+	ResponseBody   json.RawMessage `json:"response_body"`
+	CreatedAt      time.Time       `json:"created_at"`
+}
+
+// gateResponseRecorder buffers a handler's response so it can be cached and
+// replayed verbatim to a future duplicate request.
+type gateResponseRecorder struct {
+	gin.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (w *gateResponseRecorder) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *gateResponseRecorder) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// RequireIdempotencyKey enforces an Idempotency-Key header on a
+// state-changing gate endpoint (check-in, payment, check-out), keyed by
+// the authenticated user and the :id booking the request targets rather
+// than the full request identity utils.Idempotency hashes - a worker
+// retrying queue.CheckInJob or a client retrying a flaky payment call both
+// reuse the exact same key for the same booking, and this is what stops
+// utils.ParkingQueue and PaymentHandler from processing either one twice.
+// Reusing a key against a different request body (rather than an honest
+// retry) is rejected with 409 Conflict instead of being replayed or
+// reprocessed.
+func RequireIdempotencyKey() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader("Idempotency-Key")
+		if key == "" {
+			respondWithError(c, http.StatusBadRequest, errMissingIdempotencyKey)
+			c.Abort()
+			return
+		}
+
+		userID, exists := getUserIDFromContext(c)
+		if !exists {
+			respondWithError(c, http.StatusUnauthorized, ErrUserNotAuthenticated)
+			c.Abort()
+			return
+		}
+		bookingID := c.Param("id")
+
+		bodyBytes, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			respondWithError(c, http.StatusBadRequest, errReadIdempotencyBody)
+			c.Abort()
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(bodyBytes))
+		payloadHash := hashPayload(bodyBytes)
+
+		redisKey := idempotencyRedisKey(userID, bookingID, key)
+		ctx := context.Background()
+
+		if cached, err := lock.RedisClient.Get(ctx, redisKey).Result(); err == nil {
+			replayOrConflict(c, cached, payloadHash)
+			c.Abort()
+			return
+		}
+
+		// Serialize concurrent duplicates (e.g. a client that fires the
+		// same request twice before the first gets a response) the same
+		// way utils.Idempotency does, so the second one waits for the
+		// first's result instead of racing it into utils.ParkingQueue or
+		// PaymentHandler a second time.
+		mutex, err := lock.AcquireLock("idemp-lock:"+redisKey, 10*time.Second)
+		if err != nil {
+			c.JSON(http.StatusConflict, gin.H{"error": "Duplicate request already in flight, please retry shortly"})
+			c.Abort()
+			return
+		}
+		defer lock.ReleaseLock(mutex)
+
+		if cached, err := lock.RedisClient.Get(ctx, redisKey).Result(); err == nil {
+			replayOrConflict(c, cached, payloadHash)
+			c.Abort()
+			return
+		}
+
+		recorder := &gateResponseRecorder{ResponseWriter: c.Writer, status: http.StatusOK}
+		c.Writer = recorder
+
+		c.Next()
+
+		record := idempotencyRecord{
+			PayloadHash:    payloadHash,
+			ResponseStatus: recorder.status,
+			ResponseBody:   json.RawMessage(recorder.body.Bytes()),
+			CreatedAt:      time.Now(),
+		}
+		encoded, err := json.Marshal(record)
+		if err != nil {
+			return
+		}
+		lock.RedisClient.Set(ctx, redisKey, encoded, idempotencyKeyTTL)
+	}
+}
+
+func idempotencyRedisKey(userID, bookingID, key string) string {
+	sum := sha256.Sum256([]byte(userID + "\x00" + bookingID + "\x00" + key))
+	return "idemp:" + hex.EncodeToString(sum[:])
+}
+
+func hashPayload(body []byte) string {
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:])
+}
+
+// replayOrConflict either replays cached's stored response verbatim, or -
+// if payloadHash doesn't match what was cached for this key - reports the
+// reused-key-different-payload case as a 409 instead.
+func replayOrConflict(c *gin.Context, cached, payloadHash string) {
+	var record idempotencyRecord
+	if err := json.Unmarshal([]byte(cached), &record); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to replay cached response"})
+		return
+	}
+	if record.PayloadHash != payloadHash {
+		c.JSON(http.StatusConflict, gin.H{"error": "Idempotency-Key was already used with a different request"})
+		return
+	}
+	c.Data(record.ResponseStatus, "application/json; charset=utf-8", record.ResponseBody)
+}
@@ -0,0 +1,19 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/holycan/smart-parking-system/auth/keys"
+)
+
+// JWKS serves the public half of the JWT signing key as a JSON Web Key
+// Set, so an external verifier (an ANPR camera gateway, a mobile client)
+// can check a token's signature without holding the key that signed it.
+// Returns an empty key set when JWT_ALGORITHM is HS256, since a shared
+// HMAC secret has no public component to publish.
+func JWKS(c *gin.Context) {
+	set, _ := keys.Current.JWKS()
+	c.JSON(http.StatusOK, set)
+}
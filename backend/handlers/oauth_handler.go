@@ -0,0 +1,289 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/oauth2"
+
+	"github.com/holycan/smart-parking-system/auth/connectors"
+	"github.com/holycan/smart-parking-system/auth/rbac"
+	"github.com/holycan/smart-parking-system/config"
+	"github.com/holycan/smart-parking-system/database"
+	"github.com/holycan/smart-parking-system/middleware"
+)
+
+// oauthStateTTL is how long a login/link attempt has to complete the
+// provider's consent screen and return to the callback.
+const oauthStateTTL = 10 * time.Minute
+
+// LoginOAuth redirects the browser to provider's consent screen.
+func LoginOAuth(c *gin.Context) {
+	provider := c.Param("provider")
+	connector, err := connectors.Get(provider, config.Get().OAuth)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	state, codeVerifier, err := createOAuthState(provider, "")
+	if err != nil {
+		log.Printf("Error creating OAuth state: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start OAuth login"})
+		return
+	}
+
+	c.Redirect(http.StatusTemporaryRedirect, connector.LoginURL(state, codeVerifier))
+}
+
+// LinkOAuthProvider starts the same consent flow as LoginOAuth, but ties
+// the resulting state to the already-authenticated user so OAuthCallback
+// links the provider identity instead of creating a new account.
+func LinkOAuthProvider(c *gin.Context) {
+	userID, exists := c.Get("userId")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	provider := c.Param("provider")
+	connector, err := connectors.Get(provider, config.Get().OAuth)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	state, codeVerifier, err := createOAuthState(provider, userID.(string))
+	if err != nil {
+		log.Printf("Error creating OAuth state: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start OAuth link"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"url": connector.LoginURL(state, codeVerifier)})
+}
+
+// UnlinkOAuthProvider removes a linked provider identity from the
+// authenticated user's account.
+func UnlinkOAuthProvider(c *gin.Context) {
+	userID, exists := c.Get("userId")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	provider := c.Param("provider")
+	result, err := database.App.Exec(
+		"DELETE FROM user_identities WHERE user_id = $1 AND provider = $2",
+		userID, provider,
+	)
+	if err != nil {
+		log.Printf("Error unlinking OAuth provider: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unlink provider"})
+		return
+	}
+
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No linked identity for that provider"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Provider unlinked successfully"})
+}
+
+// OAuthCallback completes the consent flow: it verifies state, fetches
+// the provider's profile, and either links it to the user that started a
+// link flow, logs in an existing linked user, create-or-links by verified
+// email, or registers a brand-new account.
+func OAuthCallback(c *gin.Context) {
+	provider := c.Param("provider")
+	code := c.Query("code")
+	state := c.Query("state")
+
+	if code == "" || state == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Missing code or state"})
+		return
+	}
+
+	linkUserID, codeVerifier, err := consumeOAuthState(provider, state)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired OAuth state"})
+		return
+	}
+
+	connector, err := connectors.Get(provider, config.Get().OAuth)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	info, err := connector.HandleCallback(c.Request.Context(), code, codeVerifier)
+	if err != nil {
+		log.Printf("Error handling OAuth callback: %v", err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to complete OAuth login"})
+		return
+	}
+
+	if linkUserID != "" {
+		if err := linkIdentity(linkUserID, provider, info); err != nil {
+			log.Printf("Error linking OAuth identity: %v", err)
+			c.JSON(http.StatusConflict, gin.H{"error": "This provider account is already linked to another user"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Provider linked successfully"})
+		return
+	}
+
+	userID, err := findOrCreateOAuthUser(provider, info)
+	if err != nil {
+		log.Printf("Error resolving OAuth user: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to complete OAuth login"})
+		return
+	}
+
+	var userEmail string
+	if err := database.App.QueryRow("SELECT email FROM users WHERE id = $1", userID).Scan(&userEmail); err != nil {
+		log.Printf("Error fetching OAuth user: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to complete OAuth login"})
+		return
+	}
+
+	roles, perms, err := rbac.Resolve(userID)
+	if err != nil {
+		log.Printf("Error resolving roles for OAuth user: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate authentication token"})
+		return
+	}
+
+	// OAuth identity providers are out of scope for the password-login MFA
+	// gate added in handlers.LoginUser.
+	token, err := middleware.GenerateToken(userID, userEmail, roles, perms, true)
+	if err != nil {
+		log.Printf("Error generating token: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate authentication token"})
+		return
+	}
+
+	refreshToken, _, err := middleware.IssueRefreshToken(userID, c.Request.UserAgent(), c.ClientIP())
+	if err != nil {
+		log.Printf("Error issuing refresh token: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate authentication token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":      "OAuth login successful",
+		"token":        token,
+		"refreshToken": refreshToken,
+	})
+}
+
+// createOAuthState starts a login/link flow: it records the CSRF state and
+// a fresh PKCE code verifier together, and returns both so the caller can
+// redirect to connector.LoginURL with the derived challenge.
+func createOAuthState(provider, linkUserID string) (state, codeVerifier string, err error) {
+	state = uuid.New().String()
+	codeVerifier = oauth2.GenerateVerifier()
+	var userIDValue interface{}
+	if linkUserID != "" {
+		userIDValue = linkUserID
+	}
+
+	_, err = database.App.Exec(
+		"INSERT INTO oauth_states (state, provider, user_id, code_verifier, expires_at) VALUES ($1, $2, $3, $4, $5)",
+		state, provider, userIDValue, codeVerifier, time.Now().Add(oauthStateTTL),
+	)
+	return state, codeVerifier, err
+}
+
+// consumeOAuthState validates and deletes state, returning the user id a
+// link flow was started for ("" for a plain login flow) and the PKCE code
+// verifier createOAuthState generated for it.
+func consumeOAuthState(provider, state string) (linkUserID, codeVerifier string, err error) {
+	var storedProvider string
+	var storedUserID sql.NullString
+	var expiresAt time.Time
+	err = database.App.QueryRow(
+		"SELECT provider, user_id, code_verifier, expires_at FROM oauth_states WHERE state = $1",
+		state,
+	).Scan(&storedProvider, &storedUserID, &codeVerifier, &expiresAt)
+	if err != nil {
+		return "", "", err
+	}
+
+	database.App.Exec("DELETE FROM oauth_states WHERE state = $1", state)
+
+	if storedProvider != provider || time.Now().After(expiresAt) {
+		return "", "", fmt.Errorf("oauth state mismatch or expired")
+	}
+
+	return storedUserID.String, codeVerifier, nil
+}
+
+func linkIdentity(userID, provider string, info connectors.UserInfo) error {
+	_, err := database.App.Exec(
+		"INSERT INTO user_identities (id, user_id, provider, provider_sub, email, created_at) VALUES ($1, $2, $3, $4, $5, $6)",
+		uuid.New().String(), userID, provider, info.Sub, info.Email, time.Now(),
+	)
+	return err
+}
+
+// findOrCreateOAuthUser resolves info to a local user id: an existing
+// linked identity wins, then a verified-email match against an existing
+// account, then a brand-new account.
+func findOrCreateOAuthUser(provider string, info connectors.UserInfo) (string, error) {
+	var userID string
+	err := database.App.QueryRow(
+		"SELECT user_id FROM user_identities WHERE provider = $1 AND provider_sub = $2",
+		provider, info.Sub,
+	).Scan(&userID)
+	if err == nil {
+		return userID, nil
+	}
+
+	if info.EmailVerified {
+		err = database.App.QueryRow("SELECT id FROM users WHERE email = $1", info.Email).Scan(&userID)
+		if err == nil {
+			return userID, linkIdentity(userID, provider, info)
+		}
+	}
+
+	userID = uuid.New().String()
+	firstName, lastName := splitName(info.Name)
+	randomPassword, err := bcrypt.GenerateFromPassword([]byte(uuid.New().String()), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+
+	_, err = database.App.Exec(
+		"INSERT INTO users (id, email, password, first_name, last_name, phone, created_at, updated_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $7)",
+		userID, info.Email, string(randomPassword), firstName, lastName, "", time.Now(),
+	)
+	if err != nil {
+		return "", err
+	}
+
+	if err := rbac.EnsureDefaultRole(userID); err != nil {
+		return "", err
+	}
+
+	return userID, linkIdentity(userID, provider, info)
+}
+
+func splitName(name string) (first, last string) {
+	parts := strings.SplitN(strings.TrimSpace(name), " ", 2)
+	if len(parts) == 0 || parts[0] == "" {
+		return "OAuth", "User"
+	}
+	if len(parts) == 1 {
+		return parts[0], "User"
+	}
+	return parts[0], parts[1]
+}
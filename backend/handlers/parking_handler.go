@@ -1,25 +1,47 @@
 package handlers
 
 import (
+	"context"
+	"io"
 	"log"
 	"net/http"
 	"strconv"
-	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 
+	"github.com/holycan/smart-parking-system/config"
+	"github.com/holycan/smart-parking-system/criteria"
 	"github.com/holycan/smart-parking-system/database"
+	"github.com/holycan/smart-parking-system/internal/occupancy"
 	"github.com/holycan/smart-parking-system/models"
+	"github.com/holycan/smart-parking-system/pagination"
+	"github.com/holycan/smart-parking-system/repositories"
 )
 
-// GetParkingLots handles fetching all parking lots
+// lotSortColumns maps the "sort" query param GetParkingLots accepts for
+// cursor-based pagination to the column it orders by; id is always
+// appended as a tie-breaker so the tuple stays unique even when the sort
+// column has duplicates. "price" and "availability" (see
+// criteria.ParkingLotCriteria) aren't here because neither is a stable,
+// single-column sort a keyset cursor can walk - they fall back to plain
+// page/limit OFFSET pagination instead.
+var lotSortColumns = map[string]string{
+	"name":       "name",
+	"created_at": "created_at",
+}
+
+// GetParkingLots handles fetching all parking lots, filtered by city,
+// state, and a full-text q (see criteria.ParkingLotCriteria.Where).
+// Cursor pagination is only available for sort=name|created_at; any other
+// sort (price, availability) uses page/limit OFFSET pagination.
 func GetParkingLots(c *gin.Context) {
-	// Parse query parameters for filtering and pagination
 	city := c.Query("city")
 	state := c.Query("state")
+	q := c.Query("q")
+
+	sortParam := c.DefaultQuery("sort", "name")
 
-	// Pagination parameters
 	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
 	if page < 1 {
 		page = 1
@@ -30,39 +52,78 @@ func GetParkingLots(c *gin.Context) {
 		limit = 10
 	}
 
-	offset := (page - 1) * limit
+	crit := criteria.ParkingLotCriteria{
+		City:  city,
+		State: state,
+		Q:     q,
+		Sort:  sortParam,
+	}
+
+	sortColumn, cursorEligible := lotSortColumns[sortParam]
+	var cursorKeys []string
+	var cursor pagination.Cursor
+	reverse := c.Query("direction") == "prev"
+	if cursorEligible {
+		cursorKeys = []string{sortColumn, "id"}
+		if raw := c.Query("cursor"); raw != "" {
+			var err error
+			cursor, err = pagination.Decode(raw, cursorKeys...)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+		}
+		if reverse {
+			crit.Direction = criteria.SortDescending
+		} else {
+			crit.Direction = criteria.SortAscending
+		}
+	}
+
+	where, queryParams := crit.Where()
+	countQuery := "SELECT COUNT(*) FROM parking_lots" + where
+	countParamCount := len(queryParams)
 
-	// Build the query based on filters
 	query := "SELECT id, name, address, city, state, zip_code, latitude, longitude, " +
 		"total_spaces, hourly_rate, open_time, close_time, is_open_24h, created_at, updated_at " +
-		"FROM parking_lots WHERE 1=1"
+		"FROM parking_lots" + where
 
-	countQuery := "SELECT COUNT(*) FROM parking_lots WHERE 1=1"
+	paramIndex := len(queryParams) + 1
+	orderBy, orderArgs := crit.OrderBy()
+	queryParams = append(queryParams, orderArgs...)
+	paramIndex += len(orderArgs)
 
-	var queryParams []interface{}
-	var paramIndex int = 1
-
-	if city != "" {
-		query += " AND city = $" + strconv.Itoa(paramIndex)
-		countQuery += " AND city = $" + strconv.Itoa(paramIndex)
-		queryParams = append(queryParams, city)
-		paramIndex++
+	if cursor != nil {
+		var clause string
+		clause, queryParams, paramIndex = pagination.Predicate(
+			[]string{sortColumn, "id"}, cursorKeys, cursor, reverse, queryParams, paramIndex,
+		)
+		if where == "" {
+			query += " WHERE " + clause
+		} else {
+			query += " AND " + clause
+		}
 	}
 
-	if state != "" {
-		query += " AND state = $" + strconv.Itoa(paramIndex)
-		countQuery += " AND state = $" + strconv.Itoa(paramIndex)
-		queryParams = append(queryParams, state)
+	query += " ORDER BY " + orderBy + " LIMIT $" + strconv.Itoa(paramIndex)
+	queryParams = append(queryParams, limit)
+	paramIndex++
+
+	// Clients that never send a cursor keep using page/limit via OFFSET,
+	// exactly as before - the cursor only takes over once the client
+	// starts threading nextCursor/prevCursor back in.
+	offset := (page - 1) * limit
+	if cursor == nil {
+		query += " OFFSET $" + strconv.Itoa(paramIndex)
+		queryParams = append(queryParams, offset)
 		paramIndex++
 	}
 
-	// Add order by and pagination
-	query += " ORDER BY name ASC LIMIT $" + strconv.Itoa(paramIndex) + " OFFSET $" + strconv.Itoa(paramIndex+1)
-	queryParams = append(queryParams, limit, offset)
-
-	// Get total count for pagination
+	// Get total count for pagination (the legacy page/limit fields keep
+	// working; they're meaningless once a cursor replaces offset, but
+	// still cheap enough to compute against the same filters)
 	var totalCount int
-	err := database.DB.QueryRow(countQuery, queryParams[:paramIndex-1]...).Scan(&totalCount)
+	err := database.App.QueryRow(countQuery, queryParams[:countParamCount]...).Scan(&totalCount)
 	if err != nil {
 		log.Printf("Error counting parking lots: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch parking lots"})
@@ -70,7 +131,7 @@ func GetParkingLots(c *gin.Context) {
 	}
 
 	// Execute the main query
-	rows, err := database.DB.Query(query, queryParams...)
+	rows, err := database.App.Query(query, queryParams...)
 	if err != nil {
 		log.Printf("Error fetching parking lots: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch parking lots"})
@@ -112,6 +173,28 @@ func GetParkingLots(c *gin.Context) {
 		return
 	}
 
+	// A "prev" page is fetched in descending order so it's the rows
+	// immediately before the cursor, not the start of the table; put the
+	// slice back in ascending order before returning it.
+	if reverse {
+		for i, j := 0, len(parkingLots)-1; i < j; i, j = i+1, j-1 {
+			parkingLots[i], parkingLots[j] = parkingLots[j], parkingLots[i]
+		}
+	}
+
+	var nextCursor, prevCursor string
+	if cursorEligible && len(parkingLots) > 0 {
+		first, last := parkingLots[0], parkingLots[len(parkingLots)-1]
+		nextCursor, err = pagination.Encode(lotCursor(last, sortColumn))
+		if err != nil {
+			log.Printf("Error encoding next cursor: %v", err)
+		}
+		prevCursor, err = pagination.Encode(lotCursor(first, sortColumn))
+		if err != nil {
+			log.Printf("Error encoding prev cursor: %v", err)
+		}
+	}
+
 	// Calculate pagination metadata
 	totalPages := (totalCount + limit - 1) / limit
 
@@ -123,6 +206,94 @@ func GetParkingLots(c *gin.Context) {
 			"limit":      limit,
 			"totalPages": totalPages,
 		},
+		"nextCursor": nextCursor,
+		"prevCursor": prevCursor,
+	})
+}
+
+// lotCursor builds the sort key pagination.Cursor for lot, using
+// sortColumn as the primary key and id as the tie-breaker - the same
+// tuple lotSortColumns/GetParkingLots' ORDER BY and cursor predicate use.
+func lotCursor(lot models.ParkingLot, sortColumn string) pagination.Cursor {
+	var primary interface{}
+	switch sortColumn {
+	case "created_at":
+		primary = lot.CreatedAt
+	default:
+		primary = lot.Name
+	}
+	return pagination.Cursor{sortColumn: primary, "id": lot.ID}
+}
+
+// GetNearbyParkingLots handles finding the parking lots closest to a given
+// point, via repositories.ParkingLotRepository so the query runs against
+// PostGIS or the SQLite R-tree depending on the active driver. Accepts
+// radius_km (or the legacy radius in meters), vehicle_type, available_only
+// (or the legacy available), and open_now - evaluated against open_time/
+// close_time/is_open_24h in the timezone named by tz, defaulting to UTC.
+func GetNearbyParkingLots(c *gin.Context) {
+	lat, err := strconv.ParseFloat(c.Query("lat"), 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "lat is required and must be a valid number"})
+		return
+	}
+
+	// lon is the documented param name; lng is kept as a fallback for
+	// existing callers of this endpoint.
+	lonParam := c.Query("lon")
+	if lonParam == "" {
+		lonParam = c.Query("lng")
+	}
+	lon, err := strconv.ParseFloat(lonParam, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "lon is required and must be a valid number"})
+		return
+	}
+
+	radius, err := strconv.Atoi(c.DefaultQuery("radius", "5000"))
+	if err != nil || radius <= 0 {
+		radius = 5000
+	}
+	// radius_km is the documented param name; radius (meters) is kept as a
+	// fallback for existing callers of this endpoint.
+	if radiusKm, err := strconv.ParseFloat(c.Query("radius_km"), 64); err == nil && radiusKm > 0 {
+		radius = int(radiusKm * 1000)
+	}
+
+	var filters repositories.NearbyFilters
+	if isOpen24h := c.Query("is_open_24h"); isOpen24h != "" {
+		value := isOpen24h == "true"
+		filters.IsOpen24H = &value
+	}
+	// available_only is the documented param name; available is kept as a
+	// fallback for existing callers of this endpoint.
+	filters.OnlyAvailable = c.Query("available") == "true" || c.Query("available_only") == "true"
+	if maxHourlyRate, err := strconv.ParseFloat(c.Query("maxHourlyRate"), 64); err == nil {
+		filters.MaxHourlyRate = maxHourlyRate
+	}
+	filters.VehicleType = c.Query("vehicle_type")
+
+	if c.Query("open_now") == "true" {
+		loc := time.UTC
+		if tz := c.Query("tz"); tz != "" {
+			if parsed, err := time.LoadLocation(tz); err == nil {
+				loc = parsed
+			}
+		}
+		timeOfDay := time.Now().In(loc).Format("15:04:05")
+		filters.OpenNowAt = &timeOfDay
+	}
+
+	lots, err := repositories.NewParkingLotRepository().FindNearby(c.Request.Context(), database.App, lat, lon, radius, filters)
+	if err != nil {
+		log.Printf("Error finding nearby parking lots: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to find nearby parking lots"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"parkingLots": lots,
+		"count":       len(lots),
 	})
 }
 
@@ -136,7 +307,7 @@ func GetParkingLotByID(c *gin.Context) {
 
 	// Query the database for the parking lot
 	var lot models.ParkingLot
-	err := database.DB.QueryRow(
+	err := database.App.QueryRow(
 		"SELECT id, name, address, city, state, zip_code, latitude, longitude, "+
 			"total_spaces, hourly_rate, open_time, close_time, is_open_24h, created_at, updated_at "+
 			"FROM parking_lots WHERE id = $1",
@@ -176,6 +347,14 @@ func GetParkingSpacesByLotID(c *gin.Context) {
 		return
 	}
 
+	sortParam := c.DefaultQuery("sort", "space_number")
+	sortColumn, ok := spaceSortColumns[sortParam]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid sort parameter"})
+		return
+	}
+	cursorKeys := []string{sortColumn, "id"}
+
 	// Parse query parameters for filtering and pagination
 	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
 	if page < 1 {
@@ -189,15 +368,49 @@ func GetParkingSpacesByLotID(c *gin.Context) {
 
 	offset := (page - 1) * limit
 
+	reverse := c.Query("direction") == "prev"
+	var cursor pagination.Cursor
+	if raw := c.Query("cursor"); raw != "" {
+		var err error
+		cursor, err = pagination.Decode(raw, cursorKeys...)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
 	// Build the query based on filters
 	query := "SELECT id, parking_lot_id, space_number, floor, type, is_occupied, last_updated, created_at, updated_at " +
-		"FROM parking_spaces WHERE parking_lot_id = $1 ORDER BY space_number ASC LIMIT $2 OFFSET $3"
+		"FROM parking_spaces WHERE parking_lot_id = $1"
 
-	var queryParams []interface{}
-	queryParams = append(queryParams, id, limit, offset)
+	queryParams := []interface{}{id}
+	paramIndex := 2
+
+	if cursor != nil {
+		var clause string
+		clause, queryParams, paramIndex = pagination.Predicate(
+			cursorKeys, cursorKeys, cursor, reverse, queryParams, paramIndex,
+		)
+		query += " AND " + clause
+	}
+
+	orderDir := "ASC"
+	if reverse {
+		orderDir = "DESC"
+	}
+	query += " ORDER BY " + sortColumn + " " + orderDir + ", id " + orderDir +
+		" LIMIT $" + strconv.Itoa(paramIndex)
+	queryParams = append(queryParams, limit)
+	paramIndex++
+
+	if cursor == nil {
+		query += " OFFSET $" + strconv.Itoa(paramIndex)
+		queryParams = append(queryParams, offset)
+		paramIndex++
+	}
 
 	// Execute the query
-	rows, err := database.DB.Query(query, queryParams...)
+	rows, err := database.App.Query(query, queryParams...)
 	if err != nil {
 		log.Printf("Error fetching parking spaces: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching parking spaces"})
@@ -232,7 +445,49 @@ func GetParkingSpacesByLotID(c *gin.Context) {
 		parkingSpaces = append(parkingSpaces, space)
 	}
 
-	c.JSON(http.StatusOK, parkingSpaces)
+	if reverse {
+		for i, j := 0, len(parkingSpaces)-1; i < j; i, j = i+1, j-1 {
+			parkingSpaces[i], parkingSpaces[j] = parkingSpaces[j], parkingSpaces[i]
+		}
+	}
+
+	var nextCursor, prevCursor string
+	if len(parkingSpaces) > 0 {
+		first, last := parkingSpaces[0], parkingSpaces[len(parkingSpaces)-1]
+		if enc, err := pagination.Encode(spaceCursor(last, sortColumn)); err == nil {
+			nextCursor = enc
+		}
+		if enc, err := pagination.Encode(spaceCursor(first, sortColumn)); err == nil {
+			prevCursor = enc
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"parkingSpaces": parkingSpaces,
+		"nextCursor":    nextCursor,
+		"prevCursor":    prevCursor,
+	})
+}
+
+// spaceSortColumns maps the "sort" query param GetParkingSpaces,
+// GetParkingSpacesByLotID, and FilterParkingSpaces accept to the column
+// it orders by; id is always appended as a tie-breaker.
+var spaceSortColumns = map[string]string{
+	"space_number": "space_number",
+	"created_at":   "created_at",
+}
+
+// spaceCursor builds the sort key pagination.Cursor for space, using
+// sortColumn as the primary key and id as the tie-breaker.
+func spaceCursor(space models.ParkingSpace, sortColumn string) pagination.Cursor {
+	var primary interface{}
+	switch sortColumn {
+	case "created_at":
+		primary = space.CreatedAt
+	default:
+		primary = space.SpaceNumber
+	}
+	return pagination.Cursor{sortColumn: primary, "id": space.ID}
 }
 
 // GetParkingSpaceByLotID handles fetching all parking spaces by Lot ID
@@ -244,7 +499,7 @@ func GetParkingSpaceByLotID(c *gin.Context) {
 	}
 
 	// Query all parking spaces for the lot
-	rows, err := database.DB.Query(
+	rows, err := database.App.Query(
 		`SELECT id, parking_lot_id, space_number, floor, type, is_occupied, last_updated, created_at, updated_at 
 		FROM parking_spaces WHERE parking_lot_id = $1`, id)
 	if err != nil {
@@ -284,7 +539,7 @@ func GetParkingSpaceByLotID(c *gin.Context) {
 
 	// Get parking lot name (pakai id param langsung)
 	var lotName string
-	err = database.DB.QueryRow(
+	err = database.App.QueryRow(
 		"SELECT name FROM parking_lots WHERE id = $1", id,
 	).Scan(&lotName)
 	if err != nil {
@@ -307,7 +562,7 @@ func GetParkingSpaceByLotID(c *gin.Context) {
 		var hasActiveReservation bool
 		var reservationEndTime time.Time
 
-		err = database.DB.QueryRow(
+		err = database.App.QueryRow(
 			`SELECT EXISTS(
 				SELECT 1 FROM reservations 
 				WHERE parking_space_id = $1 AND status = 'active' AND end_time > $2
@@ -348,6 +603,14 @@ func GetParkingSpaces(c *gin.Context) {
 	spaceType := c.Query("type")
 	availability := c.Query("availability") // "all", "available", "occupied"
 
+	sortParam := c.DefaultQuery("sort", "space_number")
+	sortColumn, ok := spaceSortColumns[sortParam]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid sort parameter"})
+		return
+	}
+	cursorKeys := []string{sortColumn, "id"}
+
 	// Pagination parameters
 	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
 	if page < 1 {
@@ -361,6 +624,17 @@ func GetParkingSpaces(c *gin.Context) {
 
 	offset := (page - 1) * limit
 
+	reverse := c.Query("direction") == "prev"
+	var cursor pagination.Cursor
+	if raw := c.Query("cursor"); raw != "" {
+		var err error
+		cursor, err = pagination.Decode(raw, cursorKeys...)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
 	// Build the query based on filters
 	query := "SELECT id, parking_lot_id, space_number, floor, type, is_occupied, last_updated, created_at, updated_at " +
 		"FROM parking_spaces WHERE 1=1"
@@ -402,13 +676,34 @@ func GetParkingSpaces(c *gin.Context) {
 		countQuery += " AND is_occupied = true"
 	}
 
-	// Add order by and pagination
-	query += " ORDER BY space_number ASC LIMIT $" + strconv.Itoa(paramIndex) + " OFFSET $" + strconv.Itoa(paramIndex+1)
-	queryParams = append(queryParams, limit, offset)
+	countParamCount := paramIndex - 1
+
+	if cursor != nil {
+		var clause string
+		clause, queryParams, paramIndex = pagination.Predicate(
+			cursorKeys, cursorKeys, cursor, reverse, queryParams, paramIndex,
+		)
+		query += " AND " + clause
+	}
+
+	orderDir := "ASC"
+	if reverse {
+		orderDir = "DESC"
+	}
+	query += " ORDER BY " + sortColumn + " " + orderDir + ", id " + orderDir +
+		" LIMIT $" + strconv.Itoa(paramIndex)
+	queryParams = append(queryParams, limit)
+	paramIndex++
+
+	if cursor == nil {
+		query += " OFFSET $" + strconv.Itoa(paramIndex)
+		queryParams = append(queryParams, offset)
+		paramIndex++
+	}
 
 	// Get total count for pagination
 	var totalCount int
-	err := database.DB.QueryRow(countQuery, queryParams[:paramIndex-1]...).Scan(&totalCount)
+	err := database.App.QueryRow(countQuery, queryParams[:countParamCount]...).Scan(&totalCount)
 	if err != nil {
 		log.Printf("Error counting parking spaces: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch parking spaces"})
@@ -416,7 +711,7 @@ func GetParkingSpaces(c *gin.Context) {
 	}
 
 	// Execute the main query
-	rows, err := database.DB.Query(query, queryParams...)
+	rows, err := database.App.Query(query, queryParams...)
 	if err != nil {
 		log.Printf("Error fetching parking spaces: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch parking spaces"})
@@ -452,6 +747,23 @@ func GetParkingSpaces(c *gin.Context) {
 		return
 	}
 
+	if reverse {
+		for i, j := 0, len(parkingSpaces)-1; i < j; i, j = i+1, j-1 {
+			parkingSpaces[i], parkingSpaces[j] = parkingSpaces[j], parkingSpaces[i]
+		}
+	}
+
+	var nextCursor, prevCursor string
+	if len(parkingSpaces) > 0 {
+		first, last := parkingSpaces[0], parkingSpaces[len(parkingSpaces)-1]
+		if enc, err := pagination.Encode(spaceCursor(last, sortColumn)); err == nil {
+			nextCursor = enc
+		}
+		if enc, err := pagination.Encode(spaceCursor(first, sortColumn)); err == nil {
+			prevCursor = enc
+		}
+	}
+
 	// Calculate pagination metadata
 	totalPages := (totalCount + limit - 1) / limit
 
@@ -463,6 +775,8 @@ func GetParkingSpaces(c *gin.Context) {
 			"limit":      limit,
 			"totalPages": totalPages,
 		},
+		"nextCursor": nextCursor,
+		"prevCursor": prevCursor,
 	})
 }
 
@@ -476,7 +790,7 @@ func GetParkingSpaceByID(c *gin.Context) {
 
 	// Query the database for the parking space
 	var space models.ParkingSpace
-	err := database.DB.QueryRow(
+	err := database.App.QueryRow(
 		"SELECT id, parking_lot_id, space_number, floor, type, is_occupied, last_updated, created_at, updated_at "+
 			"FROM parking_spaces WHERE id = $1",
 		id,
@@ -499,7 +813,7 @@ func GetParkingSpaceByID(c *gin.Context) {
 
 	// Get parking lot information for this space
 	var lotName string
-	err = database.DB.QueryRow(
+	err = database.App.QueryRow(
 		"SELECT name FROM parking_lots WHERE id = $1",
 		space.ParkingLotID,
 	).Scan(&lotName)
@@ -512,7 +826,7 @@ func GetParkingSpaceByID(c *gin.Context) {
 	// Check if there's an active reservation for this space
 	var hasActiveReservation bool
 	var reservationEndTime time.Time
-	err = database.DB.QueryRow(
+	err = database.App.QueryRow(
 		"SELECT EXISTS(SELECT 1 FROM reservations WHERE parking_space_id = $1 AND status = 'active' AND end_time > $2), "+
 			"COALESCE((SELECT end_time FROM reservations WHERE parking_space_id = $1 AND status = 'active' AND end_time > $2 ORDER BY end_time ASC LIMIT 1), $2)",
 		id, time.Now(),
@@ -533,6 +847,15 @@ func GetParkingSpaceByID(c *gin.Context) {
 	})
 }
 
+// filterSpacesCursorColumns/filterSpacesCursorKeys are FilterParkingSpaces'
+// fixed sort tuple - it only ever orders by lot name then space number, so
+// unlike the other list handlers there's no "sort" query param to validate
+// against, just the one shape a cursor can take.
+var (
+	filterSpacesCursorColumns = []string{"pl.name", "ps.space_number", "ps.id"}
+	filterSpacesCursorKeys    = []string{"lot_name", "space_number", "id"}
+)
+
 // FilterParkingSpaces handles filtering and pagination for parking spaces
 func FilterParkingSpaces(c *gin.Context) {
 	// Get filter parameters
@@ -553,42 +876,69 @@ func FilterParkingSpaces(c *gin.Context) {
 
 	offset := (page - 1) * limit
 
-	// Build query
+	reverse := c.Query("direction") == "prev"
+	var cursor pagination.Cursor
+	if raw := c.Query("cursor"); raw != "" {
+		var err error
+		cursor, err = pagination.Decode(raw, filterSpacesCursorKeys...)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	crit := criteria.ParkingSpaceCriteria{
+		ParkingLotID:  parkingLotID,
+		AvailableOnly: availableOnly,
+		VehicleType:   vehicleType,
+	}
+	where, args := crit.Where()
+	countArgCount := len(args)
+
 	query := `
-		SELECT ps.id, ps.parking_lot_id, ps.space_number, ps.space_type, ps.is_occupied, 
-		       ps.is_reserved, ps.is_disabled_only, ps.hourly_rate, ps.created_at, ps.updated_at,
+		SELECT ps.id, ps.parking_lot_id, ps.space_number, ps.type, ps.is_occupied,
+		       ps.is_blocked, pl.hourly_rate, ps.created_at, ps.updated_at,
 		       pl.name AS parking_lot_name, pl.address
 		FROM parking_spaces ps
-		JOIN parking_lots pl ON ps.parking_lot_id = pl.id
-		WHERE 1=1
-	`
+		JOIN parking_lots pl ON ps.parking_lot_id = pl.id` + where
 
-	args := []interface{}{}
-	argIndex := 1
+	countQuery := `
+		SELECT COUNT(*)
+		FROM parking_spaces ps
+		JOIN parking_lots pl ON ps.parking_lot_id = pl.id` + where
 
-	// Add filters
-	if parkingLotID != "" {
-		query += " AND ps.parking_lot_id = $" + strconv.Itoa(argIndex)
-		args = append(args, parkingLotID)
-		argIndex++
+	argIndex := len(args) + 1
+	if cursor != nil {
+		var clause string
+		clause, args, argIndex = pagination.Predicate(
+			filterSpacesCursorColumns, filterSpacesCursorKeys, cursor, reverse, args, argIndex,
+		)
+		if where == "" {
+			query += " WHERE " + clause
+		} else {
+			query += " AND " + clause
+		}
 	}
 
-	if availableOnly {
-		query += " AND ps.is_occupied = false AND ps.is_reserved = false"
+	orderDir := "ASC"
+	if reverse {
+		orderDir = "DESC"
 	}
 
-	if vehicleType != "" {
-		query += " AND ps.space_type = $" + strconv.Itoa(argIndex)
-		args = append(args, vehicleType)
+	// Add sorting and pagination
+	query += " ORDER BY pl.name " + orderDir + ", ps.space_number " + orderDir + ", ps.id " + orderDir +
+		" LIMIT $" + strconv.Itoa(argIndex)
+	args = append(args, limit)
+	argIndex++
+
+	if cursor == nil {
+		query += " OFFSET $" + strconv.Itoa(argIndex)
+		args = append(args, offset)
 		argIndex++
 	}
 
-	// Add sorting and pagination
-	query += " ORDER BY pl.name, ps.space_number LIMIT $" + strconv.Itoa(argIndex) + " OFFSET $" + strconv.Itoa(argIndex+1)
-	args = append(args, limit, offset)
-
 	// Execute query
-	rows, err := database.DB.Query(query, args...)
+	rows, err := database.App.Query(query, args...)
 	if err != nil {
 		log.Printf("Error querying parking spaces: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch parking spaces"})
@@ -600,13 +950,13 @@ func FilterParkingSpaces(c *gin.Context) {
 	parkingSpaces := []gin.H{}
 	for rows.Next() {
 		var id, parkingLotID, spaceNumber, spaceType, parkingLotName, address string
-		var isOccupied, isReserved, isDisabledOnly bool
+		var isOccupied, isBlocked bool
 		var hourlyRate float64
 		var createdAt, updatedAt time.Time
 
 		err := rows.Scan(
 			&id, &parkingLotID, &spaceNumber, &spaceType, &isOccupied,
-			&isReserved, &isDisabledOnly, &hourlyRate, &createdAt, &updatedAt,
+			&isBlocked, &hourlyRate, &createdAt, &updatedAt,
 			&parkingLotName, &address,
 		)
 
@@ -621,8 +971,7 @@ func FilterParkingSpaces(c *gin.Context) {
 			"space_number":     spaceNumber,
 			"space_type":       spaceType,
 			"is_occupied":      isOccupied,
-			"is_reserved":      isReserved,
-			"is_disabled_only": isDisabledOnly,
+			"is_blocked":       isBlocked,
 			"hourly_rate":      hourlyRate,
 			"created_at":       createdAt,
 			"updated_at":       updatedAt,
@@ -637,18 +986,29 @@ func FilterParkingSpaces(c *gin.Context) {
 		return
 	}
 
-	// Get total count for pagination
-	countQuery := strings.Replace(query, `
-		SELECT ps.id, ps.parking_lot_id, ps.space_number, ps.space_type, ps.is_occupied, 
-		       ps.is_reserved, ps.is_disabled_only, ps.hourly_rate, ps.created_at, ps.updated_at,
-		       pl.name AS parking_lot_name, pl.address`,
-		"SELECT COUNT(*)", 1)
+	if reverse {
+		for i, j := 0, len(parkingSpaces)-1; i < j; i, j = i+1, j-1 {
+			parkingSpaces[i], parkingSpaces[j] = parkingSpaces[j], parkingSpaces[i]
+		}
+	}
 
-	// Remove ORDER BY and LIMIT clauses for count query
-	countQuery = countQuery[:strings.LastIndex(countQuery, "ORDER BY")]
+	var nextCursor, prevCursor string
+	if len(parkingSpaces) > 0 {
+		first, last := parkingSpaces[0], parkingSpaces[len(parkingSpaces)-1]
+		if enc, err := pagination.Encode(filterSpaceCursor(last)); err == nil {
+			nextCursor = enc
+		}
+		if enc, err := pagination.Encode(filterSpaceCursor(first)); err == nil {
+			prevCursor = enc
+		}
+	}
 
+	// Get total count for pagination - countQuery shares crit.Where() with
+	// the SELECT above (rather than deriving one from the other) so
+	// "total" always means "rows matching the filters", consistent
+	// regardless of which page a cursor has walked to.
 	var totalCount int
-	err = database.DB.QueryRow(countQuery, args[:len(args)-2]...).Scan(&totalCount)
+	err = database.App.QueryRow(countQuery, args[:countArgCount]...).Scan(&totalCount)
 	if err != nil {
 		log.Printf("Error counting parking spaces: %v", err)
 		totalCount = len(parkingSpaces)
@@ -660,5 +1020,194 @@ func FilterParkingSpaces(c *gin.Context) {
 		"page":           page,
 		"limit":          limit,
 		"total_pages":    (totalCount + limit - 1) / limit,
+		"next_cursor":    nextCursor,
+		"prev_cursor":    prevCursor,
+	})
+}
+
+// filterSpaceCursor builds the sort key pagination.Cursor for one of
+// FilterParkingSpaces' gin.H result rows, matching filterSpacesCursorKeys.
+func filterSpaceCursor(row gin.H) pagination.Cursor {
+	return pagination.Cursor{
+		"lot_name":     row["parking_lot_name"],
+		"space_number": row["space_number"],
+		"id":           row["id"],
+	}
+}
+
+// fetchSpace loads a single parking space by id, scanning the same column
+// list as GetParkingSpaceByID.
+func fetchSpace(id string) (*models.ParkingSpace, error) {
+	var space models.ParkingSpace
+	err := database.App.QueryRow(
+		"SELECT id, parking_lot_id, space_number, floor, type, is_occupied, last_updated, created_at, updated_at "+
+			"FROM parking_spaces WHERE id = $1",
+		id,
+	).Scan(
+		&space.ID,
+		&space.ParkingLotID,
+		&space.SpaceNumber,
+		&space.Floor,
+		&space.Type,
+		&space.IsOccupied,
+		&space.LastUpdated,
+		&space.CreatedAt,
+		&space.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &space, nil
+}
+
+// fetchAllSpacesForLot loads every parking space belonging to lotID, used
+// for a stream's initial snapshot event.
+func fetchAllSpacesForLot(lotID string) ([]models.ParkingSpace, error) {
+	rows, err := database.App.Query(
+		"SELECT id, parking_lot_id, space_number, floor, type, is_occupied, last_updated, created_at, updated_at "+
+			"FROM parking_spaces WHERE parking_lot_id = $1 ORDER BY space_number",
+		lotID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var spaces []models.ParkingSpace
+	for rows.Next() {
+		var space models.ParkingSpace
+		if err := rows.Scan(
+			&space.ID,
+			&space.ParkingLotID,
+			&space.SpaceNumber,
+			&space.Floor,
+			&space.Type,
+			&space.IsOccupied,
+			&space.LastUpdated,
+			&space.CreatedAt,
+			&space.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		spaces = append(spaces, space)
+	}
+	return spaces, rows.Err()
+}
+
+// GetParkingSpacesStreamByLotID streams live updates for every space in a
+// lot as Server-Sent Events: an initial "snapshot" event with the full
+// current list, then an "update" event per space each time
+// internal/occupancy.Current reports a change. The handler closes the
+// stream itself - via ctx, rebuilt with a fresh deadline after every event
+// or keepalive - once Stream.IdleTimeoutSeconds passes with nothing to
+// report, or when the client disconnects.
+func GetParkingSpacesStreamByLotID(c *gin.Context) {
+	lotID := c.Param("id")
+	if lotID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Parking Lot ID is required"})
+		return
+	}
+
+	spaces, err := fetchAllSpacesForLot(lotID)
+	if err != nil {
+		log.Printf("Error fetching parking spaces for stream: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching parking spaces"})
+		return
+	}
+
+	streamCfg := config.Get().Stream
+	idleTimeout := time.Duration(streamCfg.IdleTimeoutSeconds) * time.Second
+	keepalive := time.NewTicker(time.Duration(streamCfg.KeepaliveIntervalSeconds) * time.Second)
+	defer keepalive.Stop()
+
+	events := occupancy.Current.Subscribe(lotID)
+	defer occupancy.Current.Unsubscribe(lotID, events)
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), idleTimeout)
+	defer cancel()
+
+	c.SSEvent("snapshot", spaces)
+	c.Writer.Flush()
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case ev, ok := <-events:
+			if !ok {
+				return false
+			}
+			cancel()
+			ctx, cancel = context.WithTimeout(c.Request.Context(), idleTimeout)
+			space, err := fetchSpace(ev.SpaceID)
+			if err != nil {
+				log.Printf("Error fetching updated parking space %s: %v", ev.SpaceID, err)
+				return true
+			}
+			c.SSEvent("update", space)
+			return true
+		case <-keepalive.C:
+			c.SSEvent("keepalive", nil)
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	})
+}
+
+// GetParkingSpaceStream streams live updates for a single parking space as
+// Server-Sent Events, the same idle-deadline and keepalive shape as
+// GetParkingSpacesStreamByLotID but subscribed to the space's own lot and
+// filtered down to just that space's events.
+func GetParkingSpaceStream(c *gin.Context) {
+	id := c.Param("id")
+	if id == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Parking space ID is required"})
+		return
+	}
+
+	space, err := fetchSpace(id)
+	if err != nil {
+		log.Printf("Error fetching parking space for stream: %v", err)
+		c.JSON(http.StatusNotFound, gin.H{"error": "Parking space not found"})
+		return
+	}
+
+	streamCfg := config.Get().Stream
+	idleTimeout := time.Duration(streamCfg.IdleTimeoutSeconds) * time.Second
+	keepalive := time.NewTicker(time.Duration(streamCfg.KeepaliveIntervalSeconds) * time.Second)
+	defer keepalive.Stop()
+
+	events := occupancy.Current.Subscribe(space.ParkingLotID)
+	defer occupancy.Current.Unsubscribe(space.ParkingLotID, events)
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), idleTimeout)
+	defer cancel()
+
+	c.SSEvent("snapshot", space)
+	c.Writer.Flush()
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case ev, ok := <-events:
+			if !ok {
+				return false
+			}
+			cancel()
+			ctx, cancel = context.WithTimeout(c.Request.Context(), idleTimeout)
+			if ev.SpaceID != id {
+				return true
+			}
+			updated, err := fetchSpace(id)
+			if err != nil {
+				log.Printf("Error fetching updated parking space %s: %v", id, err)
+				return true
+			}
+			c.SSEvent("update", updated)
+			return true
+		case <-keepalive.C:
+			c.SSEvent("keepalive", nil)
+			return true
+		case <-ctx.Done():
+			return false
+		}
 	})
 }
@@ -2,6 +2,8 @@ package handlers
 
 import (
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
 	"time"
@@ -11,6 +13,9 @@ import (
 
 	"github.com/holycan/smart-parking-system/database"
 	"github.com/holycan/smart-parking-system/models"
+	"github.com/holycan/smart-parking-system/services"
+	"github.com/holycan/smart-parking-system/services/audit"
+	fsm "github.com/holycan/smart-parking-system/services/booking"
 	"github.com/holycan/smart-parking-system/utils"
 )
 
@@ -24,7 +29,7 @@ func GetUserReservations(c *gin.Context) {
 	}
 
 	// Query upcoming reservations
-	rows, err := database.DB.Query(`
+	rows, err := database.App.Query(`
 		SELECT r.id, r.parking_lot_id, r.parking_space_id, r.duration, vehicle_type, license_plate, reservation_date, expired_at, checkin_time,
 		       r.status, r.total_cost, r.payment_status, r.created_at, r.updated_at,
 		       pl.name AS parking_lot_name, ps.space_number
@@ -37,7 +42,7 @@ func GetUserReservations(c *gin.Context) {
 
 	if err != nil {
 		log.Printf("Error querying upcoming reservations: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch upcoming reservations"})
+		utils.RespondDBError(c, err)
 		return
 	}
 	defer rows.Close()
@@ -128,10 +133,10 @@ func GetReservationDetails(c *gin.Context) {
 	queryParams = append(queryParams, userID, c.Param("id"))
 
 	// Execute the main query
-	rows, err := database.DB.Query(query, queryParams...)
+	rows, err := database.App.Query(query, queryParams...)
 	if err != nil {
 		log.Printf("Error fetching user reservations: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch reservations"})
+		utils.RespondDBError(c, err)
 		return
 	}
 	defer rows.Close()
@@ -203,16 +208,38 @@ func CreateReservation(c *gin.Context) {
 		return
 	}
 
+	// If a pre-registered vehicle was given, hydrate the plate/type from the
+	// garage instead of trusting free-text fields in the request
+	if req.VehicleID != "" {
+		vehicle, err := getOwnedVehicle(req.VehicleID, userID.(string))
+		if err != nil {
+			log.Printf("Error fetching vehicle: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to validate vehicle"})
+			return
+		}
+		if vehicle == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Vehicle not found"})
+			return
+		}
+		req.VehicleType = vehicle.Type
+		req.LicensePlate = vehicle.LicensePlate
+	}
+
+	if req.VehicleType == "" || req.LicensePlate == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "vehicleId, or both vehicleType and licensePlate, is required"})
+		return
+	}
+
 	// Validate that the parking space exists and belongs to the specified parking lot
 	var spaceExists bool
-	err := database.DB.QueryRow(
+	err := database.App.QueryRow(
 		"SELECT EXISTS(SELECT 1 FROM parking_spaces WHERE id = $1 AND parking_lot_id = $2)",
 		req.ParkingSpaceID, req.ParkingLotID,
 	).Scan(&spaceExists)
 
 	if err != nil {
 		log.Printf("Error checking parking space: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to validate parking space"})
+		utils.RespondDBError(c, err)
 		return
 	}
 
@@ -222,7 +249,7 @@ func CreateReservation(c *gin.Context) {
 	}
 
 	// Begin transaction
-	tx, err := database.DB.Begin()
+	tx, err := database.App.Begin()
 	if err != nil {
 		log.Printf("Error beginning transaction: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create reservation"})
@@ -240,14 +267,19 @@ func CreateReservation(c *gin.Context) {
 	req.ExpiredAt = parsedDate.AddDate(0, 0, 1)
 
 	// Insert the reservation
+	var vehicleID sql.NullString
+	if req.VehicleID != "" {
+		vehicleID = sql.NullString{String: req.VehicleID, Valid: true}
+	}
+
 	_, err = tx.Exec(`
 		INSERT INTO reservations (
-			id, user_id, parking_lot_id, parking_space_id, vehicle_type, license_plate, reservation_date, expired_at,
+			id, user_id, parking_lot_id, parking_space_id, vehicle_id, vehicle_type, license_plate, reservation_date, expired_at,
 			duration, status, total_cost, payment_status,
 			created_at
-		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
 	`,
-		reservationID, userID, req.ParkingLotID, req.ParkingSpaceID, req.VehicleType, req.LicensePlate, req.ReservationDate, req.ExpiredAt,
+		reservationID, userID, req.ParkingLotID, req.ParkingSpaceID, vehicleID, req.VehicleType, req.LicensePlate, req.ReservationDate, req.ExpiredAt,
 		req.Duration, "pending", req.TotalCost, "pending",
 		time.Now(),
 	)
@@ -255,13 +287,23 @@ func CreateReservation(c *gin.Context) {
 	if err != nil {
 		tx.Rollback()
 		log.Printf("Error inserting reservation: %v", err)
+		utils.RespondDBError(c, err)
+		return
+	}
+
+	if err := audit.RecordEvent(tx, reservationID, userID.(string), "", "created", map[string]interface{}{
+		"parkingLotId":   req.ParkingLotID,
+		"parkingSpaceId": req.ParkingSpaceID,
+	}); err != nil {
+		tx.Rollback()
+		log.Printf("Error recording reservation event: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create reservation"})
 		return
 	}
 
 	// Get parking space name
 	var parkingSpaceName string
-	err = database.DB.QueryRow("SELECT space_number FROM parking_spaces WHERE id = $1", req.ParkingSpaceID).Scan(&parkingSpaceName)
+	err = database.App.QueryRow("SELECT space_number FROM parking_spaces WHERE id = $1", req.ParkingSpaceID).Scan(&parkingSpaceName)
 	if err != nil {
 		log.Printf("Error fetching parking space name: %v", err)
 	}
@@ -269,7 +311,7 @@ func CreateReservation(c *gin.Context) {
 	// Commit the transaction
 	if err = tx.Commit(); err != nil {
 		log.Printf("Error committing transaction: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		utils.RespondDBError(c, err)
 		return
 	}
 
@@ -280,6 +322,18 @@ func CreateReservation(c *gin.Context) {
 		return
 	}
 
+	// Arm the reservation's expiry deadline so services.Expiry auto-expires
+	// it (and frees the spot) if the driver never checks in.
+	services.Expiry.Register(reservationID, userID.(string), req.ExpiredAt)
+
+	utils.WsManager.HandleReservationHistoryEvent(models.ReservationHistoryEvent{
+		ReservationID:  reservationID,
+		ActorUserID:    userID.(string),
+		PreviousStatus: "",
+		NewStatus:      "created",
+		OccurredAt:     time.Now(),
+	})
+
 	utils.WsManager.HandleReservationAdded(models.Reservation{
 		ID:              reservationID,
 		UserID:          userID.(string),
@@ -332,35 +386,43 @@ func CancelReservation(c *gin.Context) {
 	}
 
 	// Check if reservation exists and belongs to user
-	var existsAndOwned bool
-	err := database.DB.QueryRow(`
-		SELECT EXISTS (
-			SELECT 1 FROM reservations
-			WHERE id = $1 AND user_id = $2 AND status != 'cancelled'
-		)
-	`, reservationID, userID).Scan(&existsAndOwned)
+	var previousStatus string
+	err := database.App.QueryRow(`
+		SELECT status FROM reservations
+		WHERE id = $1 AND user_id = $2 AND status != 'cancelled'
+	`, reservationID, userID).Scan(&previousStatus)
 
 	if err != nil {
 		log.Printf("Error checking reservation ownership: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		utils.RespondDBError(c, err)
 		return
 	}
 
-	if !existsAndOwned {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Reservation not found or already cancelled"})
+	// Route the cancellation through the same transition table ProcessCheckIn
+	// and the expiry watcher use, instead of a raw status update - this is
+	// what makes lifecycle_state (not just the legacy status column) reflect
+	// Cancelled, so a check-in queued before the cancel but delivered after
+	// it is rejected as ErrInvalidTransition rather than still finding the
+	// booking sitting in a state check-in is valid from. EventCancel also
+	// releases the space itself if the booking had already reached
+	// SpotLocked (see fsm.go's dedicated release-on-cancel branch).
+	if _, err := fsm.TransitionBooking(c.Request.Context(), reservationID, userID.(string), fsm.EventCancel, nil, nil); err != nil {
+		log.Printf("Error cancelling booking %s: %v", reservationID, err)
+		if errors.Is(err, fsm.ErrInvalidTransition) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Reservation can no longer be cancelled"})
+			return
+		}
+		utils.RespondDBError(c, err)
 		return
 	}
 
-	// Update reservation status to cancelled
-	_, err = database.DB.Exec(`
-		UPDATE reservations SET status = 'cancelled', updated_at = NOW()
-		WHERE id = $1
-	`, reservationID)
+	// Disarm the reservation-window deadline so a cancellation can't lose the
+	// race to services.Expiry and come back as "expired" - see
+	// ExpiryWatcher.Cancel's other caller in ProcessCheckIn.
+	services.Expiry.Cancel(reservationID)
 
-	if err != nil {
-		log.Printf("Error updating reservation status: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel reservation"})
-		return
+	if err := audit.RecordEvent(database.App, reservationID, userID.(string), previousStatus, "cancelled", nil); err != nil {
+		log.Printf("Error recording reservation event: %v", err)
 	}
 
 	// Notify via WebSocket
@@ -370,6 +432,14 @@ func CancelReservation(c *gin.Context) {
 		Status: "cancelled",
 	})
 
+	utils.WsManager.HandleReservationHistoryEvent(models.ReservationHistoryEvent{
+		ReservationID:  reservationID,
+		ActorUserID:    userID.(string),
+		PreviousStatus: previousStatus,
+		NewStatus:      "cancelled",
+		OccurredAt:     time.Now(),
+	})
+
 	c.JSON(http.StatusOK, gin.H{
 		"message":       "Reservation cancelled successfully",
 		"reservationId": reservationID,
@@ -393,8 +463,9 @@ func GetReservationByID(c *gin.Context) {
 
 	// Query the database for the reservation
 	var r models.Reservation
-	err := database.DB.QueryRow(`
-		SELECT id, user_id, parking_lot_id, parking_space_id, vehicle_type, license_plate,
+	var vehicleID sql.NullString
+	err := database.App.QueryRow(`
+		SELECT id, user_id, parking_lot_id, parking_space_id, vehicle_id, vehicle_type, license_plate,
 		       duration, status, total_cost, payment_status,
 		       created_at, updated_at
 		FROM reservations
@@ -404,6 +475,7 @@ func GetReservationByID(c *gin.Context) {
 		&r.UserID,
 		&r.ParkingLotID,
 		&r.ParkingSpaceID,
+		&vehicleID,
 		&r.VehicleType,
 		&r.LicensePlate,
 		&r.Duration,
@@ -413,39 +485,36 @@ func GetReservationByID(c *gin.Context) {
 		&r.UpdatedAt,
 	)
 
-	if err == sql.ErrNoRows {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Reservation not found"})
-		return
-	} else if err != nil {
+	if err != nil {
 		log.Printf("Error fetching reservation: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch reservation"})
+		utils.RespondDBError(c, err)
 		return
 	}
 
+	if vehicleID.Valid {
+		r.VehicleID = vehicleID.String
+	}
+
 	// Get additional information about the reservation
 	type ReservationDetails struct {
 		models.Reservation
 		ParkingLotName string `json:"parkingLotName"`
 		SpaceNumber    string `json:"spaceNumber"`
-		LicensePlate   string `json:"licensePlate"`
-		VehicleMake    string `json:"vehicleMake"`
-		VehicleModel   string `json:"vehicleModel"`
+		VehicleMake    string `json:"vehicleMake,omitempty"`
+		VehicleModel   string `json:"vehicleModel,omitempty"`
 	}
 
 	var details ReservationDetails
 	details.Reservation = r
 
-	err = database.DB.QueryRow(`
-		SELECT pl.name, ps.space_number, v.license_plate, v.make, v.model
+	err = database.App.QueryRow(`
+		SELECT pl.name, ps.space_number
 		FROM parking_lots pl
 		JOIN parking_spaces ps ON pl.id = ps.parking_lot_id
 		WHERE pl.id = $1 AND ps.id = $2
 	`, r.ParkingLotID, r.ParkingSpaceID).Scan(
 		&details.ParkingLotName,
 		&details.SpaceNumber,
-		&details.LicensePlate,
-		&details.VehicleMake,
-		&details.VehicleModel,
 	)
 
 	if err != nil {
@@ -453,5 +522,234 @@ func GetReservationByID(c *gin.Context) {
 		// Continue anyway, just won't have the additional details
 	}
 
+	if vehicleID.Valid {
+		var brand, model string
+		if err := database.App.QueryRow(`SELECT brand, model FROM vehicles WHERE id = $1`, vehicleID.String).Scan(&brand, &model); err != nil {
+			log.Printf("Error fetching vehicle details: %v", err)
+		} else {
+			details.VehicleMake = brand
+			details.VehicleModel = model
+		}
+	}
+
 	c.JSON(http.StatusOK, gin.H{"reservation": details})
 }
+
+// GetReservationHistory returns the ordered audit trail of a reservation's state transitions
+func GetReservationHistory(c *gin.Context) {
+	id := c.Param("id")
+	if id == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Reservation ID is required"})
+		return
+	}
+
+	if _, exists := c.Get("userId"); !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	rows, err := database.App.Query(`
+		SELECT id, reservation_id, actor_user_id, previous_status, new_status, metadata, occurred_at
+		FROM reservation_events
+		WHERE reservation_id = $1
+		ORDER BY occurred_at ASC
+	`, id)
+	if err != nil {
+		log.Printf("Error fetching reservation history: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch reservation history"})
+		return
+	}
+	defer rows.Close()
+
+	events := []models.ReservationHistoryEvent{}
+	for rows.Next() {
+		var event models.ReservationHistoryEvent
+		var actorUserID sql.NullString
+		var previousStatus sql.NullString
+		var metadataJSON sql.NullString
+
+		if err := rows.Scan(
+			&event.ID, &event.ReservationID, &actorUserID, &previousStatus, &event.NewStatus, &metadataJSON, &event.OccurredAt,
+		); err != nil {
+			log.Printf("Error scanning reservation event row: %v", err)
+			continue
+		}
+
+		event.ActorUserID = actorUserID.String
+		event.PreviousStatus = previousStatus.String
+		if metadataJSON.Valid {
+			if err := json.Unmarshal([]byte(metadataJSON.String), &event.Metadata); err != nil {
+				log.Printf("Error unmarshaling event metadata: %v", err)
+			}
+		}
+
+		events = append(events, event)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Printf("Error iterating reservation event rows: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error processing reservation history"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"reservationId": id,
+		"history":       events,
+	})
+}
+
+// ReservationUpdateRequest represents the body of a PATCH /reservations/:id request
+type ReservationUpdateRequest struct {
+	ExtendMinutes int    `json:"extendMinutes"`
+	ChangeSpaceID string `json:"changeSpaceId"`
+	ChangeDate    string `json:"changeDate"`
+}
+
+// ExtendReservation handles extending a reservation's duration, moving it to
+// a different space, or rescheduling its date. A space change is routed
+// through fsm.EventMoveSpot, which CASes the old and new spaces atomically
+// with the reservation row - see TransitionBooking - so it can't race a
+// concurrent check-in the way a raw UPDATE of parking_space_id could.
+func ExtendReservation(c *gin.Context) {
+	userID, exists := c.Get("userId")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	reservationID := c.Param("id")
+	if reservationID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Reservation ID is required"})
+		return
+	}
+
+	var req ReservationUpdateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var current models.Reservation
+	var reservationDate, expiredAt time.Time
+	err := database.App.QueryRow(`
+		SELECT id, user_id, parking_lot_id, parking_space_id, duration, status, total_cost, reservation_date, expired_at
+		FROM reservations
+		WHERE id = $1 AND user_id = $2
+	`, reservationID, userID).Scan(
+		&current.ID, &current.UserID, &current.ParkingLotID, &current.ParkingSpaceID,
+		&current.Duration, &current.Status, &current.TotalCost, &reservationDate, &expiredAt,
+	)
+	if err != nil {
+		log.Printf("Error fetching reservation for update: %v", err)
+		utils.RespondDBError(c, err)
+		return
+	}
+
+	if current.Status != "pending" && current.Status != "active" {
+		c.JSON(http.StatusConflict, gin.H{"error": "Reservation can no longer be modified"})
+		return
+	}
+
+	targetSpaceID := current.ParkingSpaceID
+	if req.ChangeSpaceID != "" {
+		targetSpaceID = req.ChangeSpaceID
+	}
+
+	newDuration := current.Duration
+	if req.ExtendMinutes > 0 {
+		newDuration += req.ExtendMinutes
+	}
+
+	newReservationDate := reservationDate
+	newExpiredAt := expiredAt
+	if req.ChangeDate != "" {
+		parsedDate, err := time.Parse("2006-01-02", req.ChangeDate)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid changeDate, expected YYYY-MM-DD"})
+			return
+		}
+		newReservationDate = parsedDate
+		newExpiredAt = parsedDate.AddDate(0, 0, 1)
+	}
+
+	// Route a space change through the same transition table ProcessCheckIn
+	// and CancelReservation use, instead of a raw parking_space_id update -
+	// EventMoveSpot releases the old space and CASes the new one atomically
+	// with the reservation row, so a concurrent check-in can never land on
+	// either space mid-move, and the old space can't be left occupied
+	// forever the way a plain UPDATE of parking_space_id would.
+	if targetSpaceID != current.ParkingSpaceID {
+		if _, err := fsm.TransitionBooking(c.Request.Context(), reservationID, userID.(string), fsm.EventMoveSpot, nil, &fsm.MoveSpotMeta{
+			ParkingSpaceID: targetSpaceID,
+		}); err != nil {
+			log.Printf("Error moving booking %s to space %s: %v", reservationID, targetSpaceID, err)
+			if errors.Is(err, fsm.ErrTargetSpaceOccupied) {
+				c.JSON(http.StatusConflict, gin.H{"error": "Target parking space is already occupied"})
+				return
+			}
+			if errors.Is(err, fsm.ErrInvalidTransition) {
+				c.JSON(http.StatusConflict, gin.H{"error": "Reservation can no longer be modified"})
+				return
+			}
+			utils.RespondDBError(c, err)
+			return
+		}
+	}
+
+	newTotalCost := services.RecalculateCost(current.Duration, current.TotalCost, newDuration)
+
+	tx, err := database.App.BeginTx(c.Request.Context(), nil)
+	if err != nil {
+		log.Printf("Error beginning transaction: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update reservation"})
+		return
+	}
+
+	_, err = tx.Exec(`
+		UPDATE reservations
+		SET duration = $1, total_cost = $2, reservation_date = $3, expired_at = $4, updated_at = NOW()
+		WHERE id = $5
+	`, newDuration, newTotalCost, newReservationDate, newExpiredAt, reservationID)
+
+	if err != nil {
+		tx.Rollback()
+		log.Printf("Error updating reservation: %v", err)
+		utils.RespondDBError(c, err)
+		return
+	}
+
+	if err := audit.RecordEvent(tx, reservationID, userID.(string), current.Status, current.Status, map[string]interface{}{
+		"action":           "extended",
+		"previousSpaceId":  current.ParkingSpaceID,
+		"newSpaceId":       targetSpaceID,
+		"previousDuration": current.Duration,
+		"newDuration":      newDuration,
+	}); err != nil {
+		tx.Rollback()
+		log.Printf("Error recording reservation event: %v", err)
+		utils.RespondDBError(c, err)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("Error committing reservation update: %v", err)
+		utils.RespondDBError(c, err)
+		return
+	}
+
+	current.ParkingSpaceID = targetSpaceID
+	current.Duration = newDuration
+	current.TotalCost = newTotalCost
+	current.ReservationDate = newReservationDate
+	current.ExpiredAt = newExpiredAt
+
+	// Re-arm the expiry deadline in case ChangeDate moved it.
+	services.Expiry.Register(reservationID, userID.(string), newExpiredAt)
+
+	utils.WsManager.HandleReservationUpdated(current)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":     "Reservation updated successfully",
+		"reservation": current,
+	})
+}
@@ -1,18 +1,23 @@
 package handlers
 
 import (
+	"database/sql"
 	"fmt"
 	"log"
 	"net/http"
-	"os"
 	"time"
 
-	"github.com/dgrijalva/jwt-go"
 	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 	"golang.org/x/crypto/bcrypt"
 
+	"github.com/holycan/smart-parking-system/auth/keys"
+	"github.com/holycan/smart-parking-system/auth/rbac"
+	"github.com/holycan/smart-parking-system/config"
 	"github.com/holycan/smart-parking-system/database"
+	"github.com/holycan/smart-parking-system/internal/email"
+	"github.com/holycan/smart-parking-system/internal/totp"
 	"github.com/holycan/smart-parking-system/middleware"
 	"github.com/holycan/smart-parking-system/models"
 )
@@ -27,7 +32,7 @@ func RegisterUser(c *gin.Context) {
 
 	// Check if user models.with this email already exists
 	var count int
-	err := database.DB.QueryRow("SELECT COUNT(*) FROM users WHERE email = $1", req.Email).Scan(&count)
+	err := database.App.QueryRow("SELECT COUNT(*) FROM users WHERE email = $1", req.Email).Scan(&count)
 	if err != nil {
 		log.Printf("Error checking for existing user: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check for existing user"})
@@ -51,7 +56,7 @@ func RegisterUser(c *gin.Context) {
 	userID := uuid.New().String()
 
 	// Insert the new user models.into the database
-	_, err = database.DB.Exec(
+	_, err = database.App.Exec(
 		"INSERT INTO users (id, email, password, first_name, last_name, phone, created_at, updated_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)",
 		userID, req.Email, string(hashedPassword), req.FirstName, req.LastName, req.Phone, time.Now(), time.Now(),
 	)
@@ -61,14 +66,54 @@ func RegisterUser(c *gin.Context) {
 		return
 	}
 
-	// Generate JWT token
-	token, err := middleware.GenerateToken(userID, req.Email, "user")
+	if err := rbac.EnsureDefaultRole(userID); err != nil {
+		log.Printf("Error assigning default role: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create user"})
+		return
+	}
+
+	roles, perms, err := rbac.Resolve(userID)
+	if err != nil {
+		log.Printf("Error resolving roles for new user: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate authentication token"})
+		return
+	}
+
+	// Generate JWT token. A brand-new account has no MFA configured yet.
+	token, err := middleware.GenerateToken(userID, req.Email, roles, perms, true)
 	if err != nil {
 		log.Printf("Error generating token: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate authentication token"})
 		return
 	}
 
+	// Issue an email verification token and send the verification email.
+	// This doesn't block registration on delivery succeeding.
+	verificationToken := uuid.New().String()
+	_, err = database.App.Exec(
+		"INSERT INTO email_verification_tokens (user_id, token, expires_at) VALUES ($1, $2, $3) ON CONFLICT (user_id) DO UPDATE SET token = $2, expires_at = $3",
+		userID, verificationToken, time.Now().Add(24*time.Hour),
+	)
+	if err != nil {
+		log.Printf("Error storing email verification token: %v", err)
+	} else {
+		verificationLink := fmt.Sprintf("https://yourapp.com/verify-email?token=%s", verificationToken)
+		if err := email.Current.Send(email.Message{
+			To:       req.Email,
+			Template: email.TemplateEmailVerification,
+			Data:     map[string]interface{}{"VerificationLink": verificationLink},
+		}); err != nil {
+			log.Printf("Error sending verification email: %v", err)
+		}
+	}
+
+	refreshToken, _, err := middleware.IssueRefreshToken(userID, c.Request.UserAgent(), c.ClientIP())
+	if err != nil {
+		log.Printf("Error issuing refresh token: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate authentication token"})
+		return
+	}
+
 	// Return the user models.and token
 	c.JSON(http.StatusCreated, gin.H{
 		"message": "User registered successfully",
@@ -79,7 +124,8 @@ func RegisterUser(c *gin.Context) {
 			"lastName":  req.LastName,
 			"phone":     req.Phone,
 		},
-		"token": token,
+		"token":        token,
+		"refreshToken": refreshToken,
 	})
 }
 
@@ -93,7 +139,7 @@ func LoginUser(c *gin.Context) {
 
 	// Find the user models.by email
 	var user models.User
-	err := database.DB.QueryRow(
+	err := database.App.QueryRow(
 		"SELECT id, email, password, first_name, last_name, phone, role FROM users WHERE email = $1",
 		req.Email,
 	).Scan(&user.ID, &user.Email, &user.Password, &user.FirstName, &user.LastName, &user.Phone, &user.Role)
@@ -104,22 +150,82 @@ func LoginUser(c *gin.Context) {
 		return
 	}
 
+	// A locked account gets the exact same response as a bad password, so
+	// the lockout state itself can't be probed from the outside.
+	if locked, _, err := middleware.IsAccountLocked(user.ID); err != nil {
+		log.Printf("Error checking account lockout: %v", err)
+	} else if locked {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid email or password"})
+		return
+	}
+
 	// Compare the provided password with the stored hash
 	err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password))
 	if err != nil {
 		log.Printf("Invalid password attempt for user models.%s: %v", user.Email, err)
+		if lockErr := middleware.RecordFailedLogin(user.ID, config.Get().Lockout); lockErr != nil {
+			log.Printf("Error recording failed login: %v", lockErr)
+		}
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid email or password"})
 		return
 	}
 
-	// Generate JWT token
-	token, err := middleware.GenerateToken(user.ID, user.Email, user.Role)
+	if err := middleware.ClearFailedLogins(user.ID); err != nil {
+		log.Printf("Error clearing failed logins: %v", err)
+	}
+
+	// An account with MFA enabled doesn't get a fully-privileged token from
+	// a password alone - park the login in mfa_sessions and hand back a
+	// session ID for the client to finish with VerifyMFA instead.
+	var mfaEnabled bool
+	if err := database.App.QueryRow("SELECT enabled FROM user_mfa WHERE user_id = $1", user.ID).Scan(&mfaEnabled); err != nil && err != sql.ErrNoRows {
+		log.Printf("Error checking MFA status: %v", err)
+	}
+
+	if mfaEnabled {
+		sessionID := uuid.New().String()
+		_, err := database.App.Exec(
+			"INSERT INTO mfa_sessions (session_id, user_id, expires_at, created_at) VALUES ($1, $2, $3, $4)",
+			sessionID, user.ID, time.Now().Add(5*time.Minute), time.Now(),
+		)
+		if err != nil {
+			log.Printf("Error creating MFA session: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start MFA verification"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message":      "MFA verification required",
+			"mfaRequired":  true,
+			"mfaSessionId": sessionID,
+		})
+		return
+	}
+
+	roles, perms, err := rbac.Resolve(user.ID)
+	if err != nil {
+		log.Printf("Error resolving roles: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate authentication token"})
+		return
+	}
+
+	// Generate the short-lived access token and a new refresh token family.
+	// Reaching here means either the account has no MFA, or (via the
+	// mfaEnabled branch above) it's about to be gated by VerifyMFA instead.
+	token, err := middleware.GenerateToken(user.ID, user.Email, roles, perms, true)
 	if err != nil {
 		log.Printf("Error generating token: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate authentication token"})
 		return
 	}
 
+	refreshToken, _, err := middleware.IssueRefreshToken(user.ID, c.Request.UserAgent(), c.ClientIP())
+	if err != nil {
+		log.Printf("Error issuing refresh token: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate authentication token"})
+		return
+	}
+
 	// Return the user models.and token
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Login successful",
@@ -130,15 +236,42 @@ func LoginUser(c *gin.Context) {
 			"lastName":  user.LastName,
 			"phone":     user.Phone,
 		},
-		"token": token,
+		"token":        token,
+		"refreshToken": refreshToken,
 	})
 }
 
-// LogoutUser handles user models.logout
+// LogoutUser revokes the presented refresh token so it (and, with
+// ?all=true, every token in its family) can no longer be used to mint
+// new access tokens.
 func LogoutUser(c *gin.Context) {
-	// Since we're using JWT tokens and not storing them server-side,
-	// we don't need to do anything special here.
-	// The client will remove the token.
+	var req struct {
+		RefreshToken string `json:"refreshToken"`
+	}
+	_ = c.ShouldBindJSON(&req)
+
+	if req.RefreshToken != "" {
+		stored, err := middleware.LookupRefreshToken(req.RefreshToken)
+		if err != nil {
+			log.Printf("Error looking up refresh token at logout: %v", err)
+		} else if c.Query("all") == "true" {
+			if err := middleware.RevokeRefreshTokenFamily(stored.FamilyID); err != nil {
+				log.Printf("Error revoking refresh token family at logout: %v", err)
+			}
+		} else if err := middleware.RevokeRefreshToken(stored.ID); err != nil {
+			log.Printf("Error revoking refresh token at logout: %v", err)
+		}
+	}
+
+	if tokenID, exists := c.Get("tokenId"); exists && tokenID != "" {
+		expiresAt, _ := c.Get("tokenExpiresAt")
+		if expiresAtTime, ok := expiresAt.(time.Time); ok {
+			if err := middleware.BlacklistToken(tokenID.(string), expiresAtTime); err != nil {
+				log.Printf("Error blacklisting access token at logout: %v", err)
+			}
+		}
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Logged out successfully",
 	})
@@ -155,19 +288,12 @@ func ValidateToken(c *gin.Context) {
 		return
 	}
 
-	// Get the JWT secret from environment variables
-	jwtSecret := os.Getenv("JWT_SECRET")
-	if jwtSecret == "" {
-		jwtSecret = "ramaa212!" // Default fallback
-	}
+	ks := keys.Current
 
 	// Parse and validate the token
 	claims := &middleware.Claims{}
 	token, err := jwt.ParseWithClaims(req.Token, claims, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return []byte(jwtSecret), nil
+		return ks.Verify(token)
 	})
 
 	if err != nil || !token.Valid {
@@ -175,12 +301,6 @@ func ValidateToken(c *gin.Context) {
 		return
 	}
 
-	// Check token expiration
-	if claims.ExpiresAt < time.Now().Unix() {
-		c.JSON(http.StatusOK, gin.H{"valid": false})
-		return
-	}
-
 	// Token is valid
 	c.JSON(http.StatusOK, gin.H{"valid": true})
 }
@@ -196,7 +316,7 @@ func GetUserProfile(c *gin.Context) {
 
 	// Fetch user models.from database
 	var user models.User
-	err := database.DB.QueryRow(
+	err := database.App.QueryRow(
 		"SELECT id, email, first_name, last_name, phone, created_at, updated_at FROM users WHERE id = $1",
 		userID,
 	).Scan(&user.ID, &user.Email, &user.FirstName, &user.LastName, &user.Phone, &user.CreatedAt, &user.UpdatedAt)
@@ -237,7 +357,7 @@ func UpdateUserProfile(c *gin.Context) {
 	}
 
 	// Start a transaction
-	tx, err := database.DB.Begin()
+	tx, err := database.App.Begin()
 	if err != nil {
 		log.Printf("Error starting transaction: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update profile"})
@@ -330,7 +450,7 @@ func UpdateUserProfile(c *gin.Context) {
 
 	// Fetch updated user models.from database
 	var user models.User
-	err = database.DB.QueryRow(
+	err = database.App.QueryRow(
 		"SELECT id, email, first_name, last_name, phone, created_at, updated_at FROM users WHERE id = $1",
 		userID,
 	).Scan(&user.ID, &user.Email, &user.FirstName, &user.LastName, &user.Phone, &user.CreatedAt, &user.UpdatedAt)
@@ -356,38 +476,79 @@ func UpdateUserProfile(c *gin.Context) {
 	})
 }
 
-// RefreshToken generates a new token for the user
+// RefreshToken exchanges a refresh token for a new access+refresh pair.
+// It deliberately doesn't sit behind AuthMiddleware: the whole point of a
+// refresh token is to get a new access token once the old one has
+// expired.
 func RefreshToken(c *gin.Context) {
-	// Get user models.ID from the context (set by the auth middleware)
-	userID, exists := c.Get("userId")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+	var req struct {
+		RefreshToken string `json:"refreshToken" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	email, exists := c.Get("email")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+	stored, err := middleware.LookupRefreshToken(req.RefreshToken)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid refresh token"})
 		return
 	}
 
-	role, exists := c.Get("role")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+	if stored.RevokedAt.Valid {
+		// This token was already rotated away (or explicitly revoked) and
+		// is being presented again - treat it as stolen and kill every
+		// token descended from the same login.
+		if err := middleware.RevokeRefreshTokenFamily(stored.FamilyID); err != nil {
+			log.Printf("Error revoking refresh token family after reuse: %v", err)
+		}
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Refresh token has already been used; all sessions revoked"})
+		return
+	}
+
+	if time.Now().After(stored.ExpiresAt) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Refresh token has expired"})
+		return
+	}
+
+	var user models.User
+	err = database.App.QueryRow(
+		"SELECT id, email, role FROM users WHERE id = $1",
+		stored.UserID,
+	).Scan(&user.ID, &user.Email, &user.Role)
+	if err != nil {
+		log.Printf("Error fetching user for refresh: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to refresh token"})
+		return
+	}
+
+	newRefreshToken, _, err := middleware.RotateRefreshToken(stored, c.Request.UserAgent(), c.ClientIP())
+	if err != nil {
+		log.Printf("Error rotating refresh token: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to refresh token"})
+		return
+	}
+
+	roles, perms, err := rbac.Resolve(user.ID)
+	if err != nil {
+		log.Printf("Error resolving roles on refresh: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to refresh token"})
 		return
 	}
 
-	// Generate a new token
-	token, err := middleware.GenerateToken(userID.(string), email.(string), role.(string))
+	// A refresh token could only exist if the original login already
+	// completed MFA (or the account doesn't require it).
+	token, err := middleware.GenerateToken(user.ID, user.Email, roles, perms, true)
 	if err != nil {
-		log.Printf("Error generating refresh token: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate new token"})
+		log.Printf("Error generating access token: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to refresh token"})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"message": "Token refreshed successfully",
-		"token":   token,
+		"message":      "Token refreshed successfully",
+		"token":        token,
+		"refreshToken": newRefreshToken,
 	})
 }
 
@@ -404,7 +565,7 @@ func RequestPasswordReset(c *gin.Context) {
 
 	// Check if user models.exists
 	var userID string
-	err := database.DB.QueryRow("SELECT id FROM users WHERE email = $1", req.Email).Scan(&userID)
+	err := database.App.QueryRow("SELECT id FROM users WHERE email = $1", req.Email).Scan(&userID)
 	if err != nil {
 		// Don't reveal if email exists or not for security reasons
 		c.JSON(http.StatusOK, gin.H{"message": "If your email is registered, you will receive password reset instructions"})
@@ -416,7 +577,7 @@ func RequestPasswordReset(c *gin.Context) {
 
 	// Store the reset token in the database with expiration time (1 hour)
 	expiryTime := time.Now().Add(1 * time.Hour)
-	_, err = database.DB.Exec(
+	_, err = database.App.Exec(
 		"INSERT INTO password_reset_tokens (user_id, token, expires_at) VALUES ($1, $2, $3) ON CONFLICT (user_id) DO UPDATE SET token = $2, expires_at = $3",
 		userID, resetToken, expiryTime,
 	)
@@ -427,9 +588,14 @@ func RequestPasswordReset(c *gin.Context) {
 		return
 	}
 
-	// In a real application, send an email with the reset link
 	resetLink := fmt.Sprintf("https://yourapp.com/reset-password?token=%s", resetToken)
-	log.Printf("Password reset link for %s: %s", req.Email, resetLink)
+	if err := email.Current.Send(email.Message{
+		To:       req.Email,
+		Template: email.TemplatePasswordReset,
+		Data:     map[string]interface{}{"ResetLink": resetLink},
+	}); err != nil {
+		log.Printf("Error sending password reset email: %v", err)
+	}
 
 	// For demo purposes, we'll just return success
 	c.JSON(http.StatusOK, gin.H{
@@ -453,7 +619,7 @@ func ResetPassword(c *gin.Context) {
 
 	// Verify the reset token
 	var userID string
-	err := database.DB.QueryRow(
+	err := database.App.QueryRow(
 		"SELECT user_id FROM password_reset_tokens WHERE token = $1 AND expires_at > $2",
 		req.Token, time.Now(),
 	).Scan(&userID)
@@ -472,7 +638,7 @@ func ResetPassword(c *gin.Context) {
 	}
 
 	// Update the user's password
-	_, err = database.DB.Exec(
+	_, err = database.App.Exec(
 		"UPDATE users SET password = $1, updated_at = $2 WHERE id = $3",
 		string(hashedPassword), time.Now(), userID,
 	)
@@ -484,7 +650,7 @@ func ResetPassword(c *gin.Context) {
 	}
 
 	// Delete the used reset token
-	_, err = database.DB.Exec("DELETE FROM password_reset_tokens WHERE token = $1", req.Token)
+	_, err = database.App.Exec("DELETE FROM password_reset_tokens WHERE token = $1", req.Token)
 	if err != nil {
 		log.Printf("Error deleting reset token: %v", err)
 	}
@@ -503,7 +669,7 @@ func VerifyEmail(c *gin.Context) {
 
 	// Verify the email verification token
 	var userID string
-	err := database.DB.QueryRow(
+	err := database.App.QueryRow(
 		"SELECT user_id FROM email_verification_tokens WHERE token = $1 AND expires_at > $2",
 		token, time.Now(),
 	).Scan(&userID)
@@ -514,7 +680,7 @@ func VerifyEmail(c *gin.Context) {
 	}
 
 	// Update the user's email verification status
-	_, err = database.DB.Exec(
+	_, err = database.App.Exec(
 		"UPDATE users SET email_verified = true, updated_at = $1 WHERE id = $2",
 		time.Now(), userID,
 	)
@@ -526,7 +692,7 @@ func VerifyEmail(c *gin.Context) {
 	}
 
 	// Delete the used verification token
-	_, err = database.DB.Exec("DELETE FROM email_verification_tokens WHERE token = $1", token)
+	_, err = database.App.Exec("DELETE FROM email_verification_tokens WHERE token = $1", token)
 	if err != nil {
 		log.Printf("Error deleting verification token: %v", err)
 	}
@@ -537,11 +703,12 @@ func VerifyEmail(c *gin.Context) {
 // SetupMFA sets up multi-factor authentication for a user
 func SetupMFA(c *gin.Context) {
 	// Get user models.ID from the context (set by the auth middleware)
-	userID, exists := c.Get("userId")
+	userIDVal, exists := c.Get("userId")
 	if !exists {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
 		return
 	}
+	userID := userIDVal.(string)
 
 	var req struct {
 		Method string `json:"method" binding:"required,oneof=totp sms email"`
@@ -552,24 +719,46 @@ func SetupMFA(c *gin.Context) {
 		return
 	}
 
-	// Generate a secret key for TOTP or a verification code for SMS/email
-	var secret string
+	// storedSecret is what goes in user_mfa.secret: an AES-GCM-encrypted
+	// TOTP secret for "totp", or a plaintext one-time code for "sms"/"email".
+	var storedSecret string
 	var qrCodeURL string
+	var backupCodes []string
 
 	switch req.Method {
 	case "totp":
-		// In a real implementation, use a proper TOTP library
-		secret = fmt.Sprintf("TOTP_SECRET_%s", uuid.New().String())
-		qrCodeURL = fmt.Sprintf("otpauth://totp/SmartParkingSystem:%s?secret=%s&issuer=SmartParkingSystem", userID, secret)
+		secret, err := totp.GenerateSecret()
+		if err != nil {
+			log.Printf("Error generating TOTP secret: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to set up MFA"})
+			return
+		}
+		qrCodeURL = totp.URI(secret, "SmartParkingSystem", userID)
+		storedSecret, err = totp.EncryptSecret(config.Get().MFA.EncryptionKey, secret)
+		if err != nil {
+			log.Printf("Error encrypting TOTP secret: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to set up MFA"})
+			return
+		}
+
+		backupCodes, err = totp.GenerateBackupCodes()
+		if err != nil {
+			log.Printf("Error generating backup codes: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to set up MFA"})
+			return
+		}
 	case "sms", "email":
 		// Generate a 6-digit verification code
-		secret = fmt.Sprintf("%06d", time.Now().UnixNano()%1000000)
+		storedSecret = fmt.Sprintf("%06d", time.Now().UnixNano()%1000000)
 	}
 
-	// Store the MFA method and secret in the database
-	_, err := database.DB.Exec(
-		"INSERT INTO user_mfa (user_id, method, secret, enabled, created_at) VALUES ($1, $2, $3, false, $4) ON CONFLICT (user_id) DO UPDATE SET method = $2, secret = $3, enabled = false, created_at = $4",
-		userID, req.Method, secret, time.Now(),
+	// Store the MFA method and secret in the database. last_accepted_step
+	// resets to 0 on every (re-)setup so a stale replay guard from a
+	// previous secret can't block the first real code.
+	_, err := database.App.Exec(
+		"INSERT INTO user_mfa (user_id, method, secret, enabled, last_accepted_step, created_at, updated_at) VALUES ($1, $2, $3, false, 0, $4, $4) "+
+			"ON CONFLICT (user_id) DO UPDATE SET method = $2, secret = $3, enabled = false, last_accepted_step = 0, updated_at = $4",
+		userID, req.Method, storedSecret, time.Now(),
 	)
 
 	if err != nil {
@@ -578,9 +767,39 @@ func SetupMFA(c *gin.Context) {
 		return
 	}
 
+	if req.Method == "totp" {
+		if _, err := database.App.Exec("DELETE FROM user_mfa_backup_codes WHERE user_id = $1", userID); err != nil {
+			log.Printf("Error clearing old backup codes: %v", err)
+		}
+		for _, code := range backupCodes {
+			hash, err := totp.HashBackupCode(code)
+			if err != nil {
+				log.Printf("Error hashing backup code: %v", err)
+				continue
+			}
+			if _, err := database.App.Exec(
+				"INSERT INTO user_mfa_backup_codes (id, user_id, code_hash, created_at) VALUES ($1, $2, $3, $4)",
+				uuid.New().String(), userID, hash, time.Now(),
+			); err != nil {
+				log.Printf("Error storing backup code: %v", err)
+			}
+		}
+	}
+
 	// In a real application, send the verification code via SMS or email if applicable
-	if req.Method == "sms" || req.Method == "email" {
-		log.Printf("MFA verification code for user models.%s: %s", userID, secret)
+	if req.Method == "sms" {
+		log.Printf("MFA verification code for user models.%s: %s", userID, storedSecret)
+	} else if req.Method == "email" {
+		var userEmail string
+		if err := database.App.QueryRow("SELECT email FROM users WHERE id = $1", userID).Scan(&userEmail); err != nil {
+			log.Printf("Error looking up email for MFA code delivery: %v", err)
+		} else if err := email.Current.Send(email.Message{
+			To:       userEmail,
+			Template: email.TemplateMFACode,
+			Data:     map[string]interface{}{"Code": storedSecret},
+		}); err != nil {
+			log.Printf("Error sending MFA code email: %v", err)
+		}
 	}
 
 	response := gin.H{
@@ -589,8 +808,12 @@ func SetupMFA(c *gin.Context) {
 	}
 
 	if req.Method == "totp" {
-		response["secret"] = secret
+		// Rendering qrCodeUrl as an actual QR-code PNG needs a QR-encoding
+		// library this build doesn't have available; the client renders
+		// the QR code itself from the otpauth:// URI, same as most
+		// authenticator-app integrations support as a fallback.
 		response["qrCodeUrl"] = qrCodeURL
+		response["backupCodes"] = backupCodes
 	}
 
 	c.JSON(http.StatusOK, response)
@@ -614,13 +837,14 @@ func VerifyMFA(c *gin.Context) {
 	var method string
 	var secret string
 	var enabled bool
+	var lastAcceptedStep int64
 
 	if req.SessionID != "" {
 		// Verify MFA during login
-		err := database.DB.QueryRow(
-			"SELECT u.id, m.method, m.secret, m.enabled FROM mfa_sessions s JOIN users u ON s.user_id = u.id JOIN user_mfa m ON u.id = m.user_id WHERE s.session_id = $1 AND s.expires_at > $2",
+		err := database.App.QueryRow(
+			"SELECT u.id, m.method, m.secret, m.enabled, m.last_accepted_step FROM mfa_sessions s JOIN users u ON s.user_id = u.id JOIN user_mfa m ON u.id = m.user_id WHERE s.session_id = $1 AND s.expires_at > $2",
 			req.SessionID, time.Now(),
-		).Scan(&userID, &method, &secret, &enabled)
+		).Scan(&userID, &method, &secret, &enabled, &lastAcceptedStep)
 
 		if err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired MFA session"})
@@ -642,10 +866,10 @@ func VerifyMFA(c *gin.Context) {
 		userID = userIDFromContext.(string)
 
 		// Get the user's MFA method and secret
-		err := database.DB.QueryRow(
-			"SELECT method, secret, enabled FROM user_mfa WHERE user_id = $1",
+		err := database.App.QueryRow(
+			"SELECT method, secret, enabled, last_accepted_step FROM user_mfa WHERE user_id = $1",
 			userID,
-		).Scan(&method, &secret, &enabled)
+		).Scan(&method, &secret, &enabled, &lastAcceptedStep)
 
 		if err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "MFA not set up for this user"})
@@ -655,12 +879,56 @@ func VerifyMFA(c *gin.Context) {
 
 	// Verify the MFA code
 	var codeValid bool
+	var acceptedStep int64
+	usedBackupCode := false
 
 	switch method {
 	case "totp":
-		// In a real implementation, use a proper TOTP library to validate the code
-		// For demo purposes, we'll just check if the code is "123456"
-		codeValid = req.Code == "123456"
+		if totp.IsBackupCodeFormat(req.Code) {
+			rows, err := database.App.Query(
+				"SELECT id, code_hash FROM user_mfa_backup_codes WHERE user_id = $1 AND used_at IS NULL",
+				userID,
+			)
+			if err != nil {
+				log.Printf("Error fetching backup codes: %v", err)
+			} else {
+				var matchedID string
+				for rows.Next() {
+					var codeID, codeHash string
+					if err := rows.Scan(&codeID, &codeHash); err != nil {
+						log.Printf("Error scanning backup code: %v", err)
+						continue
+					}
+					// Each of the 10 codes GenerateBackupCodes issues is
+					// hashed independently, so the submitted code can only
+					// ever match one row - check every unused row instead of
+					// an arbitrary single one.
+					if totp.CompareBackupCode(codeHash, req.Code) {
+						matchedID = codeID
+						break
+					}
+				}
+				rows.Close()
+
+				if matchedID != "" {
+					codeValid = true
+					usedBackupCode = true
+					if _, err := database.App.Exec(
+						"UPDATE user_mfa_backup_codes SET used_at = $1 WHERE id = $2",
+						time.Now(), matchedID,
+					); err != nil {
+						log.Printf("Error marking backup code used: %v", err)
+					}
+				}
+			}
+		} else {
+			decrypted, err := totp.DecryptSecret(config.Get().MFA.EncryptionKey, secret)
+			if err != nil {
+				log.Printf("Error decrypting TOTP secret: %v", err)
+			} else {
+				codeValid, acceptedStep = totp.Validate(decrypted, req.Code, time.Now(), config.Get().MFA.TOTPSkewSteps, lastAcceptedStep)
+			}
+		}
 	case "sms", "email":
 		// Check if the code matches the stored secret
 		codeValid = req.Code == secret
@@ -671,9 +939,18 @@ func VerifyMFA(c *gin.Context) {
 		return
 	}
 
+	if method == "totp" && !usedBackupCode {
+		if _, err := database.App.Exec(
+			"UPDATE user_mfa SET last_accepted_step = $1 WHERE user_id = $2",
+			acceptedStep, userID,
+		); err != nil {
+			log.Printf("Error recording accepted TOTP step: %v", err)
+		}
+	}
+
 	// If this is part of enabling MFA, mark it as enabled
 	if !enabled && req.SessionID == "" {
-		_, err := database.DB.Exec(
+		_, err := database.App.Exec(
 			"UPDATE user_mfa SET enabled = true, updated_at = $1 WHERE user_id = $2",
 			time.Now(), userID,
 		)
@@ -692,7 +969,7 @@ func VerifyMFA(c *gin.Context) {
 	if req.SessionID != "" {
 		// Get user models.information
 		var user models.User
-		err := database.DB.QueryRow(
+		err := database.App.QueryRow(
 			"SELECT id, email, first_name, last_name, phone, role FROM users WHERE id = $1",
 			userID,
 		).Scan(&user.ID, &user.Email, &user.FirstName, &user.LastName, &user.Phone, &user.Role)
@@ -703,8 +980,16 @@ func VerifyMFA(c *gin.Context) {
 			return
 		}
 
-		// Generate JWT token
-		token, err := middleware.GenerateToken(user.ID, user.Email, user.Role)
+		roles, perms, err := rbac.Resolve(user.ID)
+		if err != nil {
+			log.Printf("Error resolving roles: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate authentication token"})
+			return
+		}
+
+		// Generate JWT token. Reaching here means the second factor for this
+		// session has just been verified.
+		token, err := middleware.GenerateToken(user.ID, user.Email, roles, perms, true)
 		if err != nil {
 			log.Printf("Error generating token: %v", err)
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate authentication token"})
@@ -712,11 +997,18 @@ func VerifyMFA(c *gin.Context) {
 		}
 
 		// Delete the MFA session
-		_, err = database.DB.Exec("DELETE FROM mfa_sessions WHERE session_id = $1", req.SessionID)
+		_, err = database.App.Exec("DELETE FROM mfa_sessions WHERE session_id = $1", req.SessionID)
 		if err != nil {
 			log.Printf("Error deleting MFA session: %v", err)
 		}
 
+		refreshToken, _, err := middleware.IssueRefreshToken(user.ID, c.Request.UserAgent(), c.ClientIP())
+		if err != nil {
+			log.Printf("Error issuing refresh token: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate authentication token"})
+			return
+		}
+
 		// Return the user models.and token
 		c.JSON(http.StatusOK, gin.H{
 			"message": "MFA verification successful",
@@ -726,9 +1018,10 @@ func VerifyMFA(c *gin.Context) {
 				"firstName": user.FirstName,
 				"lastName":  user.LastName,
 				"phone":     user.Phone,
-				"role":      user.Role,
+				"roles":     roles,
 			},
-			"token": token,
+			"token":        token,
+			"refreshToken": refreshToken,
 		})
 		return
 	}
@@ -0,0 +1,163 @@
+package handlers
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/holycan/smart-parking-system/database"
+	"github.com/holycan/smart-parking-system/models"
+)
+
+// GetVehicles handles fetching all vehicles registered to the current user
+func GetVehicles(c *gin.Context) {
+	userID, exists := c.Get("userId")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	rows, err := database.App.Query(`
+		SELECT id, user_id, license_plate, brand, model, type, color, is_default, created_at, updated_at
+		FROM vehicles WHERE user_id = $1 ORDER BY is_default DESC, created_at ASC
+	`, userID)
+	if err != nil {
+		log.Printf("Error fetching vehicles: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch vehicles"})
+		return
+	}
+	defer rows.Close()
+
+	vehicles := []models.Vehicle{}
+	for rows.Next() {
+		var v models.Vehicle
+		if err := rows.Scan(&v.ID, &v.UserID, &v.LicensePlate, &v.Make, &v.Model, &v.Type, &v.Color, &v.IsDefault, &v.CreatedAt, &v.UpdatedAt); err != nil {
+			log.Printf("Error scanning vehicle row: %v", err)
+			continue
+		}
+		vehicles = append(vehicles, v)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"vehicles": vehicles})
+}
+
+// CreateVehicle handles registering a new vehicle to the current user's garage
+func CreateVehicle(c *gin.Context) {
+	userID, exists := c.Get("userId")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req models.VehicleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	vehicleID := uuid.New().String()
+	_, err := database.App.Exec(`
+		INSERT INTO vehicles (id, user_id, type, license_plate, brand, model, year, color, is_default, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+	`, vehicleID, userID, req.Type, req.LicensePlate, req.Make, req.Model, 0, req.Color, req.IsDefault, time.Now(), time.Now())
+
+	if err != nil {
+		log.Printf("Error creating vehicle: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to register vehicle"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Vehicle registered successfully",
+		"vehicle": gin.H{
+			"id":           vehicleID,
+			"licensePlate": req.LicensePlate,
+			"make":         req.Make,
+			"model":        req.Model,
+			"type":         req.Type,
+			"color":        req.Color,
+			"isDefault":    req.IsDefault,
+		},
+	})
+}
+
+// UpdateVehicle handles updating a vehicle the current user owns
+func UpdateVehicle(c *gin.Context) {
+	userID, exists := c.Get("userId")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	vehicleID := c.Param("id")
+	var req models.VehicleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	result, err := database.App.Exec(`
+		UPDATE vehicles
+		SET license_plate = $1, brand = $2, model = $3, type = $4, color = $5, is_default = $6, updated_at = $7
+		WHERE id = $8 AND user_id = $9
+	`, req.LicensePlate, req.Make, req.Model, req.Type, req.Color, req.IsDefault, time.Now(), vehicleID, userID)
+
+	if err != nil {
+		log.Printf("Error updating vehicle: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update vehicle"})
+		return
+	}
+
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Vehicle not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Vehicle updated successfully"})
+}
+
+// DeleteVehicle handles removing a vehicle from the current user's garage
+func DeleteVehicle(c *gin.Context) {
+	userID, exists := c.Get("userId")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	vehicleID := c.Param("id")
+	result, err := database.App.Exec("DELETE FROM vehicles WHERE id = $1 AND user_id = $2", vehicleID, userID)
+	if err != nil {
+		log.Printf("Error deleting vehicle: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete vehicle"})
+		return
+	}
+
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Vehicle not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Vehicle deleted successfully"})
+}
+
+// getOwnedVehicle fetches a vehicle by ID, verifying it belongs to userID
+func getOwnedVehicle(vehicleID, userID string) (*models.Vehicle, error) {
+	var v models.Vehicle
+	err := database.App.QueryRow(`
+		SELECT id, user_id, license_plate, brand, model, type, color, is_default, created_at, updated_at
+		FROM vehicles WHERE id = $1 AND user_id = $2
+	`, vehicleID, userID).Scan(&v.ID, &v.UserID, &v.LicensePlate, &v.Make, &v.Model, &v.Type, &v.Color, &v.IsDefault, &v.CreatedAt, &v.UpdatedAt)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &v, nil
+}
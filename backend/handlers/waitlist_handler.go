@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/holycan/smart-parking-system/services"
+)
+
+// GetWaitlistPosition reports the authenticated user's 1-based position in
+// lotID's waitlist, populated by services.JoinWaitlist when ProcessCheckIn
+// couldn't find any spot at all.
+func GetWaitlistPosition(c *gin.Context) {
+	lotID := c.Param("lotID")
+	userID, exists := getUserIDFromContext(c)
+	if !exists {
+		respondWithError(c, http.StatusUnauthorized, ErrUserNotAuthenticated)
+		return
+	}
+
+	position, err := services.WaitlistPosition(c.Request.Context(), lotID, userID)
+	if err != nil {
+		if errors.Is(err, services.ErrNotWaitlisted) {
+			respondWithError(c, http.StatusNotFound, err)
+			return
+		}
+		log.Printf("Failed to read waitlist position for user %s in lot %s: %v", userID, lotID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read waitlist position"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"position": position})
+}
+
+// LeaveWaitlist lets the authenticated user voluntarily drop off lotID's
+// waitlist, e.g. because they found parking elsewhere.
+func LeaveWaitlist(c *gin.Context) {
+	lotID := c.Param("lotID")
+	userID, exists := getUserIDFromContext(c)
+	if !exists {
+		respondWithError(c, http.StatusUnauthorized, ErrUserNotAuthenticated)
+		return
+	}
+
+	if err := services.LeaveWaitlist(c.Request.Context(), lotID, userID); err != nil {
+		if errors.Is(err, services.ErrNotWaitlisted) {
+			respondWithError(c, http.StatusNotFound, err)
+			return
+		}
+		log.Printf("Failed to remove user %s from waitlist for lot %s: %v", userID, lotID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to leave waitlist"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Left the waitlist"})
+}
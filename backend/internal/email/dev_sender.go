@@ -0,0 +1,20 @@
+package email
+
+import "log"
+
+// devSender logs rendered messages to stdout instead of sending them, so
+// local development doesn't need a real SMTP server configured.
+type devSender struct{}
+
+func newDevSender() *devSender {
+	return &devSender{}
+}
+
+func (s *devSender) Send(msg Message) error {
+	subject, body, err := render(msg)
+	if err != nil {
+		return err
+	}
+	log.Printf("email (dev mode): to=%s subject=%q\n%s", msg.To, subject, body)
+	return nil
+}
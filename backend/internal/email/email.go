@@ -0,0 +1,90 @@
+// Package email delivers the transactional messages the auth handlers
+// used to just log.Printf — password reset links, email verification
+// links, MFA codes, and booking confirmations. NewSender resolves to one
+// of three backends depending on the application environment: a real SMTP
+// sender in anything but development/test, a sender that logs to stdout in
+// development, and an in-memory capturing sender in tests.
+package email
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"html/template"
+
+	"github.com/holycan/smart-parking-system/config"
+)
+
+// Template names, matching the embedded *.html files under templates/.
+const (
+	TemplatePasswordReset       = "password_reset"
+	TemplateEmailVerification   = "email_verification"
+	TemplateMFACode             = "mfa_code"
+	TemplateBookingConfirmation = "booking_confirmation"
+)
+
+var subjects = map[string]string{
+	TemplatePasswordReset:       "Reset your Smart Parking System password",
+	TemplateEmailVerification:   "Verify your Smart Parking System email",
+	TemplateMFACode:             "Your Smart Parking System verification code",
+	TemplateBookingConfirmation: "Your Smart Parking System booking is confirmed",
+}
+
+//go:embed templates/*.html
+var templateFS embed.FS
+
+var templates = template.Must(template.ParseFS(templateFS, "templates/*.html"))
+
+// Message is a single email to send. Template selects both the embedded
+// HTML body and the subject line; Data is passed straight to that
+// template's Execute.
+type Message struct {
+	To       string
+	Template string
+	Data     map[string]interface{}
+}
+
+func render(msg Message) (subject, body string, err error) {
+	subject, ok := subjects[msg.Template]
+	if !ok {
+		return "", "", fmt.Errorf("email: unknown template %q", msg.Template)
+	}
+
+	var buf bytes.Buffer
+	if err := templates.ExecuteTemplate(&buf, msg.Template+".html", msg.Data); err != nil {
+		return "", "", fmt.Errorf("email: failed to render template %q: %w", msg.Template, err)
+	}
+
+	return subject, buf.String(), nil
+}
+
+// Sender delivers a Message. Send is expected to return quickly — the SMTP
+// implementation queues the message and hands off to a worker pool rather
+// than dialing out on the caller's goroutine, so a slow mail server can't
+// block a Gin request handler.
+type Sender interface {
+	Send(msg Message) error
+}
+
+// Current is the process-wide Sender set by Init, following the same
+// global-singleton pattern as utils.WsManager and jobs.Current.
+var Current Sender
+
+// Init resolves a Sender from cfg and env and stores it as Current.
+func Init(cfg config.NotificationsConfig, env string) {
+	Current = NewSender(cfg, env)
+}
+
+// NewSender returns the Sender matching env: "test" captures messages
+// in-memory, "development" logs them to stdout, anything else dials out
+// over SMTP using cfg.
+func NewSender(cfg config.NotificationsConfig, env string) Sender {
+	switch env {
+	case "test":
+		return NewTestSender()
+	case "development":
+		return newDevSender()
+	default:
+		return newSMTPSender(cfg)
+	}
+}
@@ -0,0 +1,122 @@
+package email
+
+import (
+	"crypto/tls"
+	"fmt"
+	"log"
+	"net/smtp"
+
+	"github.com/holycan/smart-parking-system/config"
+)
+
+// smtpWorkers bounds how many messages can be in flight to the SMTP server
+// at once.
+const smtpWorkers = 4
+
+// smtpQueueSize is how many queued messages Send will accept before it
+// starts rejecting new ones rather than blocking the caller.
+const smtpQueueSize = 100
+
+// smtpSender delivers mail over net/smtp, optionally wrapped in TLS. Send
+// only enqueues the message; smtpWorkers goroutines do the actual dialing
+// and delivery, so a slow or unreachable SMTP server can't block whatever
+// Gin handler called Send.
+type smtpSender struct {
+	cfg   config.NotificationsConfig
+	queue chan Message
+}
+
+func newSMTPSender(cfg config.NotificationsConfig) *smtpSender {
+	s := &smtpSender{
+		cfg:   cfg,
+		queue: make(chan Message, smtpQueueSize),
+	}
+	for i := 0; i < smtpWorkers; i++ {
+		go s.worker()
+	}
+	return s
+}
+
+func (s *smtpSender) Send(msg Message) error {
+	select {
+	case s.queue <- msg:
+		return nil
+	default:
+		return fmt.Errorf("email: send queue is full, dropping message to %s", msg.To)
+	}
+}
+
+func (s *smtpSender) worker() {
+	for msg := range s.queue {
+		if err := s.deliver(msg); err != nil {
+			log.Printf("email: failed to send %q to %s: %v", msg.Template, msg.To, err)
+		}
+	}
+}
+
+func (s *smtpSender) deliver(msg Message) error {
+	subject, body, err := render(msg)
+	if err != nil {
+		return err
+	}
+
+	data := []byte(fmt.Sprintf(
+		"From: %s\r\nTo: %s\r\nSubject: %s\r\nMIME-Version: 1.0\r\nContent-Type: text/html; charset=\"UTF-8\"\r\n\r\n%s",
+		s.cfg.SMTPFrom, msg.To, subject, body,
+	))
+
+	addr := fmt.Sprintf("%s:%s", s.cfg.SMTPHost, s.cfg.SMTPPort)
+
+	if s.cfg.SMTPTLSMode == "tls" {
+		return s.sendOverImplicitTLS(addr, msg.To, data)
+	}
+
+	// "starttls" (the default) and "none" both go through smtp.SendMail,
+	// which opportunistically issues STARTTLS if the server advertises it.
+	var auth smtp.Auth
+	if s.cfg.SMTPUser != "" {
+		auth = smtp.PlainAuth("", s.cfg.SMTPUser, s.cfg.SMTPPassword, s.cfg.SMTPHost)
+	}
+	return smtp.SendMail(addr, auth, s.cfg.SMTPFrom, []string{msg.To}, data)
+}
+
+// sendOverImplicitTLS is used when SMTPTLSMode is "tls" — some providers
+// (e.g. port 465) expect the TLS handshake before any SMTP command, which
+// smtp.SendMail's STARTTLS negotiation doesn't do.
+func (s *smtpSender) sendOverImplicitTLS(addr, to string, data []byte) error {
+	conn, err := tls.Dial("tcp", addr, &tls.Config{ServerName: s.cfg.SMTPHost})
+	if err != nil {
+		return fmt.Errorf("failed to dial SMTP over TLS: %w", err)
+	}
+	defer conn.Close()
+
+	client, err := smtp.NewClient(conn, s.cfg.SMTPHost)
+	if err != nil {
+		return fmt.Errorf("failed to create SMTP client: %w", err)
+	}
+	defer client.Close()
+
+	if s.cfg.SMTPUser != "" {
+		if err := client.Auth(smtp.PlainAuth("", s.cfg.SMTPUser, s.cfg.SMTPPassword, s.cfg.SMTPHost)); err != nil {
+			return fmt.Errorf("SMTP auth failed: %w", err)
+		}
+	}
+
+	if err := client.Mail(s.cfg.SMTPFrom); err != nil {
+		return fmt.Errorf("MAIL FROM failed: %w", err)
+	}
+	if err := client.Rcpt(to); err != nil {
+		return fmt.Errorf("RCPT TO failed: %w", err)
+	}
+
+	w, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("DATA failed: %w", err)
+	}
+	defer w.Close()
+
+	if _, err := w.Write(data); err != nil {
+		return fmt.Errorf("failed to write message body: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,36 @@
+package email
+
+import "sync"
+
+// TestSender captures every message it's asked to send instead of
+// delivering it, so tests can assert on what would have gone out without
+// needing a real or fake SMTP server.
+type TestSender struct {
+	mu   sync.Mutex
+	sent []Message
+}
+
+// NewTestSender returns an empty TestSender.
+func NewTestSender() *TestSender {
+	return &TestSender{}
+}
+
+func (s *TestSender) Send(msg Message) error {
+	if _, _, err := render(msg); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sent = append(s.sent, msg)
+	return nil
+}
+
+// Sent returns every message captured so far, in send order.
+func (s *TestSender) Sent() []Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sent := make([]Message, len(s.sent))
+	copy(sent, s.sent)
+	return sent
+}
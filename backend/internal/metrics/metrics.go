@@ -0,0 +1,206 @@
+// Package metrics holds the Prometheus collectors shared across
+// middleware and handlers. Nothing exposes them over HTTP yet - no
+// /metrics endpoint has been wired up - but registering them against the
+// default registry now means they're already warm once one is.
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// AuthRateLimitExceeded counts requests rejected by
+// middleware.AuthRateLimit, labeled by the endpoint name passed to it
+// (e.g. "login", "register", "password-reset", "verify-mfa").
+var AuthRateLimitExceeded = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "smart_parking_auth_rate_limit_exceeded_total",
+		Help: "Number of auth requests rejected for exceeding their rate limit, by endpoint.",
+	},
+	[]string{"endpoint"},
+)
+
+// AccountLockouts counts how many times middleware.RecordFailedLogin has
+// locked an account out after too many consecutive failed logins.
+var AccountLockouts = promauto.NewCounter(
+	prometheus.CounterOpts{
+		Name: "smart_parking_account_lockouts_total",
+		Help: "Number of accounts locked out after too many consecutive failed logins.",
+	},
+)
+
+// JobRuns counts jobs.Manager's job executions, labeled by job name and
+// outcome ("success", "error", "skipped_not_leader").
+var JobRuns = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "smart_parking_job_runs_total",
+		Help: "Number of background job executions, by job name and outcome.",
+	},
+	[]string{"job", "outcome"},
+)
+
+// JobDurationSeconds observes how long a job's Run took, labeled by job
+// name. Skipped runs (another instance holds the leader lock) aren't
+// observed here.
+var JobDurationSeconds = promauto.NewHistogramVec(
+	prometheus.HistogramOpts{
+		Name:    "smart_parking_job_duration_seconds",
+		Help:    "How long a background job's Run took, by job name.",
+		Buckets: prometheus.DefBuckets,
+	},
+	[]string{"job"},
+)
+
+// CheckInDurationSeconds observes how long handlers.ProcessCheckIn takes
+// from dequeuing a queue.CheckInJob to finishing spot assignment,
+// labeled by outcome ("success", "no_available_spot", "error").
+var CheckInDurationSeconds = promauto.NewHistogramVec(
+	prometheus.HistogramOpts{
+		Name:    "smart_parking_checkin_duration_seconds",
+		Help:    "How long ProcessCheckIn took to assign a spot, by outcome.",
+		Buckets: prometheus.DefBuckets,
+	},
+	[]string{"outcome"},
+)
+
+// SpotAssignmentFailures counts the ways ProcessCheckIn can fail to hand a
+// reservation a parking spot, labeled by reason
+// ("no_available_spot", "update_booking", "update_space").
+var SpotAssignmentFailures = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "smart_parking_spot_assignment_failures_total",
+		Help: "Number of check-ins that failed to end up with an assigned spot, by reason.",
+	},
+	[]string{"reason"},
+)
+
+// SemaphoreInUse gauges how many of utils.Semaphore's slots are currently
+// held, so saturation (a gauge pinned at its configured capacity) shows up
+// before requests start timing out waiting for a slot.
+var SemaphoreInUse = promauto.NewGauge(
+	prometheus.GaugeOpts{
+		Name: "smart_parking_checkin_semaphore_in_use",
+		Help: "Number of utils.Semaphore slots currently held by an in-flight check-in.",
+	},
+)
+
+// QueueDepth gauges how many messages are waiting in a queue package
+// stream, labeled by stream name, as of the last Enqueue/ack on it.
+var QueueDepth = promauto.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Name: "smart_parking_checkin_queue_depth",
+		Help: "Number of pending messages on a check-in stream, by stream name.",
+	},
+	[]string{"stream"},
+)
+
+// WSConnections gauges how many clients are currently registered with
+// utils.WsManager.
+var WSConnections = promauto.NewGauge(
+	prometheus.GaugeOpts{
+		Name: "smart_parking_ws_connections",
+		Help: "Number of currently registered WebSocket/notification clients.",
+	},
+)
+
+// WSMessagesSent counts messages ws.WebSocketManager has delivered to a
+// client's Send channel, labeled by WebSocketMessage.Type (e.g.
+// "PARKING_UPDATE", "RESERVATION_ADD"). A client whose Send buffer is full
+// and gets dropped instead isn't counted here.
+var WSMessagesSent = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "smart_parking_ws_messages_sent_total",
+		Help: "Number of WebSocket messages delivered to a client's send buffer, by message type.",
+	},
+	[]string{"type"},
+)
+
+// WSDuplicateEventsSuppressed counts events ws.WebSocketManager's dedup
+// recognized as already delivered within the current rolling window and
+// dropped before enqueuing, by WebSocketMessage.Type.
+var WSDuplicateEventsSuppressed = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "smart_parking_ws_duplicate_events_suppressed_total",
+		Help: "Number of WebSocket events recognized as duplicates and dropped before enqueuing, by message type.",
+	},
+	[]string{"type"},
+)
+
+// HTTPRequestsTotal counts every request middleware.Metrics sees through,
+// labeled by method, route (c.FullPath(), so path params stay ungrouped),
+// and response status code.
+var HTTPRequestsTotal = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "smart_parking_http_requests_total",
+		Help: "Number of HTTP requests, by method, route, and status code.",
+	},
+	[]string{"method", "route", "status"},
+)
+
+// HTTPRequestDurationSeconds observes middleware.Metrics' per-request
+// latency, labeled by method and route.
+var HTTPRequestDurationSeconds = promauto.NewHistogramVec(
+	prometheus.HistogramOpts{
+		Name:    "smart_parking_http_request_duration_seconds",
+		Help:    "HTTP request latency in seconds, by method and route.",
+		Buckets: prometheus.DefBuckets,
+	},
+	[]string{"method", "route"},
+)
+
+// ParkingSpacesTotal gauges how many parking_spaces rows exist per lot and
+// type, kept current by jobs.reportParkingMetrics' periodic reconciler.
+var ParkingSpacesTotal = promauto.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Name: "smart_parking_spaces_total",
+		Help: "Number of parking spaces, by lot and space type.",
+	},
+	[]string{"lot_id", "type"},
+)
+
+// ParkingSpacesOccupied gauges how many of those spaces are currently
+// occupied, by lot and type - updated both on every occupancy state change
+// (see handlers.ProcessCheckIn and handlers.CheckOutReservation) and by the
+// same reconciler as ParkingSpacesTotal.
+var ParkingSpacesOccupied = promauto.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Name: "smart_parking_spaces_occupied",
+		Help: "Number of currently occupied parking spaces, by lot and space type.",
+	},
+	[]string{"lot_id", "type"},
+)
+
+// ParkingLotUtilizationRatio gauges occupied/total spaces per lot, so a
+// dashboard doesn't have to do the division itself from the two gauges
+// above.
+var ParkingLotUtilizationRatio = promauto.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Name: "smart_parking_lot_utilization_ratio",
+		Help: "Fraction (0.0-1.0) of a lot's parking spaces currently occupied.",
+	},
+	[]string{"lot_id"},
+)
+
+// ReservationActive gauges how many reservations are currently
+// active/checked-in per lot - the same subquery shape
+// handlers.GetParkingSpaceByLotID / handlers.GetParkingSpaceByID already use
+// per-space, rolled up to the lot level here.
+var ReservationActive = promauto.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Name: "smart_parking_reservation_active",
+		Help: "Number of active/checked-in reservations, by lot.",
+	},
+	[]string{"lot_id"},
+)
+
+// SpaceDwellSeconds observes how long a parking space stayed occupied,
+// sourced from the last_updated transition when jobs.reportParkingMetrics
+// sees a space flip from occupied back to free.
+var SpaceDwellSeconds = promauto.NewHistogramVec(
+	prometheus.HistogramOpts{
+		Name:    "smart_parking_space_dwell_seconds",
+		Help:    "How long a parking space stayed occupied before freeing up, by lot.",
+		Buckets: prometheus.ExponentialBuckets(60, 2, 12), // 1m .. ~2.8 days
+	},
+	[]string{"lot_id"},
+)
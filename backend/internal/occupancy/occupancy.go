@@ -0,0 +1,163 @@
+// Package occupancy fans out Postgres LISTEN/NOTIFY parking_space_changed
+// events (see database/migrations/0013_parking_space_change_notify.sql) to
+// the SSE stream handlers in handlers/parking_handler.go. A single
+// pq.Listener goroutine owns the one Postgres connection LISTEN needs;
+// every subscriber just registers a channel against Current instead of
+// opening its own LISTEN connection per request.
+package occupancy
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/lib/pq"
+
+	"github.com/holycan/smart-parking-system/config"
+	"github.com/holycan/smart-parking-system/database"
+)
+
+// channelName is the Postgres NOTIFY channel the migration's triggers fire
+// on.
+const channelName = "parking_space_changed"
+
+// Event is a single parking_space_changed notification, decoded from the
+// trigger's JSON payload.
+type Event struct {
+	SpaceID string `json:"space_id"`
+	LotID   string `json:"lot_id"`
+}
+
+// Hub fans out Events to subscribers, keyed by the lot ID they asked for.
+type Hub struct {
+	subscribe   chan subscribeRequest
+	unsubscribe chan unsubscribeRequest
+	events      chan Event
+	listener    *pq.Listener
+}
+
+type subscribeRequest struct {
+	lotID string
+	ch    chan Event
+}
+
+type unsubscribeRequest struct {
+	lotID string
+	ch    chan Event
+}
+
+// Current is the process-wide Hub every stream handler subscribes through.
+// It defaults to an inert Hub with no listener attached, so Subscribe is
+// always safe to call even when database.ActiveDriver isn't Postgres (or
+// Init hasn't run yet) - callers just never receive an Event, the same way
+// tracing.Current is always safe to Start a span on.
+var Current = newHub(nil)
+
+func newHub(listener *pq.Listener) *Hub {
+	h := &Hub{
+		subscribe:   make(chan subscribeRequest),
+		unsubscribe: make(chan unsubscribeRequest),
+		events:      make(chan Event, 64),
+		listener:    listener,
+	}
+	go h.run()
+	return h
+}
+
+// Init starts listening on channelName against cfg's DSN and stores the
+// resulting Hub as Current. A no-op when database.ActiveDriver isn't
+// Postgres, since LISTEN/NOTIFY has no SQLite equivalent - stream
+// endpoints still serve their initial snapshot there, they just never see
+// an incremental update.
+func Init(cfg config.DBConfig) error {
+	if database.ActiveDriver != database.DriverPostgres {
+		return nil
+	}
+
+	listener := pq.NewListener(cfg.DSN(), 10*time.Second, time.Minute, func(ev pq.ListenerEventType, err error) {
+		if err != nil {
+			log.Printf("occupancy: listener event: %v", err)
+		}
+	})
+	if err := listener.Listen(channelName); err != nil {
+		return fmt.Errorf("occupancy: failed to LISTEN on %s: %w", channelName, err)
+	}
+
+	hub := newHub(listener)
+	go func() {
+		for n := range listener.Notify {
+			if n == nil {
+				// pq.Listener sends a nil notification after it
+				// transparently reconnects; there's no payload to decode.
+				continue
+			}
+			var ev Event
+			if err := json.Unmarshal([]byte(n.Extra), &ev); err != nil {
+				log.Printf("occupancy: failed to decode notification payload %q: %v", n.Extra, err)
+				continue
+			}
+			hub.events <- ev
+		}
+	}()
+
+	Current = hub
+	return nil
+}
+
+// run owns subscribers - every read/write of it happens on this goroutine,
+// so Subscribe/Unsubscribe/broadcast never need a mutex.
+func (h *Hub) run() {
+	subscribers := make(map[string]map[chan Event]struct{})
+
+	for {
+		select {
+		case req := <-h.subscribe:
+			if subscribers[req.lotID] == nil {
+				subscribers[req.lotID] = make(map[chan Event]struct{})
+			}
+			subscribers[req.lotID][req.ch] = struct{}{}
+
+		case req := <-h.unsubscribe:
+			delete(subscribers[req.lotID], req.ch)
+			if len(subscribers[req.lotID]) == 0 {
+				delete(subscribers, req.lotID)
+			}
+			close(req.ch)
+
+		case ev := <-h.events:
+			for ch := range subscribers[ev.LotID] {
+				select {
+				case ch <- ev:
+				default:
+					// Slow subscriber - drop rather than block every other
+					// lot's fan-out; the stream's next snapshot (a fresh
+					// page load) papers over a missed notification.
+				}
+			}
+		}
+	}
+}
+
+// Subscribe registers a new channel for lotID's notifications. The caller
+// must call Unsubscribe with the same lotID and channel once done, or it
+// leaks for the life of the process.
+func (h *Hub) Subscribe(lotID string) chan Event {
+	ch := make(chan Event, 16)
+	h.subscribe <- subscribeRequest{lotID: lotID, ch: ch}
+	return ch
+}
+
+// Unsubscribe removes ch from lotID's fan-out set and closes it.
+func (h *Hub) Unsubscribe(lotID string, ch chan Event) {
+	h.unsubscribe <- unsubscribeRequest{lotID: lotID, ch: ch}
+}
+
+// Shutdown closes the underlying pq.Listener, if any, so the process can
+// exit cleanly. A no-op when tracing was never enabled on Postgres.
+func Shutdown() error {
+	if Current == nil || Current.listener == nil {
+		return nil
+	}
+	return Current.listener.Close()
+}
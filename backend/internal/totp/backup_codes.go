@@ -0,0 +1,69 @@
+package totp
+
+import (
+	"crypto/rand"
+	"fmt"
+	"regexp"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// backupCodeCount is how many single-use backup codes are issued per MFA
+// setup.
+const backupCodeCount = 10
+
+// backupCodePattern matches the "XXXX-XXXX" shape GenerateBackupCodes
+// produces, so VerifyMFA can tell a backup code apart from a 6-digit TOTP
+// code without a round trip to the database.
+var backupCodePattern = regexp.MustCompile(`^[0-9A-Z]{4}-[0-9A-Z]{4}$`)
+
+const backupCodeAlphabet = "0123456789ABCDEFGHJKLMNPQRSTUVWXYZ"
+
+// GenerateBackupCodes returns backupCodeCount single-use recovery codes in
+// "XXXX-XXXX" form. Callers must store only bcrypt hashes of these, in
+// user_mfa_backup_codes, and return the plaintext codes to the user exactly
+// once.
+func GenerateBackupCodes() ([]string, error) {
+	codes := make([]string, backupCodeCount)
+	for i := range codes {
+		code, err := randomBackupCode()
+		if err != nil {
+			return nil, err
+		}
+		codes[i] = code
+	}
+	return codes, nil
+}
+
+func randomBackupCode() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("totp: failed to generate backup code: %w", err)
+	}
+
+	runes := make([]byte, 8)
+	for i, v := range b {
+		runes[i] = backupCodeAlphabet[int(v)%len(backupCodeAlphabet)]
+	}
+	return fmt.Sprintf("%s-%s", runes[:4], runes[4:]), nil
+}
+
+// IsBackupCodeFormat reports whether code looks like a backup code rather
+// than a 6-digit TOTP/SMS/email code.
+func IsBackupCodeFormat(code string) bool {
+	return backupCodePattern.MatchString(code)
+}
+
+// HashBackupCode bcrypt-hashes a backup code for storage.
+func HashBackupCode(code string) (string, error) {
+	hashed, err := bcrypt.GenerateFromPassword([]byte(code), bcrypt.DefaultCost)
+	if err != nil {
+		return "", fmt.Errorf("totp: failed to hash backup code: %w", err)
+	}
+	return string(hashed), nil
+}
+
+// CompareBackupCode reports whether code matches hash.
+func CompareBackupCode(hash, code string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(code)) == nil
+}
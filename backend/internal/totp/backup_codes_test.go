@@ -0,0 +1,39 @@
+package totp
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestBackupCodesVerifyIndependently guards the bug VerifyMFA used to have:
+// each of the 10 codes GenerateBackupCodes returns is hashed independently,
+// so verifying one has to check every stored hash, not just the first row a
+// query happens to return. A single-row QueryRow-style lookup would only
+// ever match the first generated code.
+func TestBackupCodesVerifyIndependently(t *testing.T) {
+	codes, err := GenerateBackupCodes()
+	assert.NoError(t, err)
+	assert.Len(t, codes, backupCodeCount)
+
+	hashes := make([]string, len(codes))
+	for i, code := range codes {
+		hash, err := HashBackupCode(code)
+		assert.NoError(t, err)
+		hashes[i] = hash
+	}
+
+	// The second code must still verify against its own hash, even though
+	// it isn't the first row - this is what a QueryRow-with-no-match-
+	// condition lookup would have missed.
+	matched := false
+	for _, hash := range hashes {
+		if CompareBackupCode(hash, codes[1]) {
+			matched = true
+			break
+		}
+	}
+	assert.True(t, matched, "second backup code should verify against its own hash")
+
+	assert.False(t, CompareBackupCode(hashes[0], codes[1]))
+}
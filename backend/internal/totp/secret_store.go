@@ -0,0 +1,83 @@
+package totp
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+)
+
+// resolveKey turns the configured MFA_ENCRYPTION_KEY into a 32-byte
+// AES-256 key, accepting either a base64-encoded 32-byte key (preferred,
+// so the env var stays printable) or a raw 32-byte string.
+func resolveKey(rawKey string) ([]byte, error) {
+	if decoded, err := base64.StdEncoding.DecodeString(rawKey); err == nil && len(decoded) == 32 {
+		return decoded, nil
+	}
+	if len(rawKey) == 32 {
+		return []byte(rawKey), nil
+	}
+	return nil, fmt.Errorf("totp: MFA_ENCRYPTION_KEY must be a 32-byte AES-256 key (raw or base64-encoded)")
+}
+
+// EncryptSecret seals secret with AES-GCM under rawKey, returning a
+// base64 string safe to store in the user_mfa.secret column. The nonce is
+// prepended to the ciphertext so Decrypt doesn't need a separate column.
+func EncryptSecret(rawKey string, secret []byte) (string, error) {
+	key, err := resolveKey(rawKey)
+	if err != nil {
+		return "", err
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return "", fmt.Errorf("totp: failed to create cipher: %w", err)
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return "", fmt.Errorf("totp: failed to create GCM: %w", err)
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("totp: failed to generate nonce: %w", err)
+	}
+
+	sealed := gcm.Seal(nonce, nonce, secret, nil)
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// DecryptSecret reverses EncryptSecret.
+func DecryptSecret(rawKey string, stored string) ([]byte, error) {
+	key, err := resolveKey(rawKey)
+	if err != nil {
+		return nil, err
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(stored)
+	if err != nil {
+		return nil, fmt.Errorf("totp: stored secret is not valid base64: %w", err)
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("totp: failed to create cipher: %w", err)
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("totp: failed to create GCM: %w", err)
+	}
+
+	nonceSize := gcm.NonceSize()
+	if len(sealed) < nonceSize {
+		return nil, fmt.Errorf("totp: stored secret is too short")
+	}
+	nonce, ciphertext := sealed[:nonceSize], sealed[nonceSize:]
+
+	secret, err := gcm.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, fmt.Errorf("totp: failed to decrypt secret: %w", err)
+	}
+	return secret, nil
+}
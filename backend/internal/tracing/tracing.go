@@ -0,0 +1,78 @@
+// Package tracing bootstraps the process-wide OpenTelemetry TracerProvider
+// that middleware.Tracing and the check-in worker's span instrumentation
+// pull spans from, exporting to an OTLP/gRPC collector (Jaeger/Tempo).
+// Following the same Current/Init singleton pattern as routing.Current and
+// email's package-level sender, Init resolves Current from config so
+// callers never construct a provider themselves.
+package tracing
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/holycan/smart-parking-system/config"
+)
+
+// Current is the process-wide Tracer every span in this codebase starts
+// from. It is always non-nil: when cfg.Enabled is false, Init leaves it
+// set to the otel global no-op tracer, so callers never need an Enabled
+// check of their own before starting a span.
+var Current trace.Tracer = otel.Tracer("smart-parking-system")
+
+// provider is kept so Shutdown can flush and close it; nil when tracing
+// was never enabled.
+var provider *sdktrace.TracerProvider
+
+// Init resolves Current from cfg. A no-op beyond the package-level no-op
+// tracer when cfg.Enabled is false, since that depends on a collector
+// being reachable at cfg.OTLPEndpoint.
+func Init(cfg config.TracingConfig) error {
+	if !cfg.Enabled {
+		return nil
+	}
+
+	exporter, err := otlptracegrpc.New(
+		context.Background(),
+		otlptracegrpc.WithEndpoint(cfg.OTLPEndpoint),
+		otlptracegrpc.WithInsecure(),
+	)
+	if err != nil {
+		return fmt.Errorf("tracing: failed to create OTLP exporter: %w", err)
+	}
+
+	res, err := resource.New(
+		context.Background(),
+		resource.WithAttributes(semconv.ServiceName(cfg.ServiceName)),
+	)
+	if err != nil {
+		return fmt.Errorf("tracing: failed to build resource: %w", err)
+	}
+
+	provider = sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.TraceIDRatioBased(cfg.SampleRatio)),
+	)
+
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	Current = provider.Tracer(cfg.ServiceName)
+	return nil
+}
+
+// Shutdown flushes any spans still buffered in the batcher and closes the
+// OTLP connection. A no-op when tracing was never enabled.
+func Shutdown(ctx context.Context) error {
+	if provider == nil {
+		return nil
+	}
+	return provider.Shutdown(ctx)
+}
@@ -0,0 +1,27 @@
+// Package version exposes the running binary's build metadata - git
+// commit and build time - baked in at compile time via -ldflags, e.g.:
+//
+//	go build -ldflags "-X .../internal/version.GitHash=$(git rev-parse HEAD) -X .../internal/version.BuildTime=$(date -u +%FT%TZ)"
+//
+// so `server version` and GET /healthz can report exactly what's deployed
+// without shelling out to git at runtime.
+package version
+
+// GitHash and BuildTime are set via -ldflags at build time. Both stay
+// "unknown" for a plain `go build`/`go run` without the flags, which is
+// expected in local development.
+var (
+	GitHash   = "unknown"
+	BuildTime = "unknown"
+)
+
+// Info is the payload GET /healthz returns and `server version` prints.
+type Info struct {
+	GitHash   string `json:"gitHash"`
+	BuildTime string `json:"buildTime"`
+}
+
+// Current returns the running binary's build metadata.
+func Current() Info {
+	return Info{GitHash: GitHash, BuildTime: BuildTime}
+}
@@ -0,0 +1,217 @@
+// Package jobs owns all periodic background database work — reservation
+// lifecycle transitions, occupancy stats, notification purges, and
+// maintenance — behind a single robfig/cron/v3 scheduler with bounded
+// concurrency and per-job status tracking. See registry.go for the actual
+// job set.
+//
+// Every tick is gated by a Redis lock (see lock.AcquireLock) so that when
+// more than one instance of the backend is running, only the one that
+// wins the lock executes a given job — the rest observe the skip and move
+// on to their next scheduled tick.
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	cronLib "github.com/robfig/cron/v3"
+
+	"github.com/holycan/smart-parking-system/config"
+	"github.com/holycan/smart-parking-system/internal/metrics"
+	"github.com/holycan/smart-parking-system/lock"
+)
+
+// leaderLockTTL bounds how long a won job lock is held, slightly longer
+// than the per-run context timeout in runJob so a slow run never loses the
+// lock to another instance mid-execution.
+const leaderLockTTL = 3 * time.Minute
+
+// JobFunc is the work a Job performs on each tick.
+type JobFunc func(ctx context.Context) error
+
+// Job describes one periodic task: its cron schedule and the function that
+// runs it.
+type Job struct {
+	Name     string
+	Schedule string
+	Run      JobFunc
+}
+
+// Status is a point-in-time snapshot of a job's run history, returned by
+// Manager.Statuses for the /api/admin/jobs endpoint.
+type Status struct {
+	Name           string    `json:"name"`
+	Schedule       string    `json:"schedule"`
+	LastRunAt      time.Time `json:"lastRunAt,omitempty"`
+	LastDurationMs int64     `json:"lastDurationMs"`
+	LastError      string    `json:"lastError,omitempty"`
+	NextRunAt      time.Time `json:"nextRunAt,omitempty"`
+}
+
+type jobState struct {
+	mu           sync.Mutex
+	lastRunAt    time.Time
+	lastDuration time.Duration
+	lastErr      error
+	entryID      cronLib.EntryID
+}
+
+// Manager runs a fixed set of Jobs on a shared cron scheduler, bounding how
+// many run concurrently via a worker semaphore so a burst of overlapping
+// schedules can't pile onto the database at once.
+type Manager struct {
+	cron      *cronLib.Cron
+	jobs      []Job
+	states    map[string]*jobState
+	workerSem chan struct{}
+}
+
+// Current is the Manager started by Attach, set at startup so handlers can
+// read job statuses without threading a Manager through every call site —
+// the same pattern utils.WsManager uses for the WebSocket manager.
+var Current *Manager
+
+// Attach registers the built-in job set (see registry.go) onto a new cron
+// scheduler, starts it, stores it as Current, and returns it so the caller
+// can Stop it during shutdown.
+func Attach(cfg config.JobsConfig) *Manager {
+	maxWorkers := cfg.MaxConcurrentWorkers
+	if maxWorkers <= 0 {
+		maxWorkers = 4
+	}
+
+	m := &Manager{
+		cron:      cronLib.New(),
+		jobs:      defaultJobs(),
+		states:    make(map[string]*jobState),
+		workerSem: make(chan struct{}, maxWorkers),
+	}
+
+	for i, job := range m.jobs {
+		if override, ok := cfg.Schedules[job.Name]; ok && override != "" {
+			job.Schedule = override
+			m.jobs[i] = job
+		}
+
+		job := job
+		m.states[job.Name] = &jobState{}
+
+		entryID, err := m.cron.AddFunc(job.Schedule, func() { m.runJob(job) })
+		if err != nil {
+			log.Printf("jobs: failed to schedule %s (%q): %v", job.Name, job.Schedule, err)
+			continue
+		}
+		m.states[job.Name].entryID = entryID
+	}
+
+	m.cron.Start()
+
+	if cfg.RunOnStartup {
+		for _, job := range m.jobs {
+			go m.runJob(job)
+		}
+	}
+
+	Current = m
+	return m
+}
+
+// runJob acquires the distributed leader lock for job, and if won, executes
+// it under the worker semaphore and records its outcome. If another
+// instance holds the lock this tick, the run is skipped entirely — that
+// instance's own run already covers it.
+func (m *Manager) runJob(job Job) {
+	mutex, err := lock.AcquireLock("jobs:lock:"+job.Name, leaderLockTTL)
+	if err != nil {
+		metrics.JobRuns.WithLabelValues(job.Name, "skipped_not_leader").Inc()
+		log.Printf("jobs: %s skipped this tick, another instance holds the lock", job.Name)
+		return
+	}
+	defer lock.ReleaseLock(mutex)
+
+	m.workerSem <- struct{}{}
+	defer func() { <-m.workerSem }()
+
+	start := time.Now()
+	log.Printf("jobs: starting %s", job.Name)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	err = job.Run(ctx)
+	duration := time.Since(start)
+
+	state := m.states[job.Name]
+	state.mu.Lock()
+	state.lastRunAt = start
+	state.lastDuration = duration
+	state.lastErr = err
+	state.mu.Unlock()
+
+	metrics.JobDurationSeconds.WithLabelValues(job.Name).Observe(duration.Seconds())
+
+	if err != nil {
+		metrics.JobRuns.WithLabelValues(job.Name, "error").Inc()
+		log.Printf("jobs: %s failed after %s: %v", job.Name, duration, err)
+		return
+	}
+	metrics.JobRuns.WithLabelValues(job.Name, "success").Inc()
+	log.Printf("jobs: %s completed in %s", job.Name, duration)
+}
+
+// Statuses returns a snapshot of every job's last run, next scheduled run,
+// and last error, for the /api/admin/jobs endpoint.
+func (m *Manager) Statuses() []Status {
+	entries := make(map[cronLib.EntryID]cronLib.Entry)
+	for _, e := range m.cron.Entries() {
+		entries[e.ID] = e
+	}
+
+	statuses := make([]Status, 0, len(m.jobs))
+	for _, job := range m.jobs {
+		state := m.states[job.Name]
+		state.mu.Lock()
+		lastRunAt := state.lastRunAt
+		lastDuration := state.lastDuration
+		lastErr := state.lastErr
+		entryID := state.entryID
+		state.mu.Unlock()
+
+		status := Status{
+			Name:           job.Name,
+			Schedule:       job.Schedule,
+			LastRunAt:      lastRunAt,
+			LastDurationMs: lastDuration.Milliseconds(),
+		}
+		if lastErr != nil {
+			status.LastError = lastErr.Error()
+		}
+		if entry, ok := entries[entryID]; ok {
+			status.NextRunAt = entry.Next
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+// Stop halts the scheduler. Jobs already running are allowed to finish.
+func (m *Manager) Stop() {
+	m.cron.Stop()
+}
+
+// RunNamed looks up name in the built-in job set and runs it once,
+// synchronously, for the `server cron run <job>` CLI command. Unlike a
+// scheduled tick it bypasses the leader lock and worker semaphore - an
+// operator invoking it explicitly wants it to execute now, not be skipped
+// because another instance happens to be mid-tick on it.
+func RunNamed(ctx context.Context, name string) error {
+	for _, job := range defaultJobs() {
+		if job.Name == name {
+			return job.Run(ctx)
+		}
+	}
+	return fmt.Errorf("jobs: no such job %q", name)
+}
@@ -0,0 +1,571 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/holycan/smart-parking-system/config"
+	"github.com/holycan/smart-parking-system/database"
+	"github.com/holycan/smart-parking-system/internal/metrics"
+	"github.com/holycan/smart-parking-system/models"
+	"github.com/holycan/smart-parking-system/services/audit"
+	"github.com/holycan/smart-parking-system/utils"
+)
+
+// defaultJobs is the built-in set of periodic work this subsystem owns.
+func defaultJobs() []Job {
+	return []Job{
+		{Name: "expire_pending_reservations", Schedule: "@every 5m", Run: expirePendingReservations},
+		{Name: "auto_complete_reservations", Schedule: "@every 1m", Run: autoCompleteReservations},
+		{Name: "detect_overstays", Schedule: "@every 1m", Run: detectOverstays},
+		{Name: "auto_checkout_overstayed", Schedule: "@every 1m", Run: autoCheckoutOverstayed},
+		{Name: "send_reservation_reminders", Schedule: "@every 1m", Run: sendReservationReminders},
+		{Name: "refresh_occupancy_stats", Schedule: "@every 10m", Run: refreshOccupancyStats},
+		{Name: "retry_failed_payment_webhooks", Schedule: "@every 15m", Run: retryFailedPaymentWebhooks},
+		{Name: "purge_old_notifications", Schedule: "0 3 * * *", Run: purgeOldNotifications},
+		{Name: "reconcile_parking_spaces", Schedule: "30 3 * * *", Run: reconcileParkingSpaces},
+		{Name: "vacuum_analyze", Schedule: "0 2 * * *", Run: vacuumAnalyze},
+		{Name: "report_parking_metrics", Schedule: "@every 1m", Run: reportParkingMetrics},
+	}
+}
+
+// expirePendingReservations expires reservations still 'pending' once their
+// expired_at has passed. This is the authoritative check against the
+// expired_at column set at creation time; cron.ExpiredTime (still wired up
+// separately in main.go's cronJob) predates this job and does a rougher
+// 24-hour-past-reservation_date check instead.
+func expirePendingReservations(ctx context.Context) error {
+	result, err := database.App.ExecContext(ctx, `
+		UPDATE reservations
+		SET status = 'expired', updated_at = CURRENT_TIMESTAMP
+		WHERE status = 'pending' AND expired_at IS NOT NULL AND expired_at < CURRENT_TIMESTAMP
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to expire pending reservations: %w", err)
+	}
+	if affected, err := result.RowsAffected(); err == nil && affected > 0 {
+		log.Printf("jobs: expired %d pending reservation(s) past their expiry time", affected)
+	}
+	return nil
+}
+
+// autoCompleteReservations marks active/checked-in reservations as
+// completed once their expired_at has passed, and frees the parking space
+// they held.
+func autoCompleteReservations(ctx context.Context) error {
+	rows, err := database.App.QueryContext(ctx, `
+		SELECT id, parking_space_id, user_id, status
+		FROM reservations
+		WHERE status IN ('active', 'checked-in') AND expired_at IS NOT NULL AND expired_at < CURRENT_TIMESTAMP
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to query reservations due for auto-completion: %w", err)
+	}
+
+	type candidate struct {
+		id             string
+		parkingSpaceID string
+		userID         string
+		status         string
+	}
+	var candidates []candidate
+	for rows.Next() {
+		var c candidate
+		if err := rows.Scan(&c.id, &c.parkingSpaceID, &c.userID, &c.status); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan reservation due for auto-completion: %w", err)
+		}
+		candidates = append(candidates, c)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating reservations due for auto-completion: %w", err)
+	}
+
+	for _, c := range candidates {
+		if err := completeReservation(ctx, c.id, c.parkingSpaceID, c.userID, c.status,
+			"auto-completed by background job: expired_at passed",
+			"Your reservation has ended and was automatically completed."); err != nil {
+			log.Printf("jobs: failed to auto-complete reservation %s: %v", c.id, err)
+		}
+	}
+	return nil
+}
+
+// completeReservation transitions a single reservation to 'completed',
+// frees its parking space, and records the transition, all in one
+// transaction. reason is recorded in the audit event's metadata; message is
+// the WS notification sent to the reservation's owner.
+func completeReservation(ctx context.Context, reservationID, parkingSpaceID, userID, previousStatus, reason, message string) error {
+	tx, err := database.App.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `UPDATE reservations SET status = 'completed', updated_at = CURRENT_TIMESTAMP WHERE id = $1`, reservationID); err != nil {
+		return fmt.Errorf("failed to complete reservation: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, `UPDATE parking_spaces SET is_occupied = false WHERE id = $1`, parkingSpaceID); err != nil {
+		return fmt.Errorf("failed to free parking space: %w", err)
+	}
+
+	if err := audit.RecordEvent(tx, reservationID, "", previousStatus, "completed", map[string]interface{}{
+		"reason": reason,
+	}); err != nil {
+		return fmt.Errorf("failed to record audit event: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit completed reservation: %w", err)
+	}
+
+	utils.WsManager.HandleNotificationUpdate(models.NotificationEvent{
+		UserID:        userID,
+		ReservationId: reservationID,
+		Type:          "completed",
+		Message:       message,
+	})
+
+	return nil
+}
+
+// detectOverstays transitions 'active'/'checked-in' reservations whose
+// checkin_time + duration has passed into 'overstay' and accrues
+// config.Get().Jobs.OverstaySurchargePerMinute onto total_cost for every
+// whole minute past that point. A reservation already in 'overstay' keeps
+// accruing for only the minutes elapsed since its last update, so re-runs
+// of this job never double-charge the same minute.
+func detectOverstays(ctx context.Context) error {
+	surcharge := config.Get().Jobs.OverstaySurchargePerMinute
+
+	rows, err := database.App.QueryContext(ctx, `
+		SELECT id, user_id, checkin_time, duration, total_cost, status, updated_at
+		FROM reservations
+		WHERE status IN ('active', 'checked-in', 'overstay') AND checkin_time IS NOT NULL
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to query reservations for overstay detection: %w", err)
+	}
+
+	type candidate struct {
+		id          string
+		userID      string
+		checkinTime time.Time
+		duration    int
+		totalCost   float64
+		status      string
+		updatedAt   time.Time
+	}
+	var candidates []candidate
+	for rows.Next() {
+		var c candidate
+		if err := rows.Scan(&c.id, &c.userID, &c.checkinTime, &c.duration, &c.totalCost, &c.status, &c.updatedAt); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan reservation for overstay detection: %w", err)
+		}
+		candidates = append(candidates, c)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating reservations for overstay detection: %w", err)
+	}
+
+	for _, c := range candidates {
+		limit := c.checkinTime.Add(time.Duration(c.duration) * time.Minute)
+
+		var accruedMinutes int
+		if c.status == "overstay" {
+			accruedMinutes = int(time.Since(c.updatedAt).Minutes())
+		} else {
+			accruedMinutes = int(time.Since(limit).Minutes())
+		}
+		if accruedMinutes <= 0 {
+			continue
+		}
+
+		newTotal := c.totalCost + surcharge*float64(accruedMinutes)
+		if _, err := database.App.ExecContext(ctx, `
+			UPDATE reservations SET status = 'overstay', total_cost = $1, updated_at = CURRENT_TIMESTAMP WHERE id = $2
+		`, newTotal, c.id); err != nil {
+			log.Printf("jobs: failed to accrue overstay surcharge for reservation %s: %v", c.id, err)
+			continue
+		}
+
+		if c.status != "overstay" {
+			if err := audit.RecordEvent(database.App, c.id, "", c.status, "overstay", map[string]interface{}{
+				"reason":          "checkin_time + duration passed without checkout",
+				"surcharge_total": newTotal - c.totalCost,
+			}); err != nil {
+				log.Printf("jobs: failed to record overstay audit event for reservation %s: %v", c.id, err)
+			}
+			utils.WsManager.HandleNotificationUpdate(models.NotificationEvent{
+				UserID:        c.userID,
+				ReservationId: c.id,
+				Type:          "overstay",
+				Message:       fmt.Sprintf("Your reservation is over its reserved time and is now accruing a $%.2f/min surcharge.", surcharge),
+			})
+		}
+	}
+	return nil
+}
+
+// autoCheckoutOverstayed force-completes reservations that have sat in
+// 'overstay' for longer than config.Get().Jobs.OverstayGraceMinutes,
+// freeing their parking space the same way autoCompleteReservations does.
+func autoCheckoutOverstayed(ctx context.Context) error {
+	grace := config.Get().Jobs.OverstayGraceMinutes
+
+	var (
+		query string
+		args  []interface{}
+	)
+	if database.ActiveDriver == database.DriverSQLite {
+		query = `SELECT id, parking_space_id, user_id FROM reservations WHERE status = 'overstay' AND updated_at < datetime('now', '-' || ? || ' minutes')`
+		args = []interface{}{grace}
+	} else {
+		query = `SELECT id, parking_space_id, user_id FROM reservations WHERE status = 'overstay' AND updated_at < CURRENT_TIMESTAMP - make_interval(mins => $1)`
+		args = []interface{}{grace}
+	}
+
+	rows, err := database.App.QueryContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to query overstayed reservations due for auto-checkout: %w", err)
+	}
+
+	type candidate struct {
+		id             string
+		parkingSpaceID string
+		userID         string
+	}
+	var candidates []candidate
+	for rows.Next() {
+		var c candidate
+		if err := rows.Scan(&c.id, &c.parkingSpaceID, &c.userID); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan overstayed reservation: %w", err)
+		}
+		candidates = append(candidates, c)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating overstayed reservations: %w", err)
+	}
+
+	for _, c := range candidates {
+		if err := completeReservation(ctx, c.id, c.parkingSpaceID, c.userID, "overstay",
+			fmt.Sprintf("auto-checked-out by background job: %d minute overstay grace period passed", grace),
+			"Your reservation was automatically checked out after its overstay grace period expired."); err != nil {
+			log.Printf("jobs: failed to auto-checkout overstayed reservation %s: %v", c.id, err)
+		}
+	}
+	return nil
+}
+
+// sendReservationReminders fires a WS notification to a reservation's
+// owner when the time remaining before checkin_time + duration crosses one
+// of config.Get().Jobs.ReminderIntervalsMinutes. It runs every minute, so
+// each interval is expected to be crossed exactly once per reservation.
+func sendReservationReminders(ctx context.Context) error {
+	intervals := config.Get().Jobs.ReminderIntervalsMinutes
+
+	rows, err := database.App.QueryContext(ctx, `
+		SELECT id, user_id, checkin_time, duration, total_cost
+		FROM reservations
+		WHERE status IN ('active', 'checked-in') AND checkin_time IS NOT NULL
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to query active reservations for reminders: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			id          string
+			userID      string
+			checkinTime time.Time
+			duration    int
+			totalCost   float64
+		)
+		if err := rows.Scan(&id, &userID, &checkinTime, &duration, &totalCost); err != nil {
+			return fmt.Errorf("failed to scan reservation for reminders: %w", err)
+		}
+
+		limit := checkinTime.Add(time.Duration(duration) * time.Minute)
+		remaining := time.Until(limit)
+
+		for _, minutes := range intervals {
+			interval := time.Duration(minutes) * time.Minute
+			if remaining <= interval && remaining > interval-time.Minute {
+				utils.WsManager.HandleNotificationUpdate(models.NotificationEvent{
+					UserID:        userID,
+					ReservationId: id,
+					Type:          "time_limit",
+					Message:       fmt.Sprintf("Your reservation time limit is about to expire in %d minutes and cost you $%.2f", minutes, totalCost),
+				})
+				log.Printf("jobs: sent %d-minute reminder for reservation %s", minutes, id)
+				break
+			}
+		}
+	}
+	return rows.Err()
+}
+
+// refreshOccupancyStats refreshes the parking_lot_occupancy_stats
+// materialized view (see migration 0004). This is Postgres-only — SQLite
+// has no materialized view equivalent, and no SQLite deployment serves
+// occupancy stats from it today.
+func refreshOccupancyStats(ctx context.Context) error {
+	if database.ActiveDriver != database.DriverPostgres {
+		return nil
+	}
+	if _, err := database.App.ExecContext(ctx, `REFRESH MATERIALIZED VIEW CONCURRENTLY parking_lot_occupancy_stats`); err != nil {
+		return fmt.Errorf("failed to refresh parking_lot_occupancy_stats: %w", err)
+	}
+	return nil
+}
+
+// retryFailedPaymentWebhooks looks for payments stuck in 'failed' and logs
+// them for now. There's no payment gateway integration in this codebase yet
+// (see services/parking_services.go and models/payment_model.go — no
+// webhook client exists), so this is an honest stub: it surfaces what would
+// need retrying rather than actually calling out anywhere.
+func retryFailedPaymentWebhooks(ctx context.Context) error {
+	rows, err := database.App.QueryContext(ctx, `SELECT id, reservation_id FROM payments WHERE status = 'failed'`)
+	if err != nil {
+		return fmt.Errorf("failed to query failed payments: %w", err)
+	}
+	defer rows.Close()
+
+	var count int
+	for rows.Next() {
+		var paymentID, reservationID string
+		if err := rows.Scan(&paymentID, &reservationID); err != nil {
+			return fmt.Errorf("failed to scan failed payment: %w", err)
+		}
+		count++
+		log.Printf("jobs: payment %s for reservation %s is still failed; no payment gateway is integrated yet to retry it", paymentID, reservationID)
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating failed payments: %w", err)
+	}
+
+	if count > 0 {
+		log.Printf("jobs: %d failed payment(s) found pending a real retry implementation", count)
+	}
+	return nil
+}
+
+// purgeOldNotifications deletes read notifications older than 30 days so
+// the table doesn't grow unbounded.
+func purgeOldNotifications(ctx context.Context) error {
+	var (
+		query string
+		args  []interface{}
+	)
+	if database.ActiveDriver == database.DriverSQLite {
+		query = `DELETE FROM notifications WHERE is_read = 1 AND created_at < datetime('now', '-30 days')`
+	} else {
+		query = `DELETE FROM notifications WHERE is_read = true AND created_at < NOW() - INTERVAL '30 days'`
+	}
+
+	result, err := database.App.ExecContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to purge old notifications: %w", err)
+	}
+	if affected, err := result.RowsAffected(); err == nil && affected > 0 {
+		log.Printf("jobs: purged %d old read notification(s)", affected)
+	}
+	return nil
+}
+
+// reconcileParkingSpaces frees parking spaces left marked occupied by a
+// reservation that's since moved to 'expired' or 'cancelled' without
+// clearing is_occupied itself — a gap earlier cron.ExpiredTime (and bugs in
+// handlers that touch parking_spaces directly) could leave behind. A space
+// held by any other active/checked-in/overstay reservation is left alone.
+func reconcileParkingSpaces(ctx context.Context) error {
+	result, err := database.App.ExecContext(ctx, `
+		UPDATE parking_spaces
+		SET is_occupied = false
+		WHERE is_occupied = true
+		  AND id IN (
+		      SELECT parking_space_id FROM reservations WHERE status IN ('expired', 'cancelled')
+		  )
+		  AND id NOT IN (
+		      SELECT parking_space_id FROM reservations WHERE status IN ('active', 'checked-in', 'overstay')
+		  )
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to reconcile stale parking space occupancy: %w", err)
+	}
+	if affected, err := result.RowsAffected(); err == nil && affected > 0 {
+		log.Printf("jobs: reclaimed %d parking space(s) left occupied by an expired/cancelled reservation", affected)
+	}
+	return nil
+}
+
+// vacuumAnalyze runs VACUUM ANALYZE nightly. Postgres-only — SQLite's
+// equivalent (PRAGMA optimize / VACUUM) isn't needed at this scale and
+// SQLite is only used for local dev and the R-tree-backed parking lot
+// lookup, not production.
+func vacuumAnalyze(ctx context.Context) error {
+	if database.ActiveDriver != database.DriverPostgres {
+		return nil
+	}
+	if _, err := database.App.ExecContext(ctx, `VACUUM ANALYZE`); err != nil {
+		return fmt.Errorf("failed to run VACUUM ANALYZE: %w", err)
+	}
+	return nil
+}
+
+// dwellState tracks, per parking space, what reportParkingMetrics observed
+// on its last tick — so the next tick can tell an occupied-to-free
+// transition from a space that was already free, and observe how long it
+// was occupied for. Guarded by dwellStateMu since it's read and written
+// across ticks of the same job (serialized by the leader lock, but kept
+// safe regardless).
+var (
+	dwellStateMu sync.Mutex
+	dwellState   = map[string]dwellEntry{}
+)
+
+type dwellEntry struct {
+	lotID       string
+	occupied    bool
+	lastUpdated time.Time
+}
+
+// reportParkingMetrics refreshes the parking_spaces_total/occupied gauges,
+// the per-lot utilization ratio, the active-reservation gauge, and the
+// space dwell histogram. This is the periodic correction pass — handlers
+// that flip a space's occupancy (see
+// services.UpdateParkingSpaceOccupied) update the occupied gauge and
+// utilization ratio immediately, but this still re-derives everything from
+// the database so the two never drift apart for long.
+func reportParkingMetrics(ctx context.Context) error {
+	rows, err := database.App.QueryContext(ctx, `
+		SELECT parking_lot_id, type,
+		       COUNT(*) AS total,
+		       COUNT(CASE WHEN is_occupied THEN 1 END) AS occupied
+		FROM parking_spaces
+		GROUP BY parking_lot_id, type
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to aggregate parking space occupancy: %w", err)
+	}
+
+	type lotTotals struct{ total, occupied int }
+	lotSums := map[string]*lotTotals{}
+
+	for rows.Next() {
+		var lotID, spaceType string
+		var total, occupied int
+		if err := rows.Scan(&lotID, &spaceType, &total, &occupied); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan parking space occupancy row: %w", err)
+		}
+		metrics.ParkingSpacesTotal.WithLabelValues(lotID, spaceType).Set(float64(total))
+		metrics.ParkingSpacesOccupied.WithLabelValues(lotID, spaceType).Set(float64(occupied))
+
+		sum := lotSums[lotID]
+		if sum == nil {
+			sum = &lotTotals{}
+			lotSums[lotID] = sum
+		}
+		sum.total += total
+		sum.occupied += occupied
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating parking space occupancy rows: %w", err)
+	}
+
+	for lotID, sum := range lotSums {
+		if sum.total == 0 {
+			continue
+		}
+		metrics.ParkingLotUtilizationRatio.WithLabelValues(lotID).Set(float64(sum.occupied) / float64(sum.total))
+	}
+
+	reservationRows, err := database.App.QueryContext(ctx, `
+		SELECT parking_lot_id, COUNT(*)
+		FROM reservations
+		WHERE status IN ('active', 'checked-in')
+		GROUP BY parking_lot_id
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to aggregate active reservations: %w", err)
+	}
+	for reservationRows.Next() {
+		var lotID string
+		var active int
+		if err := reservationRows.Scan(&lotID, &active); err != nil {
+			reservationRows.Close()
+			return fmt.Errorf("failed to scan active reservation count: %w", err)
+		}
+		metrics.ReservationActive.WithLabelValues(lotID).Set(float64(active))
+	}
+	reservationRows.Close()
+	if err := reservationRows.Err(); err != nil {
+		return fmt.Errorf("error iterating active reservation counts: %w", err)
+	}
+
+	return reportSpaceDwellTransitions(ctx)
+}
+
+// reportSpaceDwellTransitions walks every parking space's current
+// occupancy and last_updated, diffing against dwellState to find spaces
+// that just freed up, and observes how long they were occupied into
+// metrics.SpaceDwellSeconds.
+func reportSpaceDwellTransitions(ctx context.Context) error {
+	rows, err := database.App.QueryContext(ctx, `SELECT id, parking_lot_id, is_occupied, last_updated FROM parking_spaces`)
+	if err != nil {
+		return fmt.Errorf("failed to load parking spaces for dwell tracking: %w", err)
+	}
+	defer rows.Close()
+
+	dwellStateMu.Lock()
+	defer dwellStateMu.Unlock()
+
+	seen := make(map[string]bool)
+	for rows.Next() {
+		var id, lotID string
+		var occupied bool
+		var lastUpdated time.Time
+		if err := rows.Scan(&id, &lotID, &occupied, &lastUpdated); err != nil {
+			return fmt.Errorf("failed to scan parking space for dwell tracking: %w", err)
+		}
+		seen[id] = true
+
+		prev, tracked := dwellState[id]
+		switch {
+		case occupied && (!tracked || !prev.occupied):
+			// Just became occupied (or this is the first tick we've seen
+			// it occupied) — anchor lastUpdated as the start of its dwell.
+			dwellState[id] = dwellEntry{lotID: lotID, occupied: true, lastUpdated: lastUpdated}
+		case occupied:
+			// Still occupied — keep the original anchor even if
+			// last_updated moved for an unrelated reason.
+		case tracked && prev.occupied:
+			if dwell := lastUpdated.Sub(prev.lastUpdated).Seconds(); dwell > 0 {
+				metrics.SpaceDwellSeconds.WithLabelValues(lotID).Observe(dwell)
+			}
+			delete(dwellState, id)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating parking spaces for dwell tracking: %w", err)
+	}
+
+	for id := range dwellState {
+		if !seen[id] {
+			delete(dwellState, id)
+		}
+	}
+	return nil
+}
@@ -3,26 +3,26 @@ package lock
 import (
 	"context"
 	"log"
-	"os"
 	"time"
 
 	"github.com/go-redsync/redsync/v4"
 	redsync_goredis "github.com/go-redsync/redsync/v4/redis/goredis/v9"
 	goredis "github.com/redis/go-redis/v9"
+
+	"github.com/holycan/smart-parking-system/config"
 )
 
 var (
 	Redsync *redsync.Redsync
+
+	// RedisClient is shared by anything that needs raw Redis access (e.g.
+	// the idempotency middleware) instead of opening a second connection.
+	RedisClient *goredis.Client
 )
 
 func InitializeRedisLock() {
-	redisAddr := os.Getenv("REDIS_ADDR")
-	if redisAddr == "" {
-		redisAddr = "localhost:6379"
-	}
-
 	client := goredis.NewClient(&goredis.Options{
-		Addr: redisAddr,
+		Addr: config.Ensure().Redis.Addr,
 	})
 	if err := client.Ping(context.Background()).Err(); err != nil {
 		log.Fatalf("Failed to connect to Redis: %v", err)
@@ -30,6 +30,7 @@ func InitializeRedisLock() {
 
 	pool := redsync_goredis.NewPool(client)
 	Redsync = redsync.New(pool)
+	RedisClient = client
 
 	log.Println("Redis lock initialized successfully")
 }
@@ -2,35 +2,47 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"log"
 	"math"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
 	"path/filepath"
+	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
-	"github.com/joho/godotenv"
-	cronLib "github.com/robfig/cron/v3"
+	googlegrpc "google.golang.org/grpc"
 
-	"github.com/holycan/smart-parking-system/cron"
+	"github.com/holycan/smart-parking-system/auth/keys"
+	"github.com/holycan/smart-parking-system/config"
 	"github.com/holycan/smart-parking-system/database"
+	"github.com/holycan/smart-parking-system/database/migrations"
+	"github.com/holycan/smart-parking-system/filestorage"
+	grpcapi "github.com/holycan/smart-parking-system/grpc"
 	"github.com/holycan/smart-parking-system/handlers"
+	"github.com/holycan/smart-parking-system/internal/email"
+	"github.com/holycan/smart-parking-system/internal/occupancy"
+	"github.com/holycan/smart-parking-system/internal/tracing"
+	"github.com/holycan/smart-parking-system/internal/version"
+	"github.com/holycan/smart-parking-system/jobs"
 	"github.com/holycan/smart-parking-system/lock"
+	"github.com/holycan/smart-parking-system/middleware"
+	"github.com/holycan/smart-parking-system/queue"
 	"github.com/holycan/smart-parking-system/routes"
+	"github.com/holycan/smart-parking-system/routing"
+	"github.com/holycan/smart-parking-system/services"
+	fsm "github.com/holycan/smart-parking-system/services/booking"
 	"github.com/holycan/smart-parking-system/utils"
 	"github.com/holycan/smart-parking-system/ws"
+	"github.com/holycan/smart-parking-system/ws/broker"
 )
 
-func initEnvironment() {
-	if err := godotenv.Load(); err != nil {
-		log.Println("Warning: .env file not found, using system environment variables")
-	}
-}
-
 func initDatabaseWithRetry() {
 	err := database.Initialize()
 	if err == nil {
@@ -58,23 +70,40 @@ func initDatabaseWithRetry() {
 }
 
 func initWebSocketManager() {
-	utils.WsManager = ws.NewWebSocketManager()
+	wsCfg := config.Get().WS
+	rateLimitCfg := config.Get().RateLimit
+	frameLimiter := middleware.NewLimiter(rateLimitCfg, lock.RedisClient, rateLimitCfg.WSFrameBurst, time.Second)
+	utils.WsManager = ws.NewWebSocketManager(wsCfg.ReplayBufferSize, wsCfg.CompressionLevel, wsCfg.CompressionThreshold, frameLimiter)
 	go func() {
 		log.Println("WebSocket manager starting...")
 		utils.WsManager.Start()
 		log.Println("WebSocket manager stopped.")
 	}()
+
+	// Relays events published by other replicas into this one - see
+	// ws/broker. Shares lock.RedisClient rather than opening a second
+	// Redis connection, same as the idempotency middleware does.
+	broker.Init(lock.RedisClient, utils.WsManager.Dispatch)
 }
 
 func setupRouter() *gin.Engine {
 	gin.SetMode(gin.ReleaseMode)
 	router := gin.New()
+
+	httpCfg := config.Get().HTTP
+	if httpCfg.TrustedPlatform != "" {
+		router.TrustedPlatform = httpCfg.TrustedPlatform
+	} else if err := router.SetTrustedProxies(httpCfg.TrustedProxies); err != nil {
+		log.Fatalf("Invalid HTTP_TRUSTED_PROXIES: %v", err)
+	}
+
 	router.Use(gin.Recovery())
 	router.Use(utils.Logger())
+	router.Use(middleware.SecurityHeaders(httpCfg.TLSCertFile != "" && httpCfg.TLSKeyFile != ""))
 	router.Static("/qrcodes", "./static/qrcodes")
 
 	router.Use(cors.New(cors.Config{
-		AllowOrigins:     []string{"http://localhost:3000", "http://localhost:8080"},
+		AllowOrigins:     config.Get().CORS.AllowedOrigins,
 		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
 		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization"},
 		ExposeHeaders:    []string{"Content-Length"},
@@ -110,42 +139,9 @@ func setupRouter() *gin.Engine {
 	return router
 }
 
-func processParkingRequest() {
-	for req := range utils.ParkingQueue {
-		// Memproses request parkir secara asinkron dengan semaphore dan fault tolerance
-		err := handlers.ProcessCheckIn(req)
-		if err != nil {
-			log.Println("Check-in failed:", err)
-		}
-	}
-}
-
-func cronJob() {
-	c := cronLib.New()
-
-	// Schedule the TimeLimit cron job (every 15 minutes)
-	_, err := c.AddFunc("*/1 * * * *", cron.TimeLimit)
-	if err != nil {
-		log.Fatalf("Error scheduling TimeLimit cron job: %v", err)
-	}
-
-	// Schedule the Expired cron job (every day at midnight)
-	_, err = c.AddFunc("0 0 * * *", cron.ExpiredTime)
-	if err != nil {
-		log.Fatalf("Error scheduling Expired cron job: %v", err)
-	}
-
-	// Start cron jobs in a separate goroutine so they don't block the main goroutine
-	go func() {
-		c.Start()
-	}()
-}
-
 func startServer(router *gin.Engine) *http.Server {
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
-	}
+	httpCfg := config.Get().HTTP
+	port := httpCfg.Port
 
 	server := &http.Server{
 		Addr:         ":" + port,
@@ -157,8 +153,8 @@ func startServer(router *gin.Engine) *http.Server {
 
 	go func() {
 		log.Printf("Server starting on port %s...\n", port)
-		certFile := os.Getenv("TLS_CERT_FILE")
-		keyFile := os.Getenv("TLS_KEY_FILE")
+		certFile := httpCfg.TLSCertFile
+		keyFile := httpCfg.TLSKeyFile
 
 		var err error
 		if certFile != "" && keyFile != "" {
@@ -176,21 +172,73 @@ func startServer(router *gin.Engine) *http.Server {
 	return server
 }
 
-func gracefulShutdown(server *http.Server) {
+// startGRPCServer starts the gRPC API alongside the HTTP server when
+// config.Get().GRPC.Enabled, listening on its own port since gRPC and
+// Gin can't share a net.Listener.
+func startGRPCServer() *googlegrpc.Server {
+	grpcCfg := config.Get().GRPC
+	if !grpcCfg.Enabled {
+		return nil
+	}
+
+	listener, err := net.Listen("tcp", ":"+grpcCfg.Port)
+	if err != nil {
+		log.Fatalf("Failed to listen for gRPC on port %s: %v", grpcCfg.Port, err)
+	}
+
+	server := grpcapi.NewGRPCServer(grpcCfg.Reflection)
+	go func() {
+		log.Printf("gRPC server starting on port %s...\n", grpcCfg.Port)
+		if err := server.Serve(listener); err != nil {
+			log.Printf("gRPC server stopped: %v", err)
+		}
+	}()
+	return server
+}
+
+// shutdownTimeout reads config.HTTP.ShutdownTimeoutSeconds, falling back to
+// 10s if it's unset (e.g. config.Load was never called, as in some test
+// setups) so callers never accidentally get a zero-duration deadline.
+func shutdownTimeout() time.Duration {
+	seconds := config.Get().HTTP.ShutdownTimeoutSeconds
+	if seconds <= 0 {
+		seconds = 10
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+func gracefulShutdown(server *http.Server, grpcServer *googlegrpc.Server) {
 	signal.Notify(utils.ShutdownChan, syscall.SIGINT, syscall.SIGTERM)
 	<-utils.ShutdownChan
 	log.Println("Shutting down server...")
 
 	// Stop WebSocket manager
 	utils.WsManager.Stop()
+	broker.Stop()
+
+	if grpcServer != nil {
+		grpcServer.GracefulStop()
+	}
+
+	// Stop the background job scheduler
+	if jobs.Current != nil {
+		jobs.Current.Stop()
+	}
 
 	// Gracefully shut down the server with a timeout
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout())
 	defer cancel()
 
 	// Close database connection during shutdown
 	database.Close()
 
+	if err := tracing.Shutdown(ctx); err != nil {
+		log.Printf("Failed to flush tracer provider: %v", err)
+	}
+	if err := occupancy.Shutdown(); err != nil {
+		log.Printf("Failed to close occupancy listener: %v", err)
+	}
+
 	// Attempt to gracefully shut down the HTTP server
 	if err := server.Shutdown(ctx); err != nil {
 		log.Fatalf("Server forced to shutdown: %v", err)
@@ -199,19 +247,244 @@ func gracefulShutdown(server *http.Server) {
 	log.Println("Server exited properly")
 }
 
+// runMigrationCommand handles `./server migrate <up|down|status|create>`
+// without pulling in a CLI framework. Subcommands other than create connect
+// to the database directly (bypassing database.Initialize, which itself runs
+// migrations) so the command works even against a schema that's behind.
+func runMigrationCommand(args []string) {
+	if len(args) < 2 {
+		log.Fatal("Usage: migrate <up|down|status|create <name>>")
+	}
+
+	if args[1] == "create" {
+		if len(args) < 3 {
+			log.Fatal("Usage: migrate create <name>")
+		}
+		if err := database.CreateMigration(args[2]); err != nil {
+			log.Fatalf("Error creating migration: %v", err)
+		}
+		return
+	}
+
+	if _, err := config.Load(os.Args[2:]); err != nil {
+		log.Fatalf("Error loading configuration: %v", err)
+	}
+	if err := database.Connect(); err != nil {
+		log.Fatalf("Error connecting to database: %v", err)
+	}
+	defer database.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	if err := database.Migrate(ctx, database.GetDB(), args[1], 0); err != nil {
+		log.Fatalf("Migration %s failed: %v", args[1], err)
+	}
+	log.Printf("Migration %s completed successfully", args[1])
+}
+
+// runWorkerCommand handles `./server worker`: it runs the check-in queue
+// consumer loop instead of the HTTP/gRPC API, one goroutine per partition
+// returned by queue.Partitions, until utils.ShutdownChan fires.
+func runWorkerCommand() {
+	if _, err := config.Load(os.Args[2:]); err != nil {
+		log.Fatalf("Error loading configuration: %v", err)
+	}
+
+	initDatabaseWithRetry()
+	defer database.Close()
+
+	lock.InitializeRedisLock()
+	routing.Init(config.Get().Routing)
+	if err := tracing.Init(config.Get().Tracing); err != nil {
+		log.Printf("Failed to initialize tracing: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Resolve any bookings a previous worker process left mid-transition
+	// (e.g. killed right after locking a spot, before check-in completed)
+	// before this one starts pulling new jobs off the stream.
+	if err := fsm.RehydrateInFlight(ctx); err != nil {
+		log.Printf("Failed to rehydrate in-flight bookings: %v", err)
+	}
+
+	if err := services.Expiry.LoadPending(ctx); err != nil {
+		log.Printf("Failed to load pending reservation deadlines: %v", err)
+	}
+	go services.Expiry.Run(ctx)
+
+	var processed int64
+	var wg sync.WaitGroup
+	for i, stream := range queue.Partitions() {
+		consumerName := fmt.Sprintf("worker-%d", i)
+		wg.Add(1)
+		go func(stream, consumerName string) {
+			defer wg.Done()
+			queue.Consume(ctx, stream, consumerName, func(job queue.CheckInJob) error {
+				err := handlers.ProcessCheckIn(job.UserID, job.ReservationID, job.OriginLat, job.OriginLng)
+				atomic.AddInt64(&processed, 1)
+				return err
+			})
+		}(stream, consumerName)
+	}
+
+	signal.Notify(utils.ShutdownChan, syscall.SIGINT, syscall.SIGTERM)
+	<-utils.ShutdownChan
+	log.Println("Worker shutting down...")
+	cancel()
+
+	// Give every Consume loop a bounded window to finish whatever message
+	// it's mid-handling and return, so database.Close() below doesn't race
+	// an in-flight handler. A message still mid-flight when the timeout
+	// expires isn't lost - it's left unacked in its stream's consumer
+	// group and will be picked up by XAUTOCLAIM on the next worker that
+	// starts, same as a worker that crashed outright.
+	drained := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(drained)
+	}()
+	select {
+	case <-drained:
+		log.Printf("Worker drained cleanly: %d check-ins processed", atomic.LoadInt64(&processed))
+	case <-time.After(shutdownTimeout()):
+		log.Printf("Worker shutdown timed out waiting for consumers; %d check-ins processed, remaining messages stay claimable by the next worker", atomic.LoadInt64(&processed))
+	}
+
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), shutdownTimeout())
+	defer shutdownCancel()
+	if err := tracing.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Failed to flush tracer provider: %v", err)
+	}
+}
+
+// runVersionCommand handles `./server version`: prints the build metadata
+// baked in via -ldflags (see internal/version), the same metadata GET
+// /healthz reports, without needing a running server to ask.
+func runVersionCommand() {
+	info := version.Current()
+	fmt.Printf("smart-parking-system git=%s built=%s\n", info.GitHash, info.BuildTime)
+}
+
+// runSeedCommand handles `./server seed`: inserts the bundled sample
+// parking lots/spaces (see migrations.SeedSampleData) on demand, for
+// populating a fresh local database without re-running migrate or setting
+// SEED_DATA=true for the 0002 migration.
+func runSeedCommand() {
+	if _, err := config.Load(os.Args[2:]); err != nil {
+		log.Fatalf("Error loading configuration: %v", err)
+	}
+	if err := database.Connect(); err != nil {
+		log.Fatalf("Error connecting to database: %v", err)
+	}
+	defer database.Close()
+
+	tx, err := database.GetDB().Begin()
+	if err != nil {
+		log.Fatalf("Error starting seed transaction: %v", err)
+	}
+	if err := migrations.SeedSampleData(tx); err != nil {
+		tx.Rollback()
+		log.Fatalf("Seeding failed: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		log.Fatalf("Error committing seed transaction: %v", err)
+	}
+	log.Println("Seed completed successfully")
+}
+
+// runCronCommand handles `./server cron run <job>`: runs one named job
+// (see jobs.defaultJobs for the names) once, immediately, outside its
+// normal schedule - for an operator who needs a job to run right now
+// instead of waiting for its next tick.
+func runCronCommand(args []string) {
+	if len(args) < 3 || args[1] != "run" {
+		log.Fatal("Usage: cron run <job>")
+	}
+	jobName := args[2]
+
+	if _, err := config.Load(os.Args[3:]); err != nil {
+		log.Fatalf("Error loading configuration: %v", err)
+	}
+	if err := database.Connect(); err != nil {
+		log.Fatalf("Error connecting to database: %v", err)
+	}
+	defer database.Close()
+
+	lock.InitializeRedisLock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	if err := jobs.RunNamed(ctx, jobName); err != nil {
+		log.Fatalf("cron run %s failed: %v", jobName, err)
+	}
+	log.Printf("cron run %s completed successfully", jobName)
+}
+
 func main() {
-	initEnvironment()
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		runMigrationCommand(os.Args[1:])
+		return
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "worker" {
+		runWorkerCommand()
+		return
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "version" {
+		runVersionCommand()
+		return
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "seed" {
+		runSeedCommand()
+		return
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "cron" {
+		runCronCommand(os.Args[1:])
+		return
+	}
+
+	if _, err := config.Load(os.Args[1:]); err != nil {
+		log.Fatalf("Error loading configuration: %v", err)
+	}
+	config.WatchReload()
+
 	initDatabaseWithRetry()
 	defer database.Close()
 
 	lock.InitializeRedisLock()
 
+	if err := keys.Init(config.Get().JWT); err != nil {
+		log.Fatalf("Failed to load JWT signing keys: %v", err)
+	}
+
+	email.Init(config.Get().Notifications, config.Get().Env)
+	routing.Init(config.Get().Routing)
+	filestorage.Init(config.Get().FileStorage)
+	if err := tracing.Init(config.Get().Tracing); err != nil {
+		log.Printf("Failed to initialize tracing: %v", err)
+	}
+	if err := occupancy.Init(config.Get().DB); err != nil {
+		log.Printf("Failed to initialize occupancy stream: %v", err)
+	}
+
 	initWebSocketManager()
 
-	go processParkingRequest()
-	cronJob()
+	if err := services.Expiry.LoadPending(context.Background()); err != nil {
+		log.Printf("Failed to load pending reservation deadlines: %v", err)
+	}
+	go services.Expiry.Run(context.Background())
+
+	jobs.Attach(config.Get().Jobs)
 
 	router := setupRouter()
 	server := startServer(router)
-	gracefulShutdown(server)
+	grpcServer := startGRPCServer()
+	gracefulShutdown(server, grpcServer)
 }
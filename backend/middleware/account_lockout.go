@@ -0,0 +1,86 @@
+package middleware
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/holycan/smart-parking-system/config"
+	"github.com/holycan/smart-parking-system/database"
+	"github.com/holycan/smart-parking-system/internal/metrics"
+)
+
+// IsAccountLocked reports whether userID is currently under a progressive
+// lockout from RecordFailedLogin, and until when.
+func IsAccountLocked(userID string) (bool, time.Time, error) {
+	var failedAttempts int
+	var lockedUntil sql.NullTime
+	err := database.App.QueryRow(
+		"SELECT failed_attempts, locked_until FROM user_lockouts WHERE user_id = $1",
+		userID,
+	).Scan(&failedAttempts, &lockedUntil)
+	if err == sql.ErrNoRows {
+		return false, time.Time{}, nil
+	}
+	if err != nil {
+		return false, time.Time{}, err
+	}
+
+	if lockedUntil.Valid && time.Now().Before(lockedUntil.Time) {
+		return true, lockedUntil.Time, nil
+	}
+	return false, time.Time{}, nil
+}
+
+// RecordFailedLogin increments userID's consecutive failed-login count
+// and, once it reaches cfg.Threshold, locks the account out for an
+// exponentially increasing backoff - doubling for each failure past the
+// threshold, capped at cfg.MaxBackoffSeconds.
+func RecordFailedLogin(userID string, cfg config.LockoutConfig) error {
+	now := time.Now()
+
+	var failedAttempts int
+	err := database.App.QueryRow(
+		`INSERT INTO user_lockouts (user_id, failed_attempts, updated_at)
+		 VALUES ($1, 1, $2)
+		 ON CONFLICT (user_id) DO UPDATE SET
+		     failed_attempts = user_lockouts.failed_attempts + 1,
+		     updated_at = $2
+		 RETURNING failed_attempts`,
+		userID, now,
+	).Scan(&failedAttempts)
+	if err != nil {
+		return fmt.Errorf("middleware: failed to record failed login: %w", err)
+	}
+
+	if failedAttempts < cfg.Threshold {
+		return nil
+	}
+
+	backoff := time.Duration(cfg.BaseBackoffSeconds) * time.Second
+	maxBackoff := time.Duration(cfg.MaxBackoffSeconds) * time.Second
+	for i := 0; i < failedAttempts-cfg.Threshold; i++ {
+		backoff *= 2
+		if backoff >= maxBackoff {
+			backoff = maxBackoff
+			break
+		}
+	}
+
+	if _, err := database.App.Exec(
+		"UPDATE user_lockouts SET locked_until = $1 WHERE user_id = $2",
+		now.Add(backoff), userID,
+	); err != nil {
+		return fmt.Errorf("middleware: failed to lock account: %w", err)
+	}
+
+	metrics.AccountLockouts.Inc()
+	return nil
+}
+
+// ClearFailedLogins resets userID's failed-login counter after a
+// successful authentication.
+func ClearFailedLogins(userID string) error {
+	_, err := database.App.Exec("DELETE FROM user_lockouts WHERE user_id = $1", userID)
+	return err
+}
@@ -1,23 +1,40 @@
 package middleware
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
-	"os"
 	"strings"
 	"time"
 
-	"github.com/dgrijalva/jwt-go"
 	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+
+	"github.com/holycan/smart-parking-system/auth/keys"
 )
 
-// Claims represents the JWT claims
+// accessTokenTTL is short since a stolen access token is only useful
+// until it expires - RefreshToken is what's expected to carry a session
+// beyond this, and that can be revoked immediately on reuse detection.
+const accessTokenTTL = 15 * time.Minute
+
+// Claims represents the JWT claims. Roles and Perms are resolved by
+// auth/rbac.Resolve at login time rather than carrying a single hardcoded
+// role string, so authorization checks compare against named permissions
+// instead of string-matching "admin" throughout the handlers.
 type Claims struct {
-	UserID string `json:"userId"`
-	Email  string `json:"email"`
-	Role   string `json:"role"`
-	jwt.StandardClaims
+	UserID string   `json:"userId"`
+	Email  string   `json:"email"`
+	Roles  []string `json:"roles"`
+	Perms  []string `json:"perms"`
+	// MFAVerified is true unless the account has MFA enabled and this
+	// token was issued before the second factor was completed for this
+	// session - see handlers.LoginUser and handlers.VerifyMFA. Checked by
+	// RequireMFA.
+	MFAVerified bool `json:"mfaVerified"`
+	jwt.RegisteredClaims
 }
 
 // AuthMiddleware validates JWT tokens and sets user info in the context
@@ -42,33 +59,21 @@ func AuthMiddleware() gin.HandlerFunc {
 		// Extract the token
 		tokenString := parts[1]
 
-		// Get the JWT secret from environment variables
-		jwtSecret := os.Getenv("JWT_SECRET")
-		if jwtSecret == "" {
-			jwtSecret = "ramaa212!" // Default fallback
-		}
+		ks := keys.Current
 
 		// Parse and validate the token
 		claims := &Claims{}
 		token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-			// Validate the signing method
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-			}
-			return []byte(jwtSecret), nil
+			return ks.Verify(token)
 		})
 
 		// Handle token validation errors
 		if err != nil {
 			log.Printf("Token validation error: %v", err)
-			if ve, ok := err.(*jwt.ValidationError); ok {
-				if ve.Errors&jwt.ValidationErrorExpired != 0 {
-					c.JSON(http.StatusUnauthorized, gin.H{"error": "Token has expired"})
-				} else {
-					c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
-				}
+			if errors.Is(err, jwt.ErrTokenExpired) {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "Token has expired"})
 			} else {
-				c.JSON(http.StatusUnauthorized, gin.H{"error": "Failed to parse token"})
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
 			}
 			c.Abort()
 			return
@@ -81,51 +86,68 @@ func AuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
-		// Check token expiration
-		if claims.ExpiresAt < time.Now().Unix() {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Token has expired"})
-			c.Abort()
-			return
+		if claims.ID != "" {
+			blacklisted, err := IsTokenBlacklisted(claims.ID)
+			if err != nil {
+				log.Printf("Error checking token blacklist: %v", err)
+			} else if blacklisted {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "Token has been revoked"})
+				c.Abort()
+				return
+			}
 		}
 
 		// Set user information in the context
 		c.Set("userId", claims.UserID)
 		c.Set("email", claims.Email)
-		c.Set("role", claims.Role)
+		c.Set("roles", claims.Roles)
+		c.Set("perms", claims.Perms)
+		c.Set("mfaVerified", claims.MFAVerified)
+		c.Set("tokenId", claims.ID)
+		c.Set("tokenExpiresAt", claims.ExpiresAt.Time)
 
 		// Continue to the next middleware/handler
 		c.Next()
 	}
 }
 
-// GenerateToken creates a new JWT token for a user
-func GenerateToken(userID, email, role string) (string, error) {
-	// Get the JWT secret from environment variables
-	jwtSecret := os.Getenv("JWT_SECRET")
-	if jwtSecret == "" {
-		jwtSecret = "ramaa212!" // Default fallback
-	}
+// GenerateToken creates a new short-lived access JWT for a user, carrying
+// its resolved roles and permissions (see auth/rbac.Resolve). Each token
+// also carries a unique jti (RegisteredClaims.ID) so it can be revoked
+// individually via BlacklistToken's Redis set before it would otherwise
+// expire. mfaVerified should be false only when the account has MFA
+// enabled and the caller hasn't completed it yet for this session - see
+// handlers.LoginUser.
+func GenerateToken(userID, email string, roles, perms []string, mfaVerified bool) (string, error) {
+	ks := keys.Current
 
-	// Set token expiration time (24 hours)
-	expirationTime := time.Now().Add(24 * time.Hour)
+	expirationTime := time.Now().Add(accessTokenTTL)
 
 	// Create the JWT claims
 	claims := &Claims{
-		UserID: userID,
-		Email:  email,
-		Role:   role,
-		StandardClaims: jwt.StandardClaims{
-			ExpiresAt: expirationTime.Unix(),
-			IssuedAt:  time.Now().Unix(),
+		UserID:      userID,
+		Email:       email,
+		Roles:       roles,
+		Perms:       perms,
+		MFAVerified: mfaVerified,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(expirationTime),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
 			Issuer:    "smart-parking-system",
+			ID:        uuid.New().String(),
 		},
 	}
 
-	// Create the token with the claims
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	// Create the token with the claims, tagging it with the key that
+	// signed it (kid) so a verifier holding multiple keys mid-rotation
+	// knows which one to check it against - see auth/keys.
+	token := jwt.NewWithClaims(ks.SigningMethod(), claims)
+	if ks.KeyID != "" {
+		token.Header["kid"] = ks.KeyID
+	}
 
-	// Sign the token with the secret key
-	tokenString, err := token.SignedString([]byte(jwtSecret))
+	// Sign the token with the resolved key
+	tokenString, err := token.SignedString(ks.SigningKey)
 	if err != nil {
 		return "", err
 	}
@@ -133,6 +155,83 @@ func GenerateToken(userID, email, role string) (string, error) {
 	return tokenString, nil
 }
 
+// HasPermission reports whether the authenticated request's token carries
+// perm, as set in the context by AuthMiddleware.
+func HasPermission(c *gin.Context, perm string) bool {
+	perms, exists := c.Get("perms")
+	if !exists {
+		return false
+	}
+	for _, p := range perms.([]string) {
+		if p == perm {
+			return true
+		}
+	}
+	return false
+}
+
+// RequirePermission returns a gin middleware that 403s any request whose
+// token doesn't carry perm. It must run after AuthMiddleware, which is
+// what populates "perms" in the context.
+func RequirePermission(perm string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !HasPermission(c, perm) {
+			c.JSON(http.StatusForbidden, gin.H{"error": fmt.Sprintf("missing required permission: %s", perm)})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// HasRole reports whether the authenticated request's token carries role,
+// as set in the context by AuthMiddleware.
+func HasRole(c *gin.Context, role string) bool {
+	roles, exists := c.Get("roles")
+	if !exists {
+		return false
+	}
+	for _, r := range roles.([]string) {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// RequireRoles returns a gin middleware that 403s any request whose token
+// doesn't carry at least one of roles. Prefer RequirePermission for
+// authorizing a specific action; RequireRoles is for the coarser
+// "this whole route group is for staff" gate. Must run after
+// AuthMiddleware.
+func RequireRoles(roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		for _, role := range roles {
+			if HasRole(c, role) {
+				c.Next()
+				return
+			}
+		}
+		c.JSON(http.StatusForbidden, gin.H{"error": fmt.Sprintf("requires one of roles: %s", strings.Join(roles, ", "))})
+		c.Abort()
+	}
+}
+
+// RequireMFA returns a gin middleware that 403s any request whose token
+// was issued before its account's second factor was verified (see
+// handlers.LoginUser). Must run after AuthMiddleware.
+func RequireMFA() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		verified, _ := c.Get("mfaVerified")
+		if v, ok := verified.(bool); !ok || !v {
+			c.JSON(http.StatusForbidden, gin.H{"error": "this action requires completing multi-factor authentication"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
 // CheckUserOwnership ensures the authenticated user is accessing their own data
 func CheckUserOwnership(c *gin.Context) {
 	// Get the target user ID from the URL parameter
@@ -6,9 +6,49 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	goredis "github.com/redis/go-redis/v9"
 	"golang.org/x/time/rate"
+
+	"github.com/holycan/smart-parking-system/config"
 )
 
+// Limiter reports whether a call identified by key should be allowed
+// right now, consuming one token if so. Implemented by *RateLimiter (the
+// in-process default, fine for a single-node dev setup) and
+// *RedisRateLimiter (shared across replicas) - see NewLimiter and
+// config.RateLimitConfig.Backend.
+type Limiter interface {
+	Allow(key string) bool
+}
+
+// NewLimiter builds the Limiter config.RateLimitConfig.Backend selects:
+// the in-process default, or a RedisRateLimiter shared across every
+// replica via redisClient when Backend is "redis". requestsPerDuration
+// and duration have the same meaning as NewRateLimiter's.
+func NewLimiter(cfg config.RateLimitConfig, redisClient *goredis.Client, requestsPerDuration int, duration time.Duration) Limiter {
+	if cfg.Backend == "redis" {
+		return NewRedisRateLimiter(redisClient, requestsPerDuration, duration)
+	}
+	return NewRateLimiter(requestsPerDuration, duration)
+}
+
+// RateLimitMiddleware returns a Gin middleware that 429s any request past
+// limiter's budget for the caller's IP. Works with either Limiter
+// implementation, unlike (*RateLimiter).Middleware which is tied to the
+// in-process backend specifically.
+func RateLimitMiddleware(limiter Limiter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !limiter.Allow(c.ClientIP()) {
+			c.JSON(http.StatusTooManyRequests, gin.H{
+				"error": "Rate limit exceeded. Please try again later.",
+			})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
 // RateLimiter implements a token bucket rate limiter for API requests
 type RateLimiter struct {
 	// Map of IP addresses to limiters
@@ -35,6 +75,12 @@ func NewRateLimiter(requestsPerDuration int, duration time.Duration) *RateLimite
 	}
 }
 
+// Allow reports whether key has a token available right now, consuming
+// one if so - see Limiter.
+func (rl *RateLimiter) Allow(key string) bool {
+	return rl.getLimiter(key).Allow()
+}
+
 // getLimiter returns the rate limiter for the provided IP address
 func (rl *RateLimiter) getLimiter(ip string) *rate.Limiter {
 	rl.mu.Lock()
@@ -78,23 +124,5 @@ func (rl *RateLimiter) Middleware() gin.HandlerFunc {
 		}
 	}()
 
-	return func(c *gin.Context) {
-		// Get client IP address
-		ip := c.ClientIP()
-
-		// Get the limiter for this IP
-		limiter := rl.getLimiter(ip)
-
-		// Check if the request is allowed
-		if !limiter.Allow() {
-			c.JSON(http.StatusTooManyRequests, gin.H{
-				"error": "Rate limit exceeded. Please try again later.",
-			})
-			c.Abort()
-			return
-		}
-
-		// Continue processing the request
-		c.Next()
-	}
+	return RateLimitMiddleware(rl)
 }
@@ -0,0 +1,84 @@
+package middleware
+
+import (
+	"context"
+	"log"
+	"time"
+
+	goredis "github.com/redis/go-redis/v9"
+)
+
+// tokenBucketScript is an atomic token-bucket check-and-decrement. A plain
+// INCRBYFLOAT can't do this alone: refilling still needs the elapsed time
+// since the bucket's last update, and the result needs capping at burst
+// and conditionally decrementing by one - all of which has to happen as
+// one read-modify-write or concurrent callers (across replicas, all
+// talking to the same key) would race each other's refill. The script
+// still uses INCRBYFLOAT-equivalent float accumulation for the refill
+// itself, just computed in Lua so the whole check is one round trip.
+var tokenBucketScript = goredis.NewScript(`
+local key = KEYS[1]
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+
+local data = redis.call("HMGET", key, "tokens", "updated")
+local tokens = tonumber(data[1])
+local updated = tonumber(data[2])
+if tokens == nil then
+    tokens = burst
+    updated = now
+end
+
+local elapsed = math.max(0, now - updated)
+tokens = math.min(burst, tokens + elapsed * rate)
+
+local allowed = 0
+if tokens >= 1 then
+    tokens = tokens - 1
+    allowed = 1
+end
+
+redis.call("HSET", key, "tokens", tokens, "updated", now)
+redis.call("EXPIRE", key, math.ceil(burst / rate) + 1)
+
+return allowed
+`)
+
+// RedisRateLimiter is a token-bucket Limiter shared across every replica
+// via a Redis client, so a client hitting N pods is bound to one combined
+// rate instead of N times the configured one, unlike the in-process
+// RateLimiter. See config.RateLimitConfig.Backend and NewLimiter.
+type RedisRateLimiter struct {
+	client *goredis.Client
+	rate   float64
+	burst  int
+}
+
+// NewRedisRateLimiter creates a RedisRateLimiter allowing up to
+// requestsPerDuration calls per duration per key, refilled continuously
+// rather than reset in a fixed window - same parameterization as
+// NewRateLimiter, so either can be dropped in behind NewLimiter.
+func NewRedisRateLimiter(client *goredis.Client, requestsPerDuration int, duration time.Duration) *RedisRateLimiter {
+	return &RedisRateLimiter{
+		client: client,
+		rate:   float64(requestsPerDuration) / duration.Seconds(),
+		burst:  requestsPerDuration,
+	}
+}
+
+// Allow reports whether key has a token available right now, consuming
+// one if so. Fails open (returns true) on a Redis error, the same choice
+// AuthRateLimit makes, so a Redis outage degrades to no rate limiting
+// rather than rejecting every request.
+func (rl *RedisRateLimiter) Allow(key string) bool {
+	now := float64(time.Now().UnixNano()) / 1e9
+	res, err := tokenBucketScript.Run(context.Background(), rl.client, []string{"ratelimit:" + key}, rl.rate, rl.burst, now).Result()
+	if err != nil {
+		log.Printf("redis rate limiter: redis error for %s, failing open: %v", key, err)
+		return true
+	}
+
+	allowed, _ := res.(int64)
+	return allowed == 1
+}
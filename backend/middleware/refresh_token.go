@@ -0,0 +1,120 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/holycan/smart-parking-system/database"
+)
+
+// refreshTokenTTL is how long a refresh token (and the session it
+// represents) stays valid without being used.
+const refreshTokenTTL = 30 * 24 * time.Hour
+
+// refreshTokenSecretBytes is the amount of randomness in a refresh token,
+// before base64url encoding.
+const refreshTokenSecretBytes = 32
+
+// RefreshToken is one row of the refresh_tokens table.
+type RefreshToken struct {
+	ID        string
+	UserID    string
+	FamilyID  string
+	ParentID  sql.NullString
+	ExpiresAt time.Time
+	RevokedAt sql.NullTime
+}
+
+// generateRefreshTokenSecret returns a random base64url-encoded opaque
+// token. Only its SHA-256 hash is ever stored, so a database read alone
+// can't be replayed as a valid refresh token.
+func generateRefreshTokenSecret() (string, error) {
+	b := make([]byte, refreshTokenSecretBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("middleware: failed to generate refresh token: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func hashRefreshToken(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// IssueRefreshToken creates a brand-new refresh token family for userID,
+// as happens on login. It returns the plaintext token to hand to the
+// client; only its hash is persisted.
+func IssueRefreshToken(userID, userAgent, ip string) (plaintext string, record RefreshToken, err error) {
+	return issueRefreshToken(userID, uuid.New().String(), sql.NullString{}, userAgent, ip)
+}
+
+func issueRefreshToken(userID, familyID string, parentID sql.NullString, userAgent, ip string) (string, RefreshToken, error) {
+	secret, err := generateRefreshTokenSecret()
+	if err != nil {
+		return "", RefreshToken{}, err
+	}
+
+	record := RefreshToken{
+		ID:        uuid.New().String(),
+		UserID:    userID,
+		FamilyID:  familyID,
+		ParentID:  parentID,
+		ExpiresAt: time.Now().Add(refreshTokenTTL),
+	}
+
+	_, err = database.App.Exec(
+		"INSERT INTO refresh_tokens (id, user_id, token_hash, family_id, parent_id, user_agent, ip, expires_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)",
+		record.ID, record.UserID, hashRefreshToken(secret), record.FamilyID, record.ParentID, userAgent, ip, record.ExpiresAt,
+	)
+	if err != nil {
+		return "", RefreshToken{}, fmt.Errorf("middleware: failed to store refresh token: %w", err)
+	}
+
+	return secret, record, nil
+}
+
+// LookupRefreshToken finds the stored record for a presented plaintext
+// refresh token. It returns sql.ErrNoRows if the token is unrecognized.
+func LookupRefreshToken(plaintext string) (RefreshToken, error) {
+	var r RefreshToken
+	err := database.App.QueryRow(
+		"SELECT id, user_id, family_id, parent_id, expires_at, revoked_at FROM refresh_tokens WHERE token_hash = $1",
+		hashRefreshToken(plaintext),
+	).Scan(&r.ID, &r.UserID, &r.FamilyID, &r.ParentID, &r.ExpiresAt, &r.RevokedAt)
+	return r, err
+}
+
+// RevokeRefreshToken revokes a single refresh token by id.
+func RevokeRefreshToken(id string) error {
+	_, err := database.App.Exec(
+		"UPDATE refresh_tokens SET revoked_at = $1 WHERE id = $2 AND revoked_at IS NULL",
+		time.Now(), id,
+	)
+	return err
+}
+
+// RevokeRefreshTokenFamily revokes every token descended from the same
+// login, used both for "log out everywhere" and for reuse detection.
+func RevokeRefreshTokenFamily(familyID string) error {
+	_, err := database.App.Exec(
+		"UPDATE refresh_tokens SET revoked_at = $1 WHERE family_id = $2 AND revoked_at IS NULL",
+		time.Now(), familyID,
+	)
+	return err
+}
+
+// RotateRefreshToken revokes old and issues a new refresh token in the
+// same family, chained via parent_id.
+func RotateRefreshToken(old RefreshToken, userAgent, ip string) (plaintext string, record RefreshToken, err error) {
+	if err := RevokeRefreshToken(old.ID); err != nil {
+		return "", RefreshToken{}, fmt.Errorf("middleware: failed to revoke rotated refresh token: %w", err)
+	}
+	return issueRefreshToken(old.UserID, old.FamilyID, sql.NullString{String: old.ID, Valid: true}, userAgent, ip)
+}
@@ -0,0 +1,31 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+)
+
+// cspPolicy is a Content-Security-Policy appropriate for the embedded
+// React bundle setupRouter serves: scripts/styles only from self (no
+// inline script execution), images from self and data: URIs (the /qrcodes
+// static route serves generated images), and API/WebSocket connections
+// back to self only.
+const cspPolicy = "default-src 'self'; script-src 'self'; style-src 'self' 'unsafe-inline'; img-src 'self' data:; connect-src 'self'; frame-ancestors 'none'"
+
+// SecurityHeaders sets the response headers a security scanner expects and
+// gin doesn't add on its own: HSTS (only when tlsEnabled, since advertising
+// it over plain HTTP is meaningless and can lock out a misconfigured
+// deployment), frame-deny, nosniff, the legacy XSS filter, and a CSP for
+// the served frontend. Register before CORS so these land on every
+// response, including ones CORS itself rejects.
+func SecurityHeaders(tlsEnabled bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if tlsEnabled {
+			c.Header("Strict-Transport-Security", "max-age=31536000; includeSubDomains")
+		}
+		c.Header("X-Frame-Options", "DENY")
+		c.Header("X-Content-Type-Options", "nosniff")
+		c.Header("X-XSS-Protection", "1; mode=block")
+		c.Header("Content-Security-Policy", cspPolicy)
+		c.Next()
+	}
+}
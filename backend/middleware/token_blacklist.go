@@ -0,0 +1,33 @@
+package middleware
+
+import (
+	"context"
+	"time"
+
+	"github.com/holycan/smart-parking-system/lock"
+)
+
+// blacklistKeyPrefix namespaces revoked jtis in Redis from the other keys
+// lock.RedisClient serves (idempotency records, rate-limit counters, ...).
+const blacklistKeyPrefix = "token_blacklist:"
+
+// BlacklistToken revokes a single access token immediately by jti, ahead
+// of its natural expiry. The Redis key expires alongside expiresAt (the
+// token's own ExpiresAt) so a revoked jti doesn't linger in Redis past the
+// point it would have stopped mattering anyway.
+func BlacklistToken(jti string, expiresAt time.Time) error {
+	ttl := time.Until(expiresAt)
+	if ttl <= 0 {
+		return nil
+	}
+	return lock.RedisClient.Set(context.Background(), blacklistKeyPrefix+jti, "1", ttl).Err()
+}
+
+// IsTokenBlacklisted reports whether jti has been revoked.
+func IsTokenBlacklisted(jti string) (bool, error) {
+	n, err := lock.RedisClient.Exists(context.Background(), blacklistKeyPrefix+jti).Result()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
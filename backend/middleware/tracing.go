@@ -0,0 +1,39 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+
+	"github.com/holycan/smart-parking-system/internal/tracing"
+)
+
+// Tracing starts a span named "<method> <route>" around every request,
+// using tracing.Current (a no-op tracer when tracing is disabled, so this
+// middleware is always safe to register). Handlers that want to attach
+// child spans - handlers.ProcessCheckIn does, for lock acquisition and the
+// DB/WS work that follows - pull the current span's context off
+// c.Request.Context().
+func Tracing() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, span := tracing.Current.Start(c.Request.Context(), c.FullPath())
+		defer span.End()
+
+		span.SetAttributes(
+			attribute.String("http.method", c.Request.Method),
+			attribute.String("http.route", c.FullPath()),
+		)
+		c.Request = c.Request.WithContext(ctx)
+
+		c.Next()
+
+		status := c.Writer.Status()
+		span.SetAttributes(attribute.Int("http.status_code", status))
+		if status >= 500 {
+			span.SetStatus(codes.Error, "")
+		}
+		if len(c.Errors) > 0 {
+			span.SetStatus(codes.Error, c.Errors.String())
+		}
+	}
+}
@@ -3,11 +3,12 @@ package middleware
 import (
 	"log"
 	"net/http"
-	"os"
 	"strings"
 
-	"github.com/dgrijalva/jwt-go"
 	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/holycan/smart-parking-system/auth/keys"
 )
 
 // AuthWebSocketMiddleware authenticates WebSocket connections using JWT tokens
@@ -30,19 +31,9 @@ func AuthWebSocketMiddleware() gin.HandlerFunc {
 		}
 
 		// Parse and validate the token
-		jwtSecret := os.Getenv("JWT_SECRET")
-		if jwtSecret == "" {
-			log.Println("JWT_SECRET environment variable not set")
-			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Server configuration error"})
-			return
-		}
-
+		ks := keys.Current
 		parsedToken, err := jwt.Parse(token, func(token *jwt.Token) (interface{}, error) {
-			// Validate the signing method
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, jwt.ErrSignatureInvalid
-			}
-			return []byte(jwtSecret), nil
+			return ks.Verify(token)
 		})
 
 		if err != nil || !parsedToken.Valid {
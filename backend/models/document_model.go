@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// Document types accepted by handlers.UploadReservationDocument. Any other
+// value is rejected.
+const (
+	DocumentTypePermit     = "permit"
+	DocumentTypeDisability = "disability_card"
+	DocumentTypeEVAuth     = "ev_charging_authorization"
+)
+
+// ReservationDocument is an uploaded attachment supporting a reservation
+// (a disability permit, an EV charging authorization, etc.), stored via
+// filestorage.Current and keyed by FileKey.
+type ReservationDocument struct {
+	ID               string    `json:"id"`
+	ReservationID    string    `json:"reservationId"`
+	UserID           string    `json:"userId"`
+	Type             string    `json:"type"`
+	FileKey          string    `json:"-"`
+	OriginalFilename string    `json:"originalFilename"`
+	ContentType      string    `json:"contentType"`
+	SizeBytes        int64     `json:"sizeBytes"`
+	ScanStatus       string    `json:"scanStatus"`
+	CreatedAt        time.Time `json:"createdAt"`
+}
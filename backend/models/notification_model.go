@@ -1,6 +1,10 @@
 package models
 
-import "time"
+import (
+	"time"
+
+	"github.com/holycan/smart-parking-system/routing"
+)
 
 // Notification represents a notification in the system
 type Notification struct {
@@ -21,4 +25,10 @@ type NotificationEvent struct {
 	Type           string    `json:"type"`
 	Message        string    `json:"message"`
 	CreatedAt      time.Time `json:"createdAt"`
+
+	// Route carries turn-by-turn directions to ParkingSpaceId. Only set on
+	// "spot_assigned" events, and only when the check-in request supplied
+	// an origin and routing.Client resolved successfully — see
+	// handlers.ProcessCheckIn.
+	Route *routing.Route `json:"route,omitempty"`
 }
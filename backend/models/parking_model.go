@@ -1,6 +1,10 @@
 package models
 
-import "time"
+import (
+	"time"
+
+	"github.com/paulmach/orb/geojson"
+)
 
 // ParkingLot represents a parking lot
 type ParkingLot struct {
@@ -19,6 +23,10 @@ type ParkingLot struct {
 	IsOpen24H   bool      `json:"isOpen24h"`
 	CreatedAt   time.Time `json:"createdAt"`
 	UpdatedAt   time.Time `json:"updatedAt"`
+
+	// Location is a GeoJSON Feature wrapping the lot's point geometry. It is
+	// only populated by geospatial queries such as FindNearestParkingLots.
+	Location *geojson.Feature `json:"location,omitempty"`
 }
 
 // ParkingSpace represents a parking space
@@ -32,6 +40,27 @@ type ParkingSpace struct {
 	LastUpdated  time.Time `json:"lastUpdated"`
 	CreatedAt    time.Time `json:"createdAt"`
 	UpdatedAt    time.Time `json:"updatedAt"`
+
+	// Latitude and Longitude are the space's own coordinates, used by
+	// routing.Client to route a driver to this exact spot rather than just
+	// the parking lot's entrance. Unset (0, 0) for spaces that haven't been
+	// surveyed yet — see migration 0010.
+	Latitude  float64 `json:"latitude,omitempty"`
+	Longitude float64 `json:"longitude,omitempty"`
+
+	// IsBlocked and BlockedReason let an admin (see handlers.BlockParkingSpace)
+	// take a space out of rotation for maintenance without it looking like
+	// it's simply occupied by a vehicle.
+	IsBlocked     bool   `json:"isBlocked"`
+	BlockedReason string `json:"blockedReason,omitempty"`
+}
+
+// LotWithDistance represents a parking lot annotated with its distance
+// from a search point and its currently available spot count
+type LotWithDistance struct {
+	ParkingLot     ParkingLot `json:"parkingLot"`
+	DistanceMeters float64    `json:"distanceMeters"`
+	AvailableSpots int        `json:"availableSpots"`
 }
 
 // ParkingUpdate represents a change in parking space status
@@ -11,6 +11,7 @@ type Reservation struct {
 	UserID          string    `json:"userId"`
 	ParkingLotID    string    `json:"parkingLotId"`
 	ParkingSpaceID  string    `json:"parkingSpaceId"`
+	VehicleID       string    `json:"vehicleId,omitempty"`
 	VehicleType     string    `json:"vehicleTyoe"`
 	LicensePlate    string    `json:"licensePlate"`
 	ReservationDate time.Time `json:"reservationDate"`
@@ -28,8 +29,9 @@ type Reservation struct {
 type ReservationRequest struct {
 	ParkingLotID    string         `json:"parkingLotId" binding:"required"`
 	ParkingSpaceID  string         `json:"parkingSpaceId" binding:"required"`
-	VehicleType     string         `json:"vehicleType" binding:"required"`
-	LicensePlate    string         `json:"licensePlate" binding:"required"`
+	VehicleID       string         `json:"vehicleId"`
+	VehicleType     string         `json:"vehicleType"`
+	LicensePlate    string         `json:"licensePlate"`
 	ReservationDate string         `json:"reservationDate" binding:"required"`
 	CheckinTime     sql.NullString `json:"checkInTime"`
 	ExpiredAt       time.Time      `json:"expiredAt"`
@@ -58,6 +60,17 @@ type ReservationInfo struct {
 	Timestamp     int64  `json:"timestamp"`
 }
 
+// ReservationHistoryEvent represents one row of a reservation's audit trail
+type ReservationHistoryEvent struct {
+	ID             string                 `json:"id"`
+	ReservationID  string                 `json:"reservationId"`
+	ActorUserID    string                 `json:"actorUserId"`
+	PreviousStatus string                 `json:"previousStatus"`
+	NewStatus      string                 `json:"newStatus"`
+	Metadata       map[string]interface{} `json:"metadata,omitempty"`
+	OccurredAt     time.Time              `json:"occurredAt"`
+}
+
 type ReservationEvent struct {
 	ReservationID  string `json:"reservationId"`
 	PaymentID      string `json:"paymentId"`
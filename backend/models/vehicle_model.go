@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// Vehicle represents a user's pre-registered vehicle in their "garage"
+type Vehicle struct {
+	ID           string    `json:"id"`
+	UserID       string    `json:"userId"`
+	LicensePlate string    `json:"licensePlate"`
+	Make         string    `json:"make"`
+	Model        string    `json:"model"`
+	Type         string    `json:"type"`
+	Color        string    `json:"color"`
+	IsDefault    bool      `json:"isDefault"`
+	CreatedAt    time.Time `json:"createdAt"`
+	UpdatedAt    time.Time `json:"updatedAt"`
+}
+
+// VehicleRequest represents the request body for creating or updating a vehicle
+type VehicleRequest struct {
+	LicensePlate string `json:"licensePlate" binding:"required"`
+	Make         string `json:"make" binding:"required"`
+	Model        string `json:"model" binding:"required"`
+	Type         string `json:"type" binding:"required"`
+	Color        string `json:"color"`
+	IsDefault    bool   `json:"isDefault"`
+}
@@ -0,0 +1,90 @@
+// Package pagination implements opaque keyset ("cursor") pagination
+// alongside this repo's existing LIMIT/OFFSET pagination, so the list
+// handlers in handlers/parking_handler.go can page through large,
+// frequently-changing tables with a stable tuple-comparison query instead
+// of a LIMIT/OFFSET that drifts when rows are inserted or deleted between
+// page fetches. Callers keep returning the existing "pagination" block
+// for clients still sending page/limit, and additionally thread a
+// "cursor" query param through nextCursor/prevCursor.
+package pagination
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+)
+
+// Cursor is the decoded sort key carried by an opaque cursor string - the
+// values of whatever columns an endpoint orders by, keyed by column name.
+type Cursor map[string]interface{}
+
+// Encode base64-encodes cursor as the opaque string returned as
+// nextCursor/prevCursor.
+func Encode(cursor Cursor) (string, error) {
+	raw, err := json.Marshal(cursor)
+	if err != nil {
+		return "", fmt.Errorf("pagination: failed to encode cursor: %w", err)
+	}
+	return base64.URLEncoding.EncodeToString(raw), nil
+}
+
+// Decode reverses Encode and validates that the decoded cursor carries
+// exactly the columns in sortKeys, so a cursor minted for one "sort"
+// query param can't be replayed against a request for a different one.
+func Decode(encoded string, sortKeys ...string) (Cursor, error) {
+	raw, err := base64.URLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("pagination: invalid cursor encoding")
+	}
+
+	var cursor Cursor
+	if err := json.Unmarshal(raw, &cursor); err != nil {
+		return nil, fmt.Errorf("pagination: invalid cursor payload")
+	}
+
+	if len(cursor) != len(sortKeys) {
+		return nil, fmt.Errorf("pagination: cursor does not match requested sort")
+	}
+	for _, key := range sortKeys {
+		if _, ok := cursor[key]; !ok {
+			return nil, fmt.Errorf("pagination: cursor does not match requested sort")
+		}
+	}
+	return cursor, nil
+}
+
+// Predicate builds the tuple-comparison clause a keyset query needs -
+// "(col1, col2) > ($n, $n+1)", or "< " when reverse is true (a "prev"
+// page, walking backwards from the cursor) - for sqlColumns in order.
+// Each column's value is pulled out of cursor by the matching entry in
+// cursorKeys (same order, but separate since sqlColumns may be qualified,
+// e.g. "pl.name", while the cursor's JSON uses the bare "name"). args is
+// the query's argument slice built up so far; Predicate appends cursor's
+// values to it and returns the next free placeholder index alongside the
+// extended slice.
+func Predicate(sqlColumns, cursorKeys []string, cursor Cursor, reverse bool, args []interface{}, paramIndex int) (clause string, newArgs []interface{}, nextIndex int) {
+	placeholders := make([]string, len(sqlColumns))
+	for i, key := range cursorKeys {
+		placeholders[i] = fmt.Sprintf("$%d", paramIndex)
+		args = append(args, cursor[key])
+		paramIndex++
+	}
+
+	op := ">"
+	if reverse {
+		op = "<"
+	}
+	clause = "(" + joinColumns(sqlColumns) + ") " + op + " (" + joinColumns(placeholders) + ")"
+	return clause, args, paramIndex
+}
+
+func joinColumns(columns []string) string {
+	out := ""
+	for i, col := range columns {
+		if i > 0 {
+			out += ", "
+		}
+		out += col
+	}
+	return out
+}
@@ -0,0 +1,7 @@
+// Package parkingv1 holds the generated stubs for parking.proto. The
+// generated *.pb.go / *_grpc.pb.go files aren't checked in (see
+// .gitignore) — run go generate to produce them locally before building
+// grpc.Server or cmd that imports this package.
+package parkingv1
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative parking.proto
@@ -0,0 +1,144 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	goredis "github.com/redis/go-redis/v9"
+
+	"github.com/holycan/smart-parking-system/lock"
+)
+
+// claimIdleFor is how long a message can sit unacknowledged in another
+// consumer's PEL before Consume is allowed to steal it with XAUTOCLAIM —
+// recovery for a worker that died mid-job.
+const claimIdleFor = 30 * time.Second
+
+// Handler processes one check-in job. Returning an error leaves the
+// message unacknowledged so a future XAUTOCLAIM retries it.
+type Handler func(job CheckInJob) error
+
+// Consume runs stream's consumer group loop under consumerName until ctx
+// is cancelled: it first reclaims messages abandoned by a dead consumer,
+// then blocks on new ones, invoking handle for each and acking on
+// success. A message handle fails past maxDeliveries is moved to
+// DeadLetterStream instead of retried forever.
+func Consume(ctx context.Context, stream, consumerName string, handle Handler) {
+	if err := ensureGroup(ctx, stream); err != nil {
+		log.Printf("queue: %v", err)
+		return
+	}
+
+	for ctx.Err() == nil {
+		reclaimStuck(ctx, stream, consumerName, handle)
+
+		res, err := lock.RedisClient.XReadGroup(ctx, &goredis.XReadGroupArgs{
+			Group:    ConsumerGroup,
+			Consumer: consumerName,
+			Streams:  []string{stream, ">"},
+			Count:    10,
+			Block:    5 * time.Second,
+		}).Result()
+		if err != nil {
+			if err != goredis.Nil && ctx.Err() == nil {
+				log.Printf("queue: XREADGROUP on %s failed: %v", stream, err)
+				time.Sleep(time.Second)
+			}
+			continue
+		}
+
+		for _, s := range res {
+			for _, msg := range s.Messages {
+				processMessage(ctx, stream, msg, handle)
+			}
+		}
+	}
+}
+
+// reclaimStuck walks the stream's PEL via XAUTOCLAIM, picking up any
+// message idle longer than claimIdleFor (abandoned by a crashed or
+// deregistered consumer) and processing it under consumerName.
+func reclaimStuck(ctx context.Context, stream, consumerName string, handle Handler) {
+	cursor := "0-0"
+	for {
+		msgs, next, err := lock.RedisClient.XAutoClaim(ctx, &goredis.XAutoClaimArgs{
+			Stream:   stream,
+			Group:    ConsumerGroup,
+			Consumer: consumerName,
+			MinIdle:  claimIdleFor,
+			Start:    cursor,
+			Count:    10,
+		}).Result()
+		if err != nil {
+			if err != goredis.Nil {
+				log.Printf("queue: XAUTOCLAIM on %s failed: %v", stream, err)
+			}
+			return
+		}
+
+		for _, msg := range msgs {
+			processMessage(ctx, stream, msg, handle)
+		}
+		if next == "0-0" || len(msgs) == 0 {
+			return
+		}
+		cursor = next
+	}
+}
+
+func processMessage(ctx context.Context, stream string, msg goredis.XMessage, handle Handler) {
+	var job CheckInJob
+	raw, _ := msg.Values["payload"].(string)
+	if err := json.Unmarshal([]byte(raw), &job); err != nil {
+		log.Printf("queue: dropping unparseable message %s on %s: %v", msg.ID, stream, err)
+		lock.RedisClient.XAck(ctx, stream, ConsumerGroup, msg.ID)
+		return
+	}
+
+	if exceededRetryBudget(ctx, stream, msg.ID) {
+		deadLetter(ctx, stream, job, msg.ID)
+		lock.RedisClient.XAck(ctx, stream, ConsumerGroup, msg.ID)
+		reportQueueDepth(ctx, stream)
+		return
+	}
+
+	if err := handle(job); err != nil {
+		log.Printf("queue: handler failed for reservation %s on %s: %v", job.ReservationID, stream, err)
+		return // leave unacked; a future XAUTOCLAIM will retry it
+	}
+
+	lock.RedisClient.XAck(ctx, stream, ConsumerGroup, msg.ID)
+	reportQueueDepth(ctx, stream)
+}
+
+// exceededRetryBudget reports whether msgID has already been delivered
+// more than maxDeliveries times, per the consumer group's PEL.
+func exceededRetryBudget(ctx context.Context, stream, msgID string) bool {
+	pending, err := lock.RedisClient.XPendingExt(ctx, &goredis.XPendingExtArgs{
+		Stream: stream,
+		Group:  ConsumerGroup,
+		Start:  msgID,
+		End:    msgID,
+		Count:  1,
+	}).Result()
+	if err != nil || len(pending) != 1 {
+		return false
+	}
+	return int(pending[0].RetryCount) > maxDeliveries
+}
+
+func deadLetter(ctx context.Context, stream string, job CheckInJob, originalID string) {
+	payload, _ := json.Marshal(job)
+	if err := lock.RedisClient.XAdd(ctx, &goredis.XAddArgs{
+		Stream: DeadLetterStream,
+		Values: map[string]interface{}{
+			"payload":         payload,
+			"original_stream": stream,
+			"original_id":     originalID,
+		},
+	}).Err(); err != nil {
+		log.Printf("queue: failed to dead-letter message %s from %s: %v", originalID, stream, err)
+	}
+}
@@ -0,0 +1,121 @@
+// Package queue replaces utils.ParkingQueue's in-memory channel with a
+// durable, at-least-once check-in queue backed by Redis Streams, so a
+// queued check-in survives a process restart and can be picked up by any
+// instance running a worker loop, not only the one that received the
+// HTTP request.
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	goredis "github.com/redis/go-redis/v9"
+
+	"github.com/holycan/smart-parking-system/internal/metrics"
+	"github.com/holycan/smart-parking-system/lock"
+)
+
+const (
+	// numPartitions splits check-in jobs across this many streams, keyed
+	// by parking lot, so a burst of activity at one lot can't starve the
+	// consumer group's PEL for every other lot.
+	numPartitions = 8
+
+	streamPrefix     = "parking:checkin:"
+	DeadLetterStream = "parking:checkin:dlq"
+	ConsumerGroup    = "checkin-workers"
+
+	// maxDeliveries bounds how many times Consume will retry a message
+	// (via XAUTOCLAIM) before routing it to DeadLetterStream instead of
+	// reclaiming it forever.
+	maxDeliveries = 5
+)
+
+// CheckInJob is the durable replacement for the map[string]interface{}
+// utils.ParkingQueue used to carry.
+type CheckInJob struct {
+	UserID        string `json:"user_id"`
+	ReservationID string `json:"reservation_id"`
+	ParkingLotID  string `json:"parking_lot_id"`
+
+	// OriginLat/OriginLng are the driver's current location, optionally
+	// supplied at check-in time so handlers.ProcessCheckIn can resolve a
+	// routing.Route to whichever space ends up assigned. Nil when omitted.
+	OriginLat *float64 `json:"origin_lat,omitempty"`
+	OriginLng *float64 `json:"origin_lng,omitempty"`
+}
+
+// Partitions returns every partitioned stream name, for a worker to run
+// one Consume loop per partition.
+func Partitions() []string {
+	streams := make([]string, numPartitions)
+	for i := range streams {
+		streams[i] = partitionStream(i)
+	}
+	return streams
+}
+
+func partitionStream(n int) string {
+	return fmt.Sprintf("%s%d", streamPrefix, n)
+}
+
+// streamForLot deterministically maps a parking lot to one of the
+// partitioned streams, so every job for that lot lands in the same
+// consumer group PEL and a single stuck job can't block other lots.
+func streamForLot(parkingLotID string) string {
+	var h uint32
+	for i := 0; i < len(parkingLotID); i++ {
+		h = h*31 + uint32(parkingLotID[i])
+	}
+	return partitionStream(int(h % numPartitions))
+}
+
+// Enqueue publishes job onto its parking lot's partitioned stream,
+// creating the stream's consumer group on first use.
+func Enqueue(ctx context.Context, job CheckInJob) error {
+	stream := streamForLot(job.ParkingLotID)
+	if err := ensureGroup(ctx, stream); err != nil {
+		return err
+	}
+
+	payload, err := json.Marshal(job)
+	if err != nil {
+		return fmt.Errorf("queue: failed to marshal check-in job: %w", err)
+	}
+
+	if err := lock.RedisClient.XAdd(ctx, &goredis.XAddArgs{
+		Stream: stream,
+		Values: map[string]interface{}{"payload": payload},
+	}).Err(); err != nil {
+		return fmt.Errorf("queue: failed to enqueue check-in for reservation %s: %w", job.ReservationID, err)
+	}
+
+	reportQueueDepth(ctx, stream)
+	return nil
+}
+
+// reportQueueDepth refreshes the smart_parking_checkin_queue_depth gauge
+// for stream from its current XLEN. Failures are logged-and-ignored by
+// the caller's caller (this is metrics, not the operation itself).
+func reportQueueDepth(ctx context.Context, stream string) {
+	length, err := lock.RedisClient.XLen(ctx, stream).Result()
+	if err != nil {
+		return
+	}
+	metrics.QueueDepth.WithLabelValues(stream).Set(float64(length))
+}
+
+// ensureGroup creates stream's consumer group starting from the
+// beginning of the stream, tolerating the group already existing.
+func ensureGroup(ctx context.Context, stream string) error {
+	err := lock.RedisClient.XGroupCreateMkStream(ctx, stream, ConsumerGroup, "0").Err()
+	if err != nil && !isBusyGroupErr(err) {
+		return fmt.Errorf("queue: failed to create consumer group on %s: %w", stream, err)
+	}
+	return nil
+}
+
+func isBusyGroupErr(err error) bool {
+	return err != nil && err.Error() == "BUSYGROUP Consumer Group name already exists"
+}
@@ -0,0 +1,145 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/geojson"
+
+	"github.com/holycan/smart-parking-system/database"
+	"github.com/holycan/smart-parking-system/models"
+)
+
+// postgresParkingLotRepository answers FindNearby via PostGIS's
+// ST_DWithin/ST_Distance against the location geography column, which
+// trg_parking_lots_sync_location (see
+// database/migrations/0003_parking_lot_geo_trigger.sql) keeps in sync with
+// latitude/longitude on every insert/update.
+type postgresParkingLotRepository struct{}
+
+func newPostgresParkingLotRepository() *postgresParkingLotRepository {
+	return &postgresParkingLotRepository{}
+}
+
+func (r *postgresParkingLotRepository) FindNearby(ctx context.Context, exec database.Executor, lat, lon float64, radiusMeters int, filters NearbyFilters) ([]models.LotWithDistance, error) {
+	query := `
+		SELECT
+			pl.id, pl.name, pl.address, pl.city, pl.state, pl.zip_code,
+			pl.latitude, pl.longitude, pl.total_spaces, pl.hourly_rate,
+			pl.open_time, pl.close_time, pl.is_open_24h, pl.created_at, pl.updated_at,
+			ST_Distance(pl.location, ST_MakePoint($2, $1)::geography) AS distance_meters,
+			(
+				SELECT COUNT(*) FROM parking_spaces ps
+				WHERE ps.parking_lot_id = pl.id
+				  AND NOT EXISTS (
+					SELECT 1 FROM reservations r
+					WHERE r.parking_space_id = ps.id
+					  AND r.status IN ('active', 'checked-in')
+				  )
+			) AS available_spots
+		FROM parking_lots pl
+		WHERE ST_DWithin(pl.location, ST_MakePoint($2, $1)::geography, $3)
+	`
+
+	args := []interface{}{lat, lon, radiusMeters}
+	paramIndex := 4
+
+	if filters.IsOpen24H != nil {
+		query += fmt.Sprintf(" AND pl.is_open_24h = $%d", paramIndex)
+		args = append(args, *filters.IsOpen24H)
+		paramIndex++
+	}
+
+	if filters.MaxHourlyRate > 0 {
+		query += fmt.Sprintf(" AND pl.hourly_rate <= $%d", paramIndex)
+		args = append(args, filters.MaxHourlyRate)
+		paramIndex++
+	}
+
+	if filters.OnlyAvailable {
+		query += `
+			AND EXISTS (
+				SELECT 1 FROM parking_spaces ps
+				WHERE ps.parking_lot_id = pl.id
+				  AND NOT EXISTS (
+					SELECT 1 FROM reservations r
+					WHERE r.parking_space_id = ps.id
+					  AND r.status IN ('active', 'checked-in')
+				  )
+			)
+		`
+	}
+
+	if filters.VehicleType != "" {
+		availabilityClause := ""
+		if filters.OnlyAvailable {
+			availabilityClause = `
+				AND NOT EXISTS (
+					SELECT 1 FROM reservations r
+					WHERE r.parking_space_id = ps.id
+					  AND r.status IN ('active', 'checked-in')
+				)
+			`
+		}
+		query += fmt.Sprintf(`
+			AND EXISTS (
+				SELECT 1 FROM parking_spaces ps
+				WHERE ps.parking_lot_id = pl.id AND ps.space_type = $%d
+				%s
+			)
+		`, paramIndex, availabilityClause)
+		args = append(args, filters.VehicleType)
+		paramIndex++
+	}
+
+	query += " ORDER BY ST_Distance(pl.location, ST_MakePoint($2, $1)::geography) ASC"
+
+	rows, err := exec.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query nearby parking lots: %w", err)
+	}
+	defer rows.Close()
+
+	var results []models.LotWithDistance
+	for rows.Next() {
+		var lot models.ParkingLot
+		var distanceMeters float64
+		var availableSpots int
+
+		if err := rows.Scan(
+			&lot.ID, &lot.Name, &lot.Address, &lot.City, &lot.State, &lot.ZipCode,
+			&lot.Latitude, &lot.Longitude, &lot.TotalSpaces, &lot.HourlyRate,
+			&lot.OpenTime, &lot.CloseTime, &lot.IsOpen24H, &lot.CreatedAt, &lot.UpdatedAt,
+			&distanceMeters, &availableSpots,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan parking lot row: %w", err)
+		}
+
+		if filters.OpenNowAt != nil && !isOpenAt(lot.IsOpen24H, lot.OpenTime, lot.CloseTime, *filters.OpenNowAt) {
+			continue
+		}
+
+		point := orb.Point{lot.Longitude, lot.Latitude}
+		lot.Location = geojson.NewFeature(point)
+
+		results = append(results, models.LotWithDistance{
+			ParkingLot:     lot,
+			DistanceMeters: distanceMeters,
+			AvailableSpots: availableSpots,
+		})
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating parking lot rows: %w", err)
+	}
+
+	return results, nil
+}
+
+// Sync is a no-op under Postgres: the database trigger keeps the geography
+// column current on every insert/update, so there's no in-process index to
+// refresh.
+func (r *postgresParkingLotRepository) Sync(ctx context.Context, exec database.Executor, lot models.ParkingLot) error {
+	return nil
+}
@@ -0,0 +1,70 @@
+// Package repositories holds the first data-access abstraction in this
+// codebase: ParkingLotRepository isolates the geospatial "find nearby"
+// query from the backend it runs against (PostGIS on Postgres, an
+// in-memory R-tree on SQLite) so handlers don't need their own driver
+// branching. Every method takes a database.Executor so callers can run a
+// lookup against the pool or fold it into an in-flight transaction opened
+// with database.Store.WithTx. Every other entity still goes through
+// database.App directly; generalizing the repository pattern across those
+// is a larger follow-up than this package alone.
+package repositories
+
+import (
+	"context"
+
+	"github.com/holycan/smart-parking-system/database"
+	"github.com/holycan/smart-parking-system/models"
+)
+
+// NearbyFilters narrows a FindNearby search. A nil/zero field means "no
+// filter applied" for that field.
+type NearbyFilters struct {
+	IsOpen24H     *bool
+	OnlyAvailable bool
+	// MaxHourlyRate of 0 means unlimited.
+	MaxHourlyRate float64
+	// VehicleType, when set, restricts results to lots with at least one
+	// parking_spaces row whose space_type matches (further narrowed to an
+	// available one of that type when OnlyAvailable is also set).
+	VehicleType string
+	// OpenNowAt, when non-nil, restricts results to lots open at this
+	// time-of-day ("HH:MM:SS", already resolved to the caller's timezone by
+	// the handler) - see isOpenAt.
+	OpenNowAt *string
+}
+
+// isOpenAt reports whether a lot with the given open/close/24h fields is
+// open at timeOfDay ("HH:MM:SS"), wrapping past midnight when closeTime is
+// earlier than openTime (e.g. a 22:00:00-06:00:00 overnight lot).
+func isOpenAt(isOpen24h bool, openTime, closeTime, timeOfDay string) bool {
+	if isOpen24h {
+		return true
+	}
+	if openTime <= closeTime {
+		return timeOfDay >= openTime && timeOfDay <= closeTime
+	}
+	return timeOfDay >= openTime || timeOfDay <= closeTime
+}
+
+// ParkingLotRepository finds parking lots by geographic proximity.
+type ParkingLotRepository interface {
+	// FindNearby returns lots within radiusMeters of (lat, lon), sorted by
+	// distance ascending, with filters applied. exec is usually
+	// database.App, or a transaction's Executor if the caller is already
+	// inside a database.Store.WithTx block.
+	FindNearby(ctx context.Context, exec database.Executor, lat, lon float64, radiusMeters int, filters NearbyFilters) ([]models.LotWithDistance, error)
+
+	// Sync refreshes the repository's search index after lot is inserted or
+	// updated. Postgres is a no-op (the GIST index and its trigger keep
+	// themselves current); the SQLite R-tree needs this called explicitly.
+	Sync(ctx context.Context, exec database.Executor, lot models.ParkingLot) error
+}
+
+// NewParkingLotRepository returns the ParkingLotRepository implementation
+// matching the active database driver.
+func NewParkingLotRepository() ParkingLotRepository {
+	if database.ActiveDriver == database.DriverSQLite {
+		return newSQLiteParkingLotRepository()
+	}
+	return newPostgresParkingLotRepository()
+}
@@ -0,0 +1,227 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"sync"
+
+	"github.com/dhconnelly/rtreego"
+
+	"github.com/holycan/smart-parking-system/database"
+	"github.com/holycan/smart-parking-system/models"
+)
+
+// earthRadiusMeters is used by the haversine distance check that follows
+// every R-tree bounding-box prefilter below.
+const earthRadiusMeters = 6371000.0
+
+// sqliteLotPoint adapts a parking lot into rtreego.Spatial so it can be
+// indexed by (longitude, latitude). rtreego has no notion of geography —
+// the tree only narrows candidates by bounding box; haversineMeters does
+// the real distance check afterward.
+type sqliteLotPoint struct {
+	lot models.ParkingLot
+}
+
+func (p sqliteLotPoint) Bounds() *rtreego.Rect {
+	rect, err := rtreego.NewRect(rtreego.Point{p.lot.Longitude, p.lot.Latitude}, []float64{1e-9, 1e-9})
+	if err != nil {
+		// rtreego rejects a zero-size rect; the epsilon above always avoids
+		// this, so this branch is unreachable in practice.
+		panic(fmt.Sprintf("repositories: invalid parking lot bounds: %v", err))
+	}
+	return rect
+}
+
+// sqliteParkingLotRepository answers FindNearby from an in-memory R-tree,
+// since SQLite has no PostGIS/GIST equivalent. The tree is rebuilt from the
+// parking_lots table on construction (i.e. on process startup) and again
+// whenever Sync is called after a write.
+type sqliteParkingLotRepository struct {
+	mu   sync.Mutex
+	tree *rtreego.Rtree
+}
+
+func newSQLiteParkingLotRepository() *sqliteParkingLotRepository {
+	repo := &sqliteParkingLotRepository{}
+	// There's no caller-supplied Executor at construction time (this runs
+	// once at process startup), so this bootstrap rebuild reaches into
+	// database.App directly rather than threading one in from main.go.
+	if err := repo.rebuild(context.Background(), database.App); err != nil {
+		log.Printf("repositories: failed to build initial parking lot R-tree: %v", err)
+	}
+	return repo
+}
+
+func (r *sqliteParkingLotRepository) rebuild(ctx context.Context, exec database.Executor) error {
+	rows, err := exec.QueryContext(ctx, `
+		SELECT id, name, address, latitude, longitude, total_spaces, hourly_rate, open_time, close_time, is_open_24h, created_at, updated_at
+		FROM parking_lots
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to load parking lots for R-tree: %w", err)
+	}
+	defer rows.Close()
+
+	tree := rtreego.NewTree(2, 25, 50)
+	for rows.Next() {
+		var lot models.ParkingLot
+		var isOpen24h int
+		if err := rows.Scan(
+			&lot.ID, &lot.Name, &lot.Address, &lot.Latitude, &lot.Longitude,
+			&lot.TotalSpaces, &lot.HourlyRate, &lot.OpenTime, &lot.CloseTime, &isOpen24h, &lot.CreatedAt, &lot.UpdatedAt,
+		); err != nil {
+			return fmt.Errorf("failed to scan parking lot row for R-tree: %w", err)
+		}
+		lot.IsOpen24H = isOpen24h != 0
+		tree.Insert(sqliteLotPoint{lot: lot})
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating parking lot rows for R-tree: %w", err)
+	}
+
+	r.mu.Lock()
+	r.tree = tree
+	r.mu.Unlock()
+	return nil
+}
+
+// Sync rebuilds the R-tree from scratch. Parking lot writes have no handler
+// yet (there's still no CreateParkingLot/UpdateParkingLot endpoint), so the
+// O(n) cost of a full rebuild is a non-issue in practice; this can be
+// replaced with a targeted insert/delete once writes exist.
+func (r *sqliteParkingLotRepository) Sync(ctx context.Context, exec database.Executor, lot models.ParkingLot) error {
+	return r.rebuild(ctx, exec)
+}
+
+func (r *sqliteParkingLotRepository) FindNearby(ctx context.Context, exec database.Executor, lat, lon float64, radiusMeters int, filters NearbyFilters) ([]models.LotWithDistance, error) {
+	r.mu.Lock()
+	tree := r.tree
+	r.mu.Unlock()
+
+	if tree == nil {
+		return nil, fmt.Errorf("parking lot R-tree is not initialized")
+	}
+
+	// Widen the bounding box generously in degrees; it only needs to avoid
+	// missing real matches, since haversineMeters below does the precise
+	// radius check on every candidate it returns.
+	degreeSpan := float64(radiusMeters)/earthRadiusMeters*(180/math.Pi) + 0.05
+	bbox, err := rtreego.NewRect(
+		rtreego.Point{lon - degreeSpan, lat - degreeSpan},
+		[]float64{degreeSpan * 2, degreeSpan * 2},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build search bounding box: %w", err)
+	}
+
+	var results []models.LotWithDistance
+	for _, candidate := range tree.SearchIntersect(bbox) {
+		lot := candidate.(sqliteLotPoint).lot
+
+		distance := haversineMeters(lat, lon, lot.Latitude, lot.Longitude)
+		if distance > float64(radiusMeters) {
+			continue
+		}
+		if filters.IsOpen24H != nil && lot.IsOpen24H != *filters.IsOpen24H {
+			continue
+		}
+		if filters.MaxHourlyRate > 0 && lot.HourlyRate > filters.MaxHourlyRate {
+			continue
+		}
+		if filters.OpenNowAt != nil && !isOpenAt(lot.IsOpen24H, lot.OpenTime, lot.CloseTime, *filters.OpenNowAt) {
+			continue
+		}
+
+		availableSpots, err := countAvailableSpotsSQLite(ctx, exec, lot.ID)
+		if err != nil {
+			return nil, err
+		}
+		if filters.OnlyAvailable && availableSpots == 0 {
+			continue
+		}
+
+		if filters.VehicleType != "" {
+			hasType, err := lotHasVehicleTypeSQLite(ctx, exec, lot.ID, filters.VehicleType, filters.OnlyAvailable)
+			if err != nil {
+				return nil, err
+			}
+			if !hasType {
+				continue
+			}
+		}
+
+		results = append(results, models.LotWithDistance{
+			ParkingLot:     lot,
+			DistanceMeters: distance,
+			AvailableSpots: availableSpots,
+		})
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].DistanceMeters < results[j].DistanceMeters
+	})
+
+	return results, nil
+}
+
+func countAvailableSpotsSQLite(ctx context.Context, exec database.Executor, lotID string) (int, error) {
+	var count int
+	err := exec.QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM parking_spaces ps
+		WHERE ps.parking_lot_id = ?
+		  AND NOT EXISTS (
+			SELECT 1 FROM reservations r
+			WHERE r.parking_space_id = ps.id
+			  AND r.status IN ('active', 'checked-in')
+		  )
+	`, lotID).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count available spots: %w", err)
+	}
+	return count, nil
+}
+
+// lotHasVehicleTypeSQLite reports whether lotID has a parking_spaces row of
+// vehicleType, additionally requiring it be unreserved when onlyAvailable.
+func lotHasVehicleTypeSQLite(ctx context.Context, exec database.Executor, lotID, vehicleType string, onlyAvailable bool) (bool, error) {
+	query := `
+		SELECT EXISTS(
+			SELECT 1 FROM parking_spaces ps
+			WHERE ps.parking_lot_id = ? AND ps.space_type = ?
+	`
+	if onlyAvailable {
+		query += `
+			  AND NOT EXISTS (
+				SELECT 1 FROM reservations r
+				WHERE r.parking_space_id = ps.id
+				  AND r.status IN ('active', 'checked-in')
+			  )
+		`
+	}
+	query += ")"
+
+	var exists bool
+	if err := exec.QueryRowContext(ctx, query, lotID, vehicleType).Scan(&exists); err != nil {
+		return false, fmt.Errorf("failed to check vehicle type availability: %w", err)
+	}
+	return exists, nil
+}
+
+// haversineMeters returns the great-circle distance between two lat/lon
+// points, in meters.
+func haversineMeters(lat1, lon1, lat2, lon2 float64) float64 {
+	toRad := func(deg float64) float64 { return deg * math.Pi / 180 }
+
+	dLat := toRad(lat2 - lat1)
+	dLon := toRad(lon2 - lon1)
+
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(toRad(lat1))*math.Cos(toRad(lat2))*math.Sin(dLon/2)*math.Sin(dLon/2)
+	c := 2 * math.Atan2(math.Sqrt(a), math.Sqrt(1-a))
+
+	return earthRadiusMeters * c
+}
@@ -1,64 +1,182 @@
 package routes
 
 import (
+	"time"
+
 	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 
+	"github.com/holycan/smart-parking-system/config"
 	"github.com/holycan/smart-parking-system/handlers"
+	"github.com/holycan/smart-parking-system/lock"
 	"github.com/holycan/smart-parking-system/middleware"
 	"github.com/holycan/smart-parking-system/utils"
 )
 
 // RegisterRoutes sets up all the routes for the application
 func RegisterRoutes(router *gin.Engine) {
+	rl := config.Get().AuthRateLimit
+
+	router.Use(middleware.Tracing())
+	router.Use(middleware.Metrics())
+
+	// General per-IP request budget, on top of the tighter per-endpoint
+	// AuthRateLimit applied below to the auth routes specifically. Backed
+	// by Redis instead of the in-process default once
+	// config.RateLimitConfig.Backend is "redis" - see middleware.NewLimiter.
+	rateLimitCfg := config.Get().RateLimit
+	limiter := middleware.NewLimiter(rateLimitCfg, lock.RedisClient, rateLimitCfg.RequestsPerSecond, time.Second)
+	router.Use(middleware.RateLimitMiddleware(limiter))
 
 	// Add WebSocket route with authentication middleware
 	router.GET("/ws", middleware.AuthWebSocketMiddleware(), func(c *gin.Context) {
 		utils.WsManager.HandleWebSocket(c)
 	})
 
+	// Prometheus scrape endpoint - see internal/metrics for the collectors
+	// registered against the default registry. Gated behind basic auth when
+	// METRICS_BASIC_AUTH_USER/PASSWORD are set; left open otherwise, since
+	// most deployments scrape it from inside their own network.
+	metricsCfg := config.Get().Metrics
+	if metricsCfg.BasicAuthUser != "" && metricsCfg.BasicAuthPassword != "" {
+		router.GET("/metrics", gin.BasicAuth(gin.Accounts{
+			metricsCfg.BasicAuthUser: metricsCfg.BasicAuthPassword,
+		}), gin.WrapH(promhttp.Handler()))
+	} else {
+		router.GET("/metrics", gin.WrapH(promhttp.Handler()))
+	}
+
+	// Liveness/version probe - see internal/version for how GitHash and
+	// BuildTime get baked in at build time.
+	router.GET("/healthz", handlers.Healthz)
+
+	// Public key for verifying this instance's JWTs out-of-process - see
+	// auth/keys.
+	router.GET("/.well-known/jwks.json", handlers.JWKS)
+
+	// Signed-URL endpoint for the local filestorage backend (see
+	// filestorage.localBackend.SignedURL) - deliberately unauthenticated,
+	// the signature and expiry in the query string are the access control.
+	router.GET("/files/*filepath", handlers.ServeSignedFile)
+
 	// API routes
 	api := router.Group("/api")
 	{
 		// Public routes
-		api.POST("/users/register", handlers.RegisterUser)
-		api.POST("/users/login", handlers.LoginUser)
+		api.POST(
+			"/users/register",
+			middleware.AuthRateLimit("register", rl.RegisterAttempts, time.Duration(rl.RegisterWindowSeconds)*time.Second, middleware.RateLimitKeyIP),
+			handlers.RegisterUser,
+		)
+		api.POST(
+			"/users/login",
+			middleware.AuthRateLimit("login", rl.LoginAttempts, time.Duration(rl.LoginWindowSeconds)*time.Second, middleware.RateLimitKeyIPAndBodyEmail),
+			handlers.LoginUser,
+		)
 		api.POST("/users/validate-token", handlers.ValidateToken)
-		api.POST("/users/request-password-reset", handlers.RequestPasswordReset)
+		api.POST(
+			"/users/request-password-reset",
+			middleware.AuthRateLimit("password-reset", rl.PasswordResetAttempts, time.Duration(rl.PasswordResetWindowSeconds)*time.Second, middleware.RateLimitKeyBodyEmail),
+			handlers.RequestPasswordReset,
+		)
 		api.POST("/users/reset-password", handlers.ResetPassword)
 		api.GET("/users/verify-email/:token", handlers.VerifyEmail)
+		api.GET("/auth/oauth/:provider/login", handlers.LoginOAuth)
+		api.GET("/auth/oauth/:provider/callback", handlers.OAuthCallback)
+		api.POST("/users/refresh-token", handlers.RefreshToken)
 		api.GET("/parking-lots", handlers.GetParkingLots)
+		api.GET("/parking-lots/nearby", handlers.GetNearbyParkingLots)
 		api.GET("/parking-lots/:id", handlers.GetParkingLotByID)
 		api.GET("/parking-lots/:id/space", handlers.GetParkingSpaceByLotID)
+		api.GET("/parking-lots/:id/spaces/stream", handlers.GetParkingSpacesStreamByLotID)
 
 		// Protected routes (require authentication)
 		protected := api.Group("")
 		protected.Use(middleware.AuthMiddleware())
 		{
-			// Gate routes
-			protected.POST("/checkin/:id", handlers.CheckInHandler)
-			protected.POST("/checkout/:id", handlers.CheckOutHandler)
-			protected.POST("/payment/:id", handlers.PaymentHandler)
+			// Gate routes. These use their own handlers.RequireIdempotencyKey
+			// rather than utils.Idempotency - the duplicate requests that
+			// matter here are a worker retrying the same queue.CheckInJob or
+			// a client retrying a flaky payment call against the same
+			// booking, not a generic duplicate POST, so the key is scoped to
+			// (user, booking) instead of the full request identity.
+			protected.POST("/checkin/:id", handlers.RequireIdempotencyKey(), handlers.CheckInHandler)
+			protected.POST("/checkout/:id", handlers.RequireIdempotencyKey(), handlers.CheckOutHandler)
+			protected.POST("/payment/:id", handlers.RequireIdempotencyKey(), handlers.PaymentHandler)
 
 			// User routes
 			protected.GET("/users/profile", handlers.GetUserProfile)
 			protected.PUT("/users/profile", handlers.UpdateUserProfile)
 			protected.POST("/users/logout", handlers.LogoutUser)
-			protected.POST("/users/refresh-token", handlers.RefreshToken)
 			protected.POST("/users/setup-mfa", handlers.SetupMFA)
-			protected.POST("/users/verify-mfa", handlers.VerifyMFA)
+			protected.POST(
+				"/users/verify-mfa",
+				middleware.AuthRateLimit("verify-mfa", rl.MFAAttempts, time.Duration(rl.MFAWindowSeconds)*time.Second, middleware.RateLimitKeyUser),
+				handlers.VerifyMFA,
+			)
+			protected.POST("/auth/oauth/:provider/link", handlers.LinkOAuthProvider)
+			protected.DELETE("/auth/oauth/:provider/unlink", handlers.UnlinkOAuthProvider)
+
+			// Waitlist routes - populated by ProcessCheckIn when a lot is
+			// completely full (see services.JoinWaitlist).
+			protected.GET("/waitlist/:lotID/position", handlers.GetWaitlistPosition)
+			protected.DELETE("/waitlist/:lotID", handlers.LeaveWaitlist)
 
 			// Parking space routes
 			protected.GET("/parking-spaces", handlers.GetParkingSpaces)
 			protected.GET("/parking-spaces/:id", handlers.GetParkingSpaceByID)
+			protected.GET("/parking-spaces/:id/stream", handlers.GetParkingSpaceStream)
 			protected.GET("/parking-spaces/filter", handlers.FilterParkingSpaces)
 
+			// Vehicle garage routes
+			vehicles := protected.Group("/me/vehicles")
+			{
+				vehicles.GET("", handlers.GetVehicles)
+				vehicles.POST("", handlers.CreateVehicle)
+				vehicles.PUT("/:id", handlers.UpdateVehicle)
+				vehicles.DELETE("/:id", handlers.DeleteVehicle)
+			}
+
 			// Reservation routes
 			reservations := protected.Group("/reservations")
 			{
 				reservations.GET("/user", handlers.GetUserReservations)
 				reservations.GET("/details/:id", handlers.GetReservationDetails)
-				reservations.POST("/create", handlers.CreateReservation)
-				reservations.POST("/cancel/:id", handlers.CancelReservation)
+				reservations.GET("/:id/history", handlers.GetReservationHistory)
+				reservations.POST("/create", utils.Idempotency(), handlers.CreateReservation)
+				reservations.PATCH("/:id", handlers.ExtendReservation)
+				reservations.POST("/cancel/:id", utils.Idempotency(), handlers.CancelReservation)
+				reservations.POST("/:id/documents", handlers.UploadReservationDocument)
+				reservations.GET("/:id/documents/:docID", handlers.DownloadReservationDocument)
+			}
+
+			// Admin routes. RequireRoles is the coarse "staff only" gate;
+			// individual routes still check a specific permission via
+			// RequirePermission on top of it.
+			admin := protected.Group("/admin")
+			admin.Use(middleware.RequireRoles("operator", "admin", "super_admin"))
+			{
+				admin.GET("/jobs", middleware.RequirePermission("admin:jobs:read"), handlers.ListJobs)
+
+				admin.GET("/users/:id/roles", middleware.RequirePermission("admin:users:manage"), handlers.ListUserRoles)
+				admin.POST("/users/:id/roles", middleware.RequirePermission("admin:users:manage"), handlers.GrantUserRole)
+				admin.DELETE("/users/:id/roles", middleware.RequirePermission("admin:users:manage"), handlers.RevokeUserRole)
+
+				admin.GET("/parking-lots/:id/occupancy", middleware.RequirePermission("admin:occupancy:read"), handlers.GetLiveOccupancy)
+
+				// Incident forensics over services.Emit's parking_events log -
+				// see handlers/events_handler.go.
+				admin.GET("/events", middleware.RequirePermission("admin:occupancy:read"), handlers.ListBookingEvents)
+				admin.GET("/events/stream", middleware.RequirePermission("admin:occupancy:read"), handlers.StreamEvents)
+
+				admin.POST("/spots/:id/block", middleware.RequirePermission("admin:spots:manage"), handlers.BlockParkingSpace)
+				admin.POST("/spots/:id/unblock", middleware.RequirePermission("admin:spots:manage"), handlers.UnblockParkingSpace)
+
+				// These mutate another driver's active reservation directly,
+				// so on top of the permission check they require the caller
+				// to have completed MFA for this session.
+				admin.POST("/reservations/:id/force-checkout", middleware.RequirePermission("admin:reservations:manage"), middleware.RequireMFA(), handlers.ForceCheckoutReservation)
+				admin.POST("/reservations/:id/override-spot", middleware.RequirePermission("admin:reservations:manage"), middleware.RequireMFA(), handlers.OverrideReservationSpot)
 			}
 		}
 	}
@@ -0,0 +1,57 @@
+// Package routing gets turn-by-turn directions from a gate (or a user's
+// current location) to an assigned parking space. Client is an interface
+// so the HTTP-backed Valhalla adapter in valhalla.go can be swapped for a
+// different provider later without touching callers.
+package routing
+
+import (
+	"context"
+
+	"github.com/holycan/smart-parking-system/config"
+)
+
+// Point is a WGS84 coordinate, matching models.ParkingLot/ParkingSpace's
+// Latitude/Longitude fields.
+type Point struct {
+	Lat float64
+	Lng float64
+}
+
+// Step is one turn-by-turn instruction.
+type Step struct {
+	Instruction    string  `json:"instruction"`
+	DistanceMeters float64 `json:"distanceMeters"`
+}
+
+// Route is a path from an origin to a destination, returned alongside the
+// spot_assigned WS notification so the mobile client can render it
+// immediately (see handlers.ProcessCheckIn).
+type Route struct {
+	// Polyline is the route geometry, encoded the way the backend returned
+	// it (Valhalla uses Google's polyline algorithm at precision 6) — left
+	// encoded so the client decodes it with whatever mapping SDK it uses.
+	Polyline        string  `json:"polyline"`
+	Steps           []Step  `json:"steps"`
+	DistanceMeters  float64 `json:"distanceMeters"`
+	DurationSeconds float64 `json:"durationSeconds"`
+}
+
+// Client resolves a route between two points. Implemented by
+// *ValhallaClient.
+type Client interface {
+	GetRoute(ctx context.Context, origin, destination Point) (*Route, error)
+}
+
+// Current is the process-wide Client set by Init, following the same
+// global-singleton pattern as email.Current and utils.WsManager. Nil when
+// cfg.Enabled is false, so callers should check that before using it.
+var Current Client
+
+// Init resolves Current from cfg. A no-op (leaving Current nil) when
+// cfg.Enabled is false.
+func Init(cfg config.RoutingConfig) {
+	if !cfg.Enabled {
+		return
+	}
+	Current = NewValhallaClient(cfg)
+}
@@ -0,0 +1,120 @@
+package routing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/holycan/smart-parking-system/config"
+)
+
+// ValhallaClient calls a self-hosted Valhalla server's /route endpoint. Its
+// base URL is configurable the same way the rest of this codebase points
+// at externally-run services (Redis, SMTP, OAuth providers) — via
+// config.RoutingConfig rather than a hardcoded host.
+type ValhallaClient struct {
+	baseURL    string
+	httpClient *http.Client
+}
+
+// NewValhallaClient builds a client from cfg. Callers should check
+// cfg.Enabled before using it — NewValhallaClient doesn't ping baseURL, it
+// just wires up the HTTP client.
+func NewValhallaClient(cfg config.RoutingConfig) *ValhallaClient {
+	timeout := time.Duration(cfg.TimeoutSeconds) * time.Second
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+	return &ValhallaClient{
+		baseURL:    cfg.BaseURL,
+		httpClient: &http.Client{Timeout: timeout},
+	}
+}
+
+type valhallaLocation struct {
+	Lat float64 `json:"lat"`
+	Lon float64 `json:"lon"`
+}
+
+type valhallaRouteRequest struct {
+	Locations []valhallaLocation `json:"locations"`
+	Costing   string             `json:"costing"`
+}
+
+type valhallaManeuver struct {
+	Instruction string  `json:"instruction"`
+	Length      float64 `json:"length"` // km
+}
+
+type valhallaLeg struct {
+	Shape     string             `json:"shape"`
+	Maneuvers []valhallaManeuver `json:"maneuvers"`
+	Summary   struct {
+		Length float64 `json:"length"` // km
+		Time   float64 `json:"time"`   // seconds
+	} `json:"summary"`
+}
+
+type valhallaRouteResponse struct {
+	Trip struct {
+		Legs []valhallaLeg `json:"legs"`
+	} `json:"trip"`
+}
+
+// GetRoute requests a driving route from origin to destination. Valhalla
+// reports distance in kilometers, so DistanceMeters is converted here.
+func (c *ValhallaClient) GetRoute(ctx context.Context, origin, destination Point) (*Route, error) {
+	body, err := json.Marshal(valhallaRouteRequest{
+		Locations: []valhallaLocation{
+			{Lat: origin.Lat, Lon: origin.Lng},
+			{Lat: destination.Lat, Lon: destination.Lng},
+		},
+		Costing: "auto",
+	})
+	if err != nil {
+		return nil, fmt.Errorf("routing: failed to marshal Valhalla request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/route", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("routing: failed to build Valhalla request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("routing: Valhalla request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("routing: Valhalla returned status %d", resp.StatusCode)
+	}
+
+	var decoded valhallaRouteResponse
+	if err := json.NewDecoder(resp.Body).Decode(&decoded); err != nil {
+		return nil, fmt.Errorf("routing: failed to decode Valhalla response: %w", err)
+	}
+	if len(decoded.Trip.Legs) == 0 {
+		return nil, fmt.Errorf("routing: Valhalla returned no legs")
+	}
+	leg := decoded.Trip.Legs[0]
+
+	steps := make([]Step, 0, len(leg.Maneuvers))
+	for _, m := range leg.Maneuvers {
+		steps = append(steps, Step{
+			Instruction:    m.Instruction,
+			DistanceMeters: m.Length * 1000,
+		})
+	}
+
+	return &Route{
+		Polyline:        leg.Shape,
+		Steps:           steps,
+		DistanceMeters:  leg.Summary.Length * 1000,
+		DurationSeconds: leg.Summary.Time,
+	}, nil
+}
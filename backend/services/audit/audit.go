@@ -0,0 +1,71 @@
+package audit
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+// Execer is satisfied by both *sql.DB and *sql.Tx so a caller already inside
+// a transaction can record an event as part of the same commit.
+type Execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// RecordEvent appends an immutable row to reservation_events describing a
+// state transition. metadata is marshaled to JSONB and may be nil.
+func RecordEvent(exec Execer, reservationID, actorUserID, previousStatus, newStatus string, metadata map[string]interface{}) error {
+	var metadataJSON []byte
+	if metadata != nil {
+		encoded, err := json.Marshal(metadata)
+		if err != nil {
+			return fmt.Errorf("failed to marshal event metadata: %w", err)
+		}
+		metadataJSON = encoded
+	}
+
+	_, err := exec.Exec(`
+		INSERT INTO reservation_events (id, reservation_id, actor_user_id, previous_status, new_status, metadata, occurred_at)
+		VALUES ($1, $2, $3, $4, $5, $6, NOW())
+	`, uuid.New().String(), reservationID, actorUserID, previousStatus, newStatus, metadataJSON)
+	if err != nil {
+		return fmt.Errorf("failed to record reservation event: %w", err)
+	}
+
+	return nil
+}
+
+// RecordAdminAction appends an immutable row to audit_logs describing a
+// mutating admin action — force-checkout, a spot block/unblock, a spot
+// override — distinct from RecordEvent's reservation-status-transition log
+// and rbac's role_audit_log. before and after are each marshaled to JSONB
+// and may be nil.
+func RecordAdminAction(exec Execer, actorUserID, actorIP, action, target string, before, after map[string]interface{}) error {
+	beforeJSON, err := marshalOrNil(before)
+	if err != nil {
+		return fmt.Errorf("failed to marshal admin action before-state: %w", err)
+	}
+	afterJSON, err := marshalOrNil(after)
+	if err != nil {
+		return fmt.Errorf("failed to marshal admin action after-state: %w", err)
+	}
+
+	_, err = exec.Exec(`
+		INSERT INTO audit_logs (id, actor_user_id, actor_ip, action, target, before_state, after_state, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, NOW())
+	`, uuid.New().String(), actorUserID, actorIP, action, target, beforeJSON, afterJSON)
+	if err != nil {
+		return fmt.Errorf("failed to record admin action: %w", err)
+	}
+
+	return nil
+}
+
+func marshalOrNil(m map[string]interface{}) ([]byte, error) {
+	if m == nil {
+		return nil, nil
+	}
+	return json.Marshal(m)
+}
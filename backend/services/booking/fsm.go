@@ -0,0 +1,297 @@
+// Package booking is the single entry point for moving a reservation
+// through its lifecycle. Before this package existed, ProcessCheckIn (see
+// handlers.ProcessCheckIn) drove the database directly through a
+// goto-based fallback chain and a best-effort RevertBookingSpot on
+// failure; a crash between the reservation update and the parking-space
+// update could leave the two out of sync, and nothing stopped a handler
+// from applying a transition that didn't make sense for the booking's
+// current state. TransitionBooking replaces both: every transition is
+// validated against a fixed table and applied atomically in one
+// transaction.
+package booking
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/holycan/smart-parking-system/database"
+)
+
+// State is one stage of a reservation's lifecycle, persisted in
+// reservations.lifecycle_state.
+type State string
+
+const (
+	StateInit           State = "init"
+	StatePaymentPending State = "payment_pending"
+	StateConfirmed      State = "confirmed"
+	StateSpotLocked     State = "spot_locked"
+	StateCheckedIn      State = "checked_in"
+	StateActive         State = "active"
+	StateCheckedOut     State = "checked_out"
+	StateExpired        State = "expired"
+	StateCancelled      State = "cancelled"
+)
+
+// Event triggers a transition out of the booking's current State.
+type Event string
+
+const (
+	EventPay         Event = "pay"
+	EventConfirm     Event = "confirm"
+	EventLockSpot    Event = "lock_spot"
+	EventReleaseLock Event = "release_lock"
+	EventCheckIn     Event = "check_in"
+	EventActivate    Event = "activate"
+	EventCheckOut    Event = "check_out"
+	EventExpire      Event = "expire"
+	EventCancel      Event = "cancel"
+	EventMoveSpot    Event = "move_spot"
+)
+
+// ErrInvalidTransition is returned when event doesn't apply to the
+// booking's current state - e.g. checking in a booking that was never
+// confirmed, or checking out one that's already checked out.
+var ErrInvalidTransition = errors.New("booking: invalid state transition")
+
+// ErrTargetSpaceOccupied is returned by EventMoveSpot when the space the
+// caller asked to move a booking to is already occupied by someone else -
+// the CAS equivalent of ExtendReservation's old conflicting-reservation
+// check, just against parking_spaces directly instead of a second
+// reservations row that may not even be the one actually holding it.
+var ErrTargetSpaceOccupied = errors.New("booking: target parking space is already occupied")
+
+// transitions is the fixed table TransitionBooking validates every event
+// against. Cancel is reachable from every pre-checkout state; ReleaseLock
+// is the compensating transition back to Confirmed when a locked spot
+// can't be carried through to CheckedIn (occupied after all, or the
+// caller wants to try a different space). MoveSpot is a self-loop on
+// every one of those states too - ExtendReservation's change-of-space path
+// doesn't move a booking to a new lifecycle stage, it just repoints which
+// space (if any) the booking holds.
+var transitions = map[State]map[Event]State{
+	StateInit: {
+		EventPay:     StatePaymentPending,
+		EventConfirm: StateConfirmed,
+		// CreateReservation doesn't route bookings through an explicit
+		// confirm step today, so check-in has to be reachable straight
+		// from Init too - this mirrors the pre-FSM behavior, where
+		// ProcessCheckIn never looked at the reservation's status before
+		// assigning it a spot.
+		EventLockSpot: StateSpotLocked,
+		EventExpire:   StateExpired,
+		EventCancel:   StateCancelled,
+		EventMoveSpot: StateInit,
+	},
+	StatePaymentPending: {
+		EventConfirm:  StateConfirmed,
+		EventExpire:   StateExpired,
+		EventCancel:   StateCancelled,
+		EventMoveSpot: StatePaymentPending,
+	},
+	StateConfirmed: {
+		EventLockSpot: StateSpotLocked,
+		EventExpire:   StateExpired,
+		EventCancel:   StateCancelled,
+		EventMoveSpot: StateConfirmed,
+	},
+	StateSpotLocked: {
+		EventCheckIn:     StateCheckedIn,
+		EventReleaseLock: StateConfirmed,
+		EventCancel:      StateCancelled,
+		// RehydrateInFlight's startup sweep isn't the only way a booking
+		// stuck here gets freed - ExpiryWatcher carries the same deadline
+		// through SpotLocked (see LoadPending) and expires it like any
+		// other overdue booking if the driver never checks in before a
+		// long-running process's next restart would have.
+		EventExpire:   StateExpired,
+		EventMoveSpot: StateSpotLocked,
+	},
+	StateCheckedIn: {
+		EventActivate: StateActive,
+		EventCancel:   StateCancelled,
+		EventMoveSpot: StateCheckedIn,
+	},
+	StateActive: {
+		EventCheckOut: StateCheckedOut,
+		EventMoveSpot: StateActive,
+	},
+}
+
+// legacyStatus maps a State onto the reservations.status vocabulary every
+// other query in this codebase still filters on, so TransitionBooking can
+// keep that column current without every one of those call sites having
+// to learn the new state names.
+func legacyStatus(s State) string {
+	switch s {
+	case StateInit, StatePaymentPending:
+		return "pending"
+	case StateConfirmed:
+		return "confirmed"
+	case StateSpotLocked, StateCheckedIn, StateActive:
+		return "active"
+	case StateCheckedOut:
+		return "completed"
+	case StateExpired:
+		return "expired"
+	case StateCancelled:
+		return "cancelled"
+	default:
+		return string(s)
+	}
+}
+
+// LockSpotMeta is the metadata EventLockSpot needs: the space being
+// claimed and the lot it belongs to. The caller is expected to have
+// already claimed the space's occupancy itself via
+// services.TryOccupyParkingSpace before firing this event - TransitionBooking
+// only records that assignment against the reservation, it doesn't attempt
+// the occupancy CAS itself, since retrying that belongs to the caller (see
+// ProcessCheckIn), not to a single state transition.
+type LockSpotMeta struct {
+	ParkingLotID   string
+	ParkingSpaceID string
+}
+
+// MoveSpotMeta is the metadata EventMoveSpot needs: the space a booking is
+// moving to. Unlike LockSpotMeta, the caller doesn't pre-claim the new
+// space itself - TransitionBooking does, releasing the old space and
+// CASing the new one in the same transaction as the reservation's
+// parking_space_id update, so the two spaces and the reservation row can
+// never land out of step the way ExtendReservation's old
+// update-reservations-only move did.
+type MoveSpotMeta struct {
+	ParkingSpaceID string
+}
+
+// Result is what a successful TransitionBooking call reports back.
+type Result struct {
+	PreviousState  State
+	NewState       State
+	ParkingSpaceID string
+}
+
+// TransitionBooking validates and applies event against bookingID's
+// current lifecycle_state, inside a single transaction so the reservation
+// row and (for EventCheckOut/EventCancel/EventExpire releasing a spot
+// locked via EventLockSpot, or EventMoveSpot swapping one) the
+// parking_spaces row land together or not at all - the crash window that
+// used to exist between the equivalent two separate
+// UpdateBookingWithSpot/UpdateParkingSpaceOccupied calls. EventLockSpot
+// itself only assigns the already-claimed space to the reservation row;
+// claiming the space's occupancy is the caller's job via
+// services.TryOccupyParkingSpace (see LockSpotMeta). lockMeta is only
+// consulted for EventLockSpot and may be nil otherwise; moveMeta is only
+// consulted for EventMoveSpot and may be nil otherwise.
+func TransitionBooking(ctx context.Context, bookingID, userID string, event Event, lockMeta *LockSpotMeta, moveMeta *MoveSpotMeta) (Result, error) {
+	var result Result
+
+	err := database.App.WithTx(ctx, func(ctx context.Context, exec database.Executor) error {
+		var current State
+		var parkingSpaceID string
+		row := exec.QueryRowContext(ctx, `
+			SELECT lifecycle_state, parking_space_id
+			FROM reservations
+			WHERE id = $1 AND user_id = $2
+			FOR UPDATE
+		`, bookingID, userID)
+		if err := row.Scan(&current, &parkingSpaceID); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return fmt.Errorf("booking %s not found for user %s: %w", bookingID, userID, err)
+			}
+			return fmt.Errorf("failed to load booking %s: %w", bookingID, err)
+		}
+
+		next, ok := transitions[current][event]
+		if !ok {
+			return fmt.Errorf("%w: %s -> (%s)", ErrInvalidTransition, current, event)
+		}
+
+		if event == EventLockSpot {
+			if lockMeta == nil {
+				return fmt.Errorf("booking: event %s requires LockSpotMeta", event)
+			}
+			if _, err := exec.ExecContext(ctx, `
+				UPDATE reservations
+				SET parking_lot_id = $1, parking_space_id = $2, checkin_time = NOW()
+				WHERE id = $3
+			`, lockMeta.ParkingLotID, lockMeta.ParkingSpaceID, bookingID); err != nil {
+				return fmt.Errorf("failed to assign space %s to booking %s: %w", lockMeta.ParkingSpaceID, bookingID, err)
+			}
+			parkingSpaceID = lockMeta.ParkingSpaceID
+		}
+
+		if event == EventMoveSpot {
+			if moveMeta == nil {
+				return fmt.Errorf("booking: event %s requires MoveSpotMeta", event)
+			}
+			if moveMeta.ParkingSpaceID != parkingSpaceID {
+				// Init/PaymentPending/Confirmed haven't had a physical spot
+				// assigned yet (that only happens at EventLockSpot), so
+				// there's nothing occupied to swap - just the reservation
+				// row to repoint. SpotLocked/CheckedIn/Active all do hold
+				// one, and have to release it and CAS the new one together.
+				if current == StateSpotLocked || current == StateCheckedIn || current == StateActive {
+					if parkingSpaceID != "" {
+						if _, err := exec.ExecContext(ctx, `
+							UPDATE parking_spaces SET is_occupied = false, resource_version = resource_version + 1 WHERE id = $1
+						`, parkingSpaceID); err != nil {
+							return fmt.Errorf("failed to release parking space %s: %w", parkingSpaceID, err)
+						}
+					}
+
+					res, err := exec.ExecContext(ctx, `
+						UPDATE parking_spaces SET is_occupied = true, resource_version = resource_version + 1
+						WHERE id = $1 AND is_occupied = false
+					`, moveMeta.ParkingSpaceID)
+					if err != nil {
+						return fmt.Errorf("failed to occupy parking space %s: %w", moveMeta.ParkingSpaceID, err)
+					}
+					if affected, err := res.RowsAffected(); err != nil {
+						return fmt.Errorf("failed to occupy parking space %s: %w", moveMeta.ParkingSpaceID, err)
+					} else if affected == 0 {
+						return fmt.Errorf("%w: %s", ErrTargetSpaceOccupied, moveMeta.ParkingSpaceID)
+					}
+				}
+
+				if _, err := exec.ExecContext(ctx, `
+					UPDATE reservations SET parking_space_id = $1 WHERE id = $2
+				`, moveMeta.ParkingSpaceID, bookingID); err != nil {
+					return fmt.Errorf("failed to move booking %s to space %s: %w", bookingID, moveMeta.ParkingSpaceID, err)
+				}
+				parkingSpaceID = moveMeta.ParkingSpaceID
+			}
+		}
+
+		if (event == EventReleaseLock || event == EventCancel || event == EventExpire) && current == StateSpotLocked && parkingSpaceID != "" {
+			if _, err := exec.ExecContext(ctx, `
+				UPDATE parking_spaces SET is_occupied = false, resource_version = resource_version + 1 WHERE id = $1
+			`, parkingSpaceID); err != nil {
+				return fmt.Errorf("failed to release parking space %s: %w", parkingSpaceID, err)
+			}
+		}
+
+		if event == EventCheckOut {
+			if _, err := exec.ExecContext(ctx, `
+				UPDATE parking_spaces SET is_occupied = false, resource_version = resource_version + 1 WHERE id = $1
+			`, parkingSpaceID); err != nil {
+				return fmt.Errorf("failed to release parking space %s: %w", parkingSpaceID, err)
+			}
+		}
+
+		if _, err := exec.ExecContext(ctx, `
+			UPDATE reservations
+			SET lifecycle_state = $1, status = $2, lifecycle_transitioned_at = NOW(), updated_at = NOW()
+			WHERE id = $3
+		`, next, legacyStatus(next), bookingID); err != nil {
+			return fmt.Errorf("failed to persist booking %s transition %s -> %s: %w", bookingID, current, next, err)
+		}
+
+		result = Result{PreviousState: current, NewState: next, ParkingSpaceID: parkingSpaceID}
+		return nil
+	})
+
+	return result, err
+}
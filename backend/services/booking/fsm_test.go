@@ -0,0 +1,60 @@
+package booking
+
+import "testing"
+
+// TestTransitionsSpotLockedAllowsExpire guards the crash-consistency gap a
+// review caught: a booking that never gets checked in has to be reachable
+// from SpotLocked to Expired, or ExpiryWatcher.expire's SpotLocked branch is
+// dead code and the booking (and its spot) stay stuck until the next
+// process restart's RehydrateInFlight sweep.
+func TestTransitionsSpotLockedAllowsExpire(t *testing.T) {
+	next, ok := transitions[StateSpotLocked][EventExpire]
+	if !ok {
+		t.Fatal("expected StateSpotLocked to accept EventExpire")
+	}
+	if next != StateExpired {
+		t.Fatalf("expected EventExpire from StateSpotLocked to land on StateExpired, got %s", next)
+	}
+}
+
+// TestTransitionsMoveSpotIsSelfLoop checks that EventMoveSpot never changes
+// a booking's lifecycle stage - ExtendReservation uses it purely to
+// repoint which space (if any) a booking holds.
+func TestTransitionsMoveSpotIsSelfLoop(t *testing.T) {
+	states := []State{
+		StateInit, StatePaymentPending, StateConfirmed,
+		StateSpotLocked, StateCheckedIn, StateActive,
+	}
+	for _, s := range states {
+		next, ok := transitions[s][EventMoveSpot]
+		if !ok {
+			t.Errorf("expected %s to accept EventMoveSpot", s)
+			continue
+		}
+		if next != s {
+			t.Errorf("expected EventMoveSpot from %s to stay on %s, got %s", s, s, next)
+		}
+	}
+}
+
+func TestLegacyStatus(t *testing.T) {
+	cases := []struct {
+		state    State
+		expected string
+	}{
+		{StateInit, "pending"},
+		{StatePaymentPending, "pending"},
+		{StateConfirmed, "confirmed"},
+		{StateSpotLocked, "active"},
+		{StateCheckedIn, "active"},
+		{StateActive, "active"},
+		{StateCheckedOut, "completed"},
+		{StateExpired, "expired"},
+		{StateCancelled, "cancelled"},
+	}
+	for _, c := range cases {
+		if got := legacyStatus(c.state); got != c.expected {
+			t.Errorf("legacyStatus(%s) = %q, want %q", c.state, got, c.expected)
+		}
+	}
+}
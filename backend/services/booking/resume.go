@@ -0,0 +1,63 @@
+package booking
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/holycan/smart-parking-system/database"
+)
+
+// stuckSpotLockGrace is how long a booking is allowed to sit in
+// StateSpotLocked before RehydrateInFlight treats it as orphaned by a
+// crash (ProcessCheckIn normally carries a booking through SpotLocked to
+// CheckedIn within the same call, in well under a second).
+const stuckSpotLockGrace = 2 * time.Minute
+
+// RehydrateInFlight resolves bookings left mid-transition by a crash -
+// the scenario this package exists to rule out going forward, but one
+// that can still have happened before a given deploy, or from a worker
+// that was killed between acquiring the transaction and this process
+// restarting. It's meant to run once at startup, before ProcessCheckIn
+// starts accepting new work again.
+//
+// The only state that can currently be left stranded is SpotLocked: every
+// other transition either is a terminal state or, like PaymentPending,
+// doesn't hold an exclusive resource (an occupied parking space) that
+// needs releasing. A SpotLocked booking older than stuckSpotLockGrace is
+// compensated back to Confirmed via EventReleaseLock, freeing the space
+// so FindAndLockAvailableSpot can hand it to someone else and the
+// driver's check-in can be retried from scratch.
+func RehydrateInFlight(ctx context.Context) error {
+	rows, err := database.App.QueryContext(ctx, `
+		SELECT id, user_id
+		FROM reservations
+		WHERE lifecycle_state = $1 AND lifecycle_transitioned_at < NOW() - $2::interval
+	`, StateSpotLocked, fmt.Sprintf("%d seconds", int(stuckSpotLockGrace.Seconds())))
+	if err != nil {
+		return fmt.Errorf("booking: failed to query stuck bookings: %w", err)
+	}
+
+	type stuck struct{ id, userID string }
+	var pending []stuck
+	for rows.Next() {
+		var s stuck
+		if err := rows.Scan(&s.id, &s.userID); err != nil {
+			rows.Close()
+			return fmt.Errorf("booking: failed to scan stuck booking: %w", err)
+		}
+		pending = append(pending, s)
+	}
+	rows.Close()
+
+	for _, s := range pending {
+		if _, err := TransitionBooking(ctx, s.id, s.userID, EventReleaseLock, nil, nil); err != nil {
+			log.Printf("booking: failed to rehydrate stuck booking %s: %v", s.id, err)
+			continue
+		}
+		log.Printf("booking: released spot held by stuck booking %s (crash recovery)", s.id)
+	}
+
+	return nil
+}
@@ -0,0 +1,246 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/holycan/smart-parking-system/config"
+	"github.com/holycan/smart-parking-system/database"
+	"github.com/holycan/smart-parking-system/models"
+	"github.com/holycan/smart-parking-system/routing"
+	"github.com/holycan/smart-parking-system/utils"
+)
+
+// Event types Emit is called with from the gate handlers and the expiry
+// watcher. Kept as plain strings (like services/booking.Event) rather than
+// a dedicated type, since parking_events.event_type is just an informational
+// column - nothing here validates a transition against it.
+const (
+	EventTypeCheckInQueued = "checkin_queued"
+	// EventTypeSpotAssigned matches the "spot_assigned" string
+	// ws.handleNotificationUpdate already routes to the driver's own
+	// UserTopic rather than broadcasting it on the space's topic.
+	EventTypeSpotAssigned          = "spot_assigned"
+	EventTypeAlternateSpotAssigned = "alternate_spot_assigned"
+	EventTypeWaitlisted            = "waitlisted"
+	EventTypeNoAvailableSpot       = "no_available_spot"
+	EventTypePaymentCompleted      = "payment_completed"
+	EventTypeCheckedOut            = "checked_out"
+	EventTypeExpired               = "expired"
+)
+
+// Event is one append-only row in parking_events, recording a single state
+// change for a booking. Emit is the only thing that writes these - this
+// replaces the ad-hoc log.Printf calls and one-off notify* functions that
+// used to be the only record a check-in/payment/check-out/expiry ever
+// left behind.
+type Event struct {
+	ID        string
+	BookingID string
+	UserID    string
+	LotID     string
+	SpaceID   string
+	EventType string
+	PrevState string
+	NewState  string
+	// Actor is who caused the event: the driver's own user ID for a
+	// self-service action, "system" for something the expiry watcher or a
+	// background job did on its own.
+	Actor string
+	// Message is the human-readable text forwarded to the driver's
+	// "NOTIFICATION_UPDATE" WS event, the same role notifySpotAssigned's
+	// and the other notify* functions' hardcoded strings used to play.
+	// Left empty, Emit skips the WS notification - not every event (e.g.
+	// EventTypeCheckInQueued) is worth surfacing to the driver.
+	Message string
+	// Route carries turn-by-turn directions to SpaceID, attached to the WS
+	// notification the same way models.NotificationEvent.Route always has -
+	// see handlers.ProcessCheckIn, the only caller that sets it.
+	Route     *routing.Route
+	Timestamp time.Time
+}
+
+// Emit records evt to parking_events, forwards it as a NOTIFICATION_UPDATE
+// WS event when it has a Message (to UserID's own topic, or broadcast to
+// SpaceID's topic when UserID is empty - see ws.handleNotificationUpdate),
+// fans it out to every live GET /admin/events/stream subscriber, and - if
+// config.Get().Events.WebhookURL is set - POSTs it to that webhook in the
+// background. Only the DB write can fail the call; the other two sinks are
+// best-effort and only ever logged.
+func Emit(ctx context.Context, evt Event) error {
+	evt.ID = uuid.New().String()
+	if evt.Timestamp.IsZero() {
+		evt.Timestamp = time.Now()
+	}
+
+	_, err := database.App.ExecContext(ctx, `
+		INSERT INTO parking_events (id, booking_id, user_id, lot_id, space_id, event_type, prev_state, new_state, actor, message, occurred_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+	`, evt.ID, evt.BookingID, evt.UserID, evt.LotID, evt.SpaceID, evt.EventType, evt.PrevState, evt.NewState, evt.Actor, evt.Message, evt.Timestamp)
+	if err != nil {
+		return fmt.Errorf("events: failed to record %s for booking %s: %w", evt.EventType, evt.BookingID, err)
+	}
+
+	if evt.Message != "" {
+		utils.WsManager.HandleNotificationUpdate(models.NotificationEvent{
+			UserID:         evt.UserID,
+			ReservationId:  evt.BookingID,
+			ParkingSpaceId: evt.SpaceID,
+			Type:           evt.EventType,
+			Message:        evt.Message,
+			CreatedAt:      evt.Timestamp,
+			Route:          evt.Route,
+		})
+	}
+
+	broadcastEvent(evt)
+	go deliverWebhook(evt)
+
+	return nil
+}
+
+// ListEventsForBooking replays bookingID's full parking_events history in
+// the order it happened, for GET /admin/events?booking_id=....
+func ListEventsForBooking(ctx context.Context, bookingID string) ([]Event, error) {
+	rows, err := database.App.QueryContext(ctx, `
+		SELECT id, booking_id, user_id, lot_id, space_id, event_type, prev_state, new_state, actor, message, occurred_at
+		FROM parking_events
+		WHERE booking_id = $1
+		ORDER BY occurred_at ASC
+	`, bookingID)
+	if err != nil {
+		return nil, fmt.Errorf("events: failed to load history for booking %s: %w", bookingID, err)
+	}
+	defer rows.Close()
+
+	events := []Event{}
+	for rows.Next() {
+		var evt Event
+		if err := rows.Scan(&evt.ID, &evt.BookingID, &evt.UserID, &evt.LotID, &evt.SpaceID, &evt.EventType, &evt.PrevState, &evt.NewState, &evt.Actor, &evt.Message, &evt.Timestamp); err != nil {
+			return nil, fmt.Errorf("events: failed to scan event for booking %s: %w", bookingID, err)
+		}
+		events = append(events, evt)
+	}
+	return events, rows.Err()
+}
+
+// eventHub fans Emit's Events out to every live GET /admin/events/stream
+// subscriber, the same single-goroutine-owns-the-map shape as
+// internal/occupancy.Hub, just without occupancy's per-lot keying since an
+// operator watching incident forensics wants every lot's events at once.
+type eventHub struct {
+	subscribe   chan chan Event
+	unsubscribe chan chan Event
+	events      chan Event
+}
+
+var hub = newEventHub()
+
+func newEventHub() *eventHub {
+	h := &eventHub{
+		subscribe:   make(chan chan Event),
+		unsubscribe: make(chan chan Event),
+		events:      make(chan Event, 64),
+	}
+	go h.run()
+	return h
+}
+
+func (h *eventHub) run() {
+	subscribers := make(map[chan Event]struct{})
+	for {
+		select {
+		case ch := <-h.subscribe:
+			subscribers[ch] = struct{}{}
+		case ch := <-h.unsubscribe:
+			if _, ok := subscribers[ch]; ok {
+				delete(subscribers, ch)
+				close(ch)
+			}
+		case evt := <-h.events:
+			for ch := range subscribers {
+				select {
+				case ch <- evt:
+				default:
+					// Slow subscriber - drop rather than block every other
+					// subscriber's fan-out; GET /admin/events?booking_id=
+					// still has the durable record.
+				}
+			}
+		}
+	}
+}
+
+func broadcastEvent(evt Event) {
+	select {
+	case hub.events <- evt:
+	default:
+		log.Printf("events: hub buffer full, dropping live broadcast of %s for booking %s", evt.EventType, evt.BookingID)
+	}
+}
+
+// SubscribeEvents registers a channel that receives every Event Emit
+// records from here on, for GET /admin/events/stream. The caller must call
+// UnsubscribeEvents with the same channel once done, or it leaks for the
+// life of the process.
+func SubscribeEvents() chan Event {
+	ch := make(chan Event, 16)
+	hub.subscribe <- ch
+	return ch
+}
+
+// UnsubscribeEvents removes ch from the live fan-out set and closes it.
+func UnsubscribeEvents(ch chan Event) {
+	hub.unsubscribe <- ch
+}
+
+var webhookClient = &http.Client{}
+
+// deliverWebhook POSTs evt to config.Get().Events.WebhookURL, if
+// configured. Best-effort: a failed delivery is logged, never retried -
+// parking_events is the authoritative record, this is just a live mirror
+// for whatever external system the operator pointed it at.
+func deliverWebhook(evt Event) {
+	webhookURL := config.Get().Events.WebhookURL
+	if webhookURL == "" {
+		return
+	}
+
+	payload, err := json.Marshal(evt)
+	if err != nil {
+		log.Printf("events: failed to marshal webhook payload for event %s: %v", evt.ID, err)
+		return
+	}
+
+	timeout := time.Duration(config.Get().Events.WebhookTimeoutSeconds) * time.Second
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhookURL, bytes.NewReader(payload))
+	if err != nil {
+		log.Printf("events: failed to build webhook request for event %s: %v", evt.ID, err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := webhookClient.Do(req)
+	if err != nil {
+		log.Printf("events: webhook delivery failed for event %s: %v", evt.ID, err)
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		log.Printf("events: webhook for event %s returned status %d", evt.ID, resp.StatusCode)
+	}
+}
@@ -0,0 +1,270 @@
+package services
+
+import (
+	"container/heap"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/holycan/smart-parking-system/database"
+	"github.com/holycan/smart-parking-system/services/booking"
+)
+
+// expiryItem is one pending reservation deadline tracked by an
+// ExpiryWatcher.
+type expiryItem struct {
+	bookingID string
+	userID    string
+	expiresAt time.Time
+	index     int
+}
+
+// expiryHeap is a container/heap.Interface ordered by soonest expiresAt,
+// so the watcher can always read the next deadline off the top in O(1).
+type expiryHeap []*expiryItem
+
+func (h expiryHeap) Len() int           { return len(h) }
+func (h expiryHeap) Less(i, j int) bool { return h[i].expiresAt.Before(h[j].expiresAt) }
+func (h expiryHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *expiryHeap) Push(x interface{}) {
+	item := x.(*expiryItem)
+	item.index = len(*h)
+	*h = append(*h, item)
+}
+
+func (h *expiryHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	item := old[n-1]
+	old[n-1] = nil
+	item.index = -1
+	*h = old[:n-1]
+	return item
+}
+
+// tickFallback is how often Run wakes on its own even with no deadline
+// due, so a Register that raced a poke being dropped (the channel is
+// buffered 1) is never stranded for longer than this.
+const tickFallback = time.Minute
+
+// ExpiryWatcher is a block-epoch-style deadline notifier for reservations:
+// a single goroutine (Run) owns a min-heap of pending expiresAt times and
+// sleeps exactly until the next one is due, instead of every caller
+// polling the DB on a fixed interval. CreateReservation registers a
+// booking's deadline; ProcessCheckIn cancels it once the driver checks in.
+// This replaces the implicit assumption that a driver always checks out
+// (or that the 5-minute expire_pending_reservations cron job notices in
+// time) and is what actually frees a spot when a client disconnects
+// mid-reservation without ever checking in.
+type ExpiryWatcher struct {
+	mu    sync.Mutex
+	items expiryHeap
+	byID  map[string]*expiryItem
+	poke  chan struct{}
+}
+
+// Expiry is the process-wide watcher. Run must be started once, by
+// whichever process calls ProcessCheckIn, so Cancel has a chance to race
+// an expiry and win. Registrations made before Run starts simply sit on
+// the heap until the first tick.
+var Expiry = NewExpiryWatcher()
+
+func NewExpiryWatcher() *ExpiryWatcher {
+	return &ExpiryWatcher{
+		byID: make(map[string]*expiryItem),
+		poke: make(chan struct{}, 1),
+	}
+}
+
+// Register arms bookingID's deadline. Re-registering an already-armed
+// booking replaces its deadline in place (used when a reservation is
+// rescheduled - see handlers.PatchReservation).
+func (w *ExpiryWatcher) Register(bookingID, userID string, expiresAt time.Time) {
+	w.mu.Lock()
+	if existing, ok := w.byID[bookingID]; ok {
+		existing.expiresAt = expiresAt
+		existing.userID = userID
+		heap.Fix(&w.items, existing.index)
+	} else {
+		item := &expiryItem{bookingID: bookingID, userID: userID, expiresAt: expiresAt}
+		heap.Push(&w.items, item)
+		w.byID[bookingID] = item
+	}
+	w.mu.Unlock()
+	w.wake()
+}
+
+// Cancel disarms bookingID's deadline. Called once check-in succeeds, so a
+// driver who shows up isn't auto-expired out from under them.
+func (w *ExpiryWatcher) Cancel(bookingID string) {
+	w.mu.Lock()
+	if item, ok := w.byID[bookingID]; ok {
+		heap.Remove(&w.items, item.index)
+		delete(w.byID, bookingID)
+	}
+	w.mu.Unlock()
+}
+
+func (w *ExpiryWatcher) wake() {
+	select {
+	case w.poke <- struct{}{}:
+	default:
+	}
+}
+
+func (w *ExpiryWatcher) nextWake() time.Duration {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if len(w.items) == 0 {
+		return tickFallback
+	}
+	if d := time.Until(w.items[0].expiresAt); d > 0 {
+		return d
+	}
+	return 0
+}
+
+// due pops and returns every item whose deadline has already passed.
+func (w *ExpiryWatcher) due() []*expiryItem {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	var out []*expiryItem
+	for len(w.items) > 0 && !w.items[0].expiresAt.After(time.Now()) {
+		out = append(out, heap.Pop(&w.items).(*expiryItem))
+	}
+	for _, item := range out {
+		delete(w.byID, item.bookingID)
+	}
+	return out
+}
+
+// LoadPending re-arms every reservation whose window can still expire, so a
+// process restart doesn't lose track of deadlines that were created (or
+// rescheduled) before this process started. SpotLocked is included
+// alongside Init/PaymentPending/Confirmed: a booking can sit there
+// indefinitely if the driver never checks in, and RehydrateInFlight's
+// startup sweep only catches that on the next restart, not while this
+// process keeps running - re-arming it here lets the ordinary due/expire
+// path reclaim it on schedule instead. Call this once before Run, in every
+// process that starts a watcher - the API server, so a deadline set up
+// before a deploy still fires, and the worker, so a standalone worker
+// process still expires bookings even without the API server's in-memory
+// registrations. Running a watcher in more than one process is safe:
+// TransitionBooking validates state before applying, so a booking another
+// process already moved past just rejects the redundant expiry as
+// ErrInvalidTransition instead of corrupting anything.
+func (w *ExpiryWatcher) LoadPending(ctx context.Context) error {
+	rows, err := database.App.QueryContext(ctx, `
+		SELECT id, user_id, expired_at
+		FROM reservations
+		WHERE lifecycle_state IN ($1, $2, $3, $4) AND expired_at IS NOT NULL
+	`, booking.StateInit, booking.StatePaymentPending, booking.StateConfirmed, booking.StateSpotLocked)
+	if err != nil {
+		return fmt.Errorf("expiry: failed to load pending reservations: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id, userID string
+		var expiresAt time.Time
+		if err := rows.Scan(&id, &userID, &expiresAt); err != nil {
+			return fmt.Errorf("expiry: failed to scan pending reservation: %w", err)
+		}
+		w.Register(id, userID, expiresAt)
+	}
+	return rows.Err()
+}
+
+// Run owns the watch loop until ctx is cancelled. Each tick - whether from
+// the fallback timer or a Register/Cancel poke - drains every deadline
+// that's now due and resets the timer to the new soonest one.
+func (w *ExpiryWatcher) Run(ctx context.Context) {
+	timer := time.NewTimer(tickFallback)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-w.poke:
+		case <-timer.C:
+		}
+
+		for _, item := range w.due() {
+			w.expire(ctx, item)
+		}
+
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		timer.Reset(w.nextWake())
+	}
+}
+
+// expire transitions one overdue booking to Expired and tells clients the
+// spot is free again. Losing the race to Cancel (the driver checked in
+// right as this fired) surfaces as ErrInvalidTransition, which isn't an
+// error worth logging - it's the expected outcome, not a failure.
+func (w *ExpiryWatcher) expire(ctx context.Context, item *expiryItem) {
+	result, err := booking.TransitionBooking(ctx, item.bookingID, item.userID, booking.EventExpire, nil, nil)
+	if err != nil {
+		if errors.Is(err, booking.ErrInvalidTransition) {
+			return
+		}
+		log.Printf("expiry: failed to expire booking %s: %v", item.bookingID, err)
+		return
+	}
+
+	// The distributed spot-lock (lock.AcquireLock) carries its own short
+	// TTL and is released by ProcessCheckIn's defer as soon as the check-in
+	// attempt finishes either way, so by the time a reservation's window
+	// has actually elapsed there's nothing left held there for this
+	// watcher to release - only the DB-side occupancy flag can still be
+	// stale, and TransitionBooking already cleared that above for any
+	// booking it found in StateSpotLocked.
+	var lotID string
+	if result.ParkingSpaceID != "" {
+		spaceData, err := GetParkingDataById(result.ParkingSpaceID)
+		if err != nil {
+			log.Printf("expiry: failed to look up lot for freed space %s: %v", result.ParkingSpaceID, err)
+		} else {
+			lotID, _ = spaceData["parking_lot_id"].(string)
+		}
+	}
+
+	if err := Emit(ctx, Event{
+		BookingID: item.bookingID,
+		UserID:    item.userID,
+		LotID:     lotID,
+		SpaceID:   result.ParkingSpaceID,
+		EventType: EventTypeExpired,
+		PrevState: string(result.PreviousState),
+		NewState:  string(booking.StateExpired),
+		Actor:     "system",
+		Message:   "Your reservation has expired.",
+	}); err != nil {
+		log.Printf("expiry: failed to record expired event for booking %s: %v", item.bookingID, err)
+	}
+
+	// Only StateSpotLocked actually held a spot - Init/PaymentPending/
+	// Confirmed expired before ever claiming one, so there's nothing to
+	// hand off to the next waitlisted driver.
+	if result.PreviousState == booking.StateSpotLocked && result.ParkingSpaceID != "" && lotID != "" {
+		if err := PromoteNext(ctx, lotID, result.ParkingSpaceID); err != nil {
+			log.Printf("expiry: failed to promote next waitlist entry for freed space %s: %v", result.ParkingSpaceID, err)
+		}
+	}
+
+	log.Printf("expiry: booking %s expired (was %s)", item.bookingID, result.PreviousState)
+}
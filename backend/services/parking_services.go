@@ -1,31 +1,56 @@
 package services
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
+	"log"
 	"time"
 
 	"github.com/go-redsync/redsync/v4"
 
 	"github.com/holycan/smart-parking-system/database"
+	"github.com/holycan/smart-parking-system/internal/metrics"
 	"github.com/holycan/smart-parking-system/lock"
+	"github.com/holycan/smart-parking-system/models"
 )
 
-func FindAndLockAvailableSpot(parkingLotID string) (*redsync.Mutex, map[string]interface{}, error) {
+// ErrVersionConflict is returned by TryOccupyParkingSpace when spaceID's
+// row no longer matches the expected occupied/resourceVersion the caller
+// read - either another writer already claimed it, or the caller's view is
+// stale. It isn't itself a failure: the caller re-reads the space's
+// current state via GetParkingSpaceVersion and retries.
+var ErrVersionConflict = errors.New("services: parking space version conflict")
+
+// FindAndLockAvailableSpot finds and locks an unoccupied spot in
+// parkingLotID, for a booking other than reservationID's own (already-tried)
+// spot. When vehicleType is non-empty, only spaces of that type
+// (motorcycle/car/ev/accessible) are considered, so a compact car doesn't
+// get steered into the one EV-charging bay; an empty vehicleType considers
+// every space type, as it always used to.
+//
+// Candidates held for a promoted waitlist entry (see HoldSpotForPromotion)
+// other than reservationID are skipped rather than returned as the only
+// option: without this, a check-in that isn't the promoted reservation
+// could be handed the held spot, fail occupySpotWithRetry's own
+// SpotHeldForOther check, and come back as "no available spot" even when
+// the lot has other free spaces.
+func FindAndLockAvailableSpot(ctx context.Context, parkingLotID, vehicleType, reservationID string) (*redsync.Mutex, map[string]interface{}, error) {
 	query := `
         SELECT ps.id
         FROM parking_spaces ps
         WHERE ps.parking_lot_id = $1
+          AND ($2 = '' OR ps.type = $2)
+          AND NOT ps.is_blocked
           AND NOT EXISTS (
               SELECT 1 FROM reservations r
               WHERE r.parking_space_id = ps.id
                 AND r.status IN ('active', 'checked-in')
           )
-        LIMIT 1
     `
 
-	rows, err := database.DB.Query(query, parkingLotID)
+	rows, err := database.App.QueryContext(ctx, query, parkingLotID, vehicleType)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -38,19 +63,105 @@ func FindAndLockAvailableSpot(parkingLotID string) (*redsync.Mutex, map[string]i
 			continue
 		}
 
+		held, err := SpotHeldForOther(ctx, spotID, reservationID)
+		if err != nil {
+			log.Printf("services: failed to check waitlist hold for spot %s, trying it anyway: %v", spotID, err)
+		} else if held {
+			continue
+		}
+
 		mutex, err := lock.AcquireLock("spot-lock:"+spotID, 10*time.Second)
 		if err == nil {
 			return mutex, map[string]interface{}{"spot_id": spotID}, nil
 		}
 	}
 
-	return nil, nil, fmt.Errorf("no available spot could be locked in parking lot %s", parkingLotID)
+	return nil, nil, fmt.Errorf("no available %s spot could be locked in parking lot %s", vehicleType, parkingLotID)
+}
+
+// TryOccupyParkingSpace performs a single optimistic-concurrency update on
+// spaceID, modeled on etcd-style compare-and-swap: the write only applies
+// if is_occupied and resource_version still match expectedOccupied and
+// resourceVersion exactly as the caller last read them. A zero-rows-affected
+// result means someone else changed the row first, reported as
+// ErrVersionConflict rather than silently doing nothing, so the caller
+// knows to re-read the space's state and decide whether to retry.
+func TryOccupyParkingSpace(ctx context.Context, exec database.Executor, spaceID string, expectedOccupied, newOccupied bool, resourceVersion int) error {
+	res, err := exec.ExecContext(ctx, `
+		UPDATE parking_spaces
+		SET is_occupied = $1, resource_version = resource_version + 1
+		WHERE id = $2 AND is_occupied = $3 AND resource_version = $4
+	`, newOccupied, spaceID, expectedOccupied, resourceVersion)
+	if err != nil {
+		return fmt.Errorf("failed to CAS parking space %s: %w", spaceID, err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to read rows affected for parking space %s: %w", spaceID, err)
+	}
+	if n == 0 {
+		return ErrVersionConflict
+	}
+
+	reportSpaceOccupancyMetric(spaceID, newOccupied)
+	return nil
+}
+
+// GetParkingSpaceVersion reads spaceID's current occupancy flag and
+// resource_version, for a caller about to attempt TryOccupyParkingSpace (or
+// retry it after an ErrVersionConflict).
+func GetParkingSpaceVersion(ctx context.Context, exec database.Executor, spaceID string) (occupied bool, resourceVersion int, err error) {
+	err = exec.QueryRowContext(ctx,
+		"SELECT is_occupied, resource_version FROM parking_spaces WHERE id = $1", spaceID,
+	).Scan(&occupied, &resourceVersion)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, 0, fmt.Errorf("parking space %s not found", spaceID)
+		}
+		return false, 0, fmt.Errorf("database error: %w", err)
+	}
+	return occupied, resourceVersion, nil
 }
 
 func UpdateParkingSpaceOccupied(status bool, spotID string) error {
 	query := "UPDATE parking_spaces SET is_occupied = $1 WHERE id = $2"
-	_, err := database.DB.Exec(query, status, spotID)
-	return err
+	if _, err := database.App.Exec(query, status, spotID); err != nil {
+		return err
+	}
+	reportSpaceOccupancyMetric(spotID, status)
+	return nil
+}
+
+// reportSpaceOccupancyMetric keeps metrics.ParkingSpacesOccupied and
+// metrics.ParkingLotUtilizationRatio current the moment a space's occupancy
+// flips, rather than waiting for jobs.reportParkingMetrics' next tick (which
+// still re-derives both from scratch, correcting any drift here).
+func reportSpaceOccupancyMetric(spotID string, occupied bool) {
+	var lotID, spaceType string
+	if err := database.App.QueryRow(
+		"SELECT parking_lot_id, type FROM parking_spaces WHERE id = $1", spotID,
+	).Scan(&lotID, &spaceType); err != nil {
+		log.Printf("services: failed to look up parking space %s for occupancy metrics: %v", spotID, err)
+		return
+	}
+
+	if occupied {
+		metrics.ParkingSpacesOccupied.WithLabelValues(lotID, spaceType).Inc()
+	} else {
+		metrics.ParkingSpacesOccupied.WithLabelValues(lotID, spaceType).Dec()
+	}
+
+	var total, stillOccupied int
+	if err := database.App.QueryRow(
+		"SELECT COUNT(*), COUNT(CASE WHEN is_occupied THEN 1 END) FROM parking_spaces WHERE parking_lot_id = $1", lotID,
+	).Scan(&total, &stillOccupied); err != nil {
+		log.Printf("services: failed to refresh utilization ratio for lot %s: %v", lotID, err)
+		return
+	}
+	if total > 0 {
+		metrics.ParkingLotUtilizationRatio.WithLabelValues(lotID).Set(float64(stillOccupied) / float64(total))
+	}
 }
 
 func GetParkingDataById(id string) (map[string]interface{}, error) {
@@ -60,7 +171,7 @@ func GetParkingDataById(id string) (map[string]interface{}, error) {
 		JOIN parking_lots pl ON ps.parking_lot_id = pl.id
 		WHERE ps.id = $1
 	`
-	rows, err := database.DB.Query(query, id)
+	rows, err := database.App.Query(query, id)
 	if err != nil {
 		return nil, err
 	}
@@ -89,12 +200,31 @@ func GetParkingDataById(id string) (map[string]interface{}, error) {
 	return result, nil
 }
 
+// GetParkingSpaceLocation returns a space's own coordinates for
+// routing.Client, falling back to its parking lot's coordinates when the
+// space hasn't been individually surveyed yet (see migration 0010).
+func GetParkingSpaceLocation(spotID string) (lat, lng float64, err error) {
+	query := `
+		SELECT COALESCE(ps.latitude, pl.latitude), COALESCE(ps.longitude, pl.longitude)
+		FROM parking_spaces ps
+		JOIN parking_lots pl ON ps.parking_lot_id = pl.id
+		WHERE ps.id = $1
+	`
+	if err := database.App.QueryRow(query, spotID).Scan(&lat, &lng); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, 0, fmt.Errorf("parking space %s not found", spotID)
+		}
+		return 0, 0, fmt.Errorf("database error: %w", err)
+	}
+	return lat, lng, nil
+}
+
 // IsParkingSpaceOccupied checks if a parking space is currently occupied
 func IsParkingSpaceOccupied(spotID string) (bool, error) {
 	query := "SELECT is_occupied FROM parking_spaces WHERE id = $1"
 
 	var isOccupied bool
-	err := database.DB.QueryRow(query, spotID).Scan(&isOccupied)
+	err := database.App.QueryRow(query, spotID).Scan(&isOccupied)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return false, fmt.Errorf("parking space %s not found", spotID)
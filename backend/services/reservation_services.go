@@ -36,7 +36,7 @@ func UpdateBookingWithSpot(status string, userID string, bookingID string, parki
 
 	// Execute the query and scan the returned values
 	var id, parkingSpaceID string
-	err := database.DB.QueryRow(query, status, parkingLotID, spotID, bookingID, userID, status).Scan(&id, &parkingSpaceID)
+	err := database.App.QueryRow(query, status, parkingLotID, spotID, bookingID, userID, status).Scan(&id, &parkingSpaceID)
 
 	// Handle potential errors
 	if err != nil {
@@ -64,7 +64,7 @@ func RevertBookingSpot(bookingID string, userID string) error {
         WHERE id = $1 AND user_id = $2
     `
 
-	_, err := database.DB.Exec(query, bookingID, userID)
+	_, err := database.App.Exec(query, bookingID, userID)
 	if err != nil {
 		return fmt.Errorf("failed to revert booking %s: %w", bookingID, err)
 	}
@@ -74,13 +74,13 @@ func RevertBookingSpot(bookingID string, userID string) error {
 
 func UpdateBookingPaymentStatus(paymentStatus string, bookingID string) error {
 	query := "UPDATE reservations SET payment_status = $1 WHERE id = $2"
-	_, err := database.DB.Exec(query, paymentStatus, bookingID)
+	_, err := database.App.Exec(query, paymentStatus, bookingID)
 	return err
 }
 
 func GetBookingByID(ReservationID string, userID string) (*models.Reservation, error) {
 	var r models.Reservation
-	err := database.DB.QueryRow(`
+	err := database.App.QueryRow(`
 		SELECT id, user_id, parking_lot_id, parking_space_id, vehicle_type, license_plate,
 		       duration, status, total_cost, payment_status,
 		       created_at, updated_at
@@ -110,3 +110,16 @@ func GetBookingByID(ReservationID string, userID string) (*models.Reservation, e
 
 	return &r, nil
 }
+
+// RecalculateCost pro-rates a reservation's total cost when its duration
+// changes, using the original booking's per-minute rate. It is a stand-in
+// for a full pricing service: none exists yet, so the only rate we have to
+// go on is whatever the client quoted at booking time.
+func RecalculateCost(currentDuration int, currentCost float64, newDuration int) float64 {
+	if currentDuration <= 0 {
+		return currentCost
+	}
+
+	ratePerMinute := currentCost / float64(currentDuration)
+	return ratePerMinute * float64(newDuration)
+}
@@ -0,0 +1,201 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	goredis "github.com/redis/go-redis/v9"
+
+	"github.com/holycan/smart-parking-system/lock"
+	"github.com/holycan/smart-parking-system/models"
+	"github.com/holycan/smart-parking-system/utils"
+)
+
+// ErrNotWaitlisted is returned by WaitlistPosition and LeaveWaitlist when
+// reservationID isn't (or is no longer) queued for parkingLotID.
+var ErrNotWaitlisted = errors.New("services: reservation not on waitlist")
+
+// waitlistKey is the Redis sorted-set key backing parkingLotID's waitlist.
+// The score is the Unix enqueue time, so ZRANK/ZPOPMIN give FIFO order for
+// free.
+func waitlistKey(parkingLotID string) string {
+	return "waitlist:" + parkingLotID
+}
+
+// waitlistMember packs the two things PromoteNext needs into a single
+// sorted-set member: reservationID to act on, userID to target the
+// promotion notification at.
+func waitlistMember(userID, reservationID string) string {
+	return userID + "|" + reservationID
+}
+
+func parseWaitlistMember(member string) (userID, reservationID string, ok bool) {
+	userID, reservationID, found := strings.Cut(member, "|")
+	return userID, reservationID, found
+}
+
+// JoinWaitlist queues reservationID for parkingLotID. ProcessCheckIn calls
+// this once the originally assigned spot and every alternate it tried are
+// unavailable, instead of failing the check-in outright. ZAddNX makes
+// re-joining idempotent - a retry of the same check-in job doesn't bump the
+// caller to the back of the queue behind themselves.
+func JoinWaitlist(ctx context.Context, parkingLotID, userID, reservationID string) error {
+	_, err := lock.RedisClient.ZAddNX(ctx, waitlistKey(parkingLotID), goredis.Z{
+		Score:  float64(time.Now().Unix()),
+		Member: waitlistMember(userID, reservationID),
+	}).Result()
+	return err
+}
+
+// findWaitlistMember looks up the sorted-set member userID queued under
+// parkingLotID with, regardless of which reservation it was queued for -
+// the /waitlist/:lotID endpoints only know the caller's userID, not the
+// reservationID PromoteNext later needs, so the member encodes both and
+// this is the other direction of that lookup.
+func findWaitlistMember(ctx context.Context, parkingLotID, userID string) (string, error) {
+	prefix := userID + "|"
+	var cursor uint64
+	for {
+		members, next, err := lock.RedisClient.ZScan(ctx, waitlistKey(parkingLotID), cursor, prefix+"*", 50).Result()
+		if err != nil {
+			return "", err
+		}
+		// ZScan returns a flat [member, score, member, score, ...] slice.
+		for i := 0; i+1 < len(members); i += 2 {
+			return members[i], nil
+		}
+		if next == 0 {
+			break
+		}
+		cursor = next
+	}
+	return "", ErrNotWaitlisted
+}
+
+// LeaveWaitlist removes userID's queued reservation from parkingLotID's
+// waitlist, for a driver who no longer wants to wait.
+func LeaveWaitlist(ctx context.Context, parkingLotID, userID string) error {
+	member, err := findWaitlistMember(ctx, parkingLotID, userID)
+	if err != nil {
+		return err
+	}
+	removed, err := lock.RedisClient.ZRem(ctx, waitlistKey(parkingLotID), member).Result()
+	if err != nil {
+		return err
+	}
+	if removed == 0 {
+		return ErrNotWaitlisted
+	}
+	return nil
+}
+
+// WaitlistPosition returns userID's 1-based position in parkingLotID's
+// waitlist.
+func WaitlistPosition(ctx context.Context, parkingLotID, userID string) (int64, error) {
+	member, err := findWaitlistMember(ctx, parkingLotID, userID)
+	if err != nil {
+		return 0, err
+	}
+	rank, err := lock.RedisClient.ZRank(ctx, waitlistKey(parkingLotID), member).Result()
+	if err != nil {
+		if errors.Is(err, goredis.Nil) {
+			return 0, ErrNotWaitlisted
+		}
+		return 0, err
+	}
+	return rank + 1, nil
+}
+
+// waitlistHoldTTL is how long PromoteNext's hold on a freed space protects
+// the promoted reservation from losing it to another driver's concurrent
+// check-in - long enough for a notified driver to act, short enough that an
+// unresponsive one doesn't sit on a spot nobody else can take.
+const waitlistHoldTTL = 2 * time.Minute
+
+// waitlistHoldKey is the Redis string key PromoteNext sets to hold spaceID
+// for one promoted reservation; occupySpotWithRetry (see
+// handlers.gate_handler) checks it before CASing the space for anyone else.
+func waitlistHoldKey(spaceID string) string {
+	return "waitlist-hold:" + spaceID
+}
+
+// HoldSpotForPromotion reserves spaceID for reservationID for
+// waitlistHoldTTL. Best-effort by design, same as the notification
+// PromoteNext already sends: a failed SET just means the promoted driver
+// gets no head start and falls back to ordinary first-come-first-served
+// allocation, not a reason to fail the promotion itself.
+func HoldSpotForPromotion(ctx context.Context, spaceID, reservationID string) error {
+	return lock.RedisClient.Set(ctx, waitlistHoldKey(spaceID), reservationID, waitlistHoldTTL).Err()
+}
+
+// SpotHeldForOther reports whether spaceID is currently held (via
+// HoldSpotForPromotion) for a promoted reservation other than reservationID.
+func SpotHeldForOther(ctx context.Context, spaceID, reservationID string) (bool, error) {
+	held, err := lock.RedisClient.Get(ctx, waitlistHoldKey(spaceID)).Result()
+	if errors.Is(err, goredis.Nil) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return held != reservationID, nil
+}
+
+// ClearSpotHold releases spaceID's promotion hold once it's been claimed (by
+// anyone) or the attempt's given up, so the TTL isn't the only thing that
+// eventually reopens it to general allocation.
+func ClearSpotHold(ctx context.Context, spaceID string) {
+	if err := lock.RedisClient.Del(ctx, waitlistHoldKey(spaceID)).Err(); err != nil {
+		log.Printf("services: failed to clear waitlist hold for space %s: %v", spaceID, err)
+	}
+}
+
+// PromoteNext pops the longest-waiting entry off parkingLotID's waitlist, if
+// any, holds spaceID for them via HoldSpotForPromotion, and notifies them
+// that it just opened up. The hold is what keeps this more than a
+// notification race: without it, any other driver's concurrent check-in -
+// including a brand-new walk-up with no waitlist history - could claim
+// spaceID through the ordinary CAS path before the promoted driver's client
+// even renders the notification. Still deliberately light-touch beyond
+// that: it doesn't touch the reservation's lifecycle state itself, so the
+// promoted driver's subsequent check-in still claims spaceID through the
+// ordinary ProcessCheckIn flow, CAS and all - just with everyone else locked
+// out of that CAS for the hold's duration. Called from CheckOutHandler and
+// the expiry watcher whenever a spot is freed.
+func PromoteNext(ctx context.Context, parkingLotID, spaceID string) error {
+	result, err := lock.RedisClient.ZPopMin(ctx, waitlistKey(parkingLotID), 1).Result()
+	if err != nil {
+		return err
+	}
+	if len(result) == 0 {
+		return nil
+	}
+
+	member, ok := result[0].Member.(string)
+	if !ok {
+		log.Printf("services: waitlist entry for lot %s had a non-string member, dropping it", parkingLotID)
+		return nil
+	}
+	userID, reservationID, ok := parseWaitlistMember(member)
+	if !ok {
+		log.Printf("services: malformed waitlist entry %q for lot %s, dropping it", member, parkingLotID)
+		return nil
+	}
+
+	if err := HoldSpotForPromotion(ctx, spaceID, reservationID); err != nil {
+		log.Printf("services: failed to hold space %s for promoted reservation %s: %v", spaceID, reservationID, err)
+	}
+
+	utils.WsManager.HandleNotificationUpdate(models.NotificationEvent{
+		UserID:         userID,
+		ReservationId:  reservationID,
+		ParkingSpaceId: spaceID,
+		Type:           "waitlist_promoted",
+		Message:        "A parking spot just opened up for you - check in now to claim it.",
+	})
+
+	return nil
+}
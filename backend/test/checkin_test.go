@@ -1,6 +1,8 @@
 package handlers_test
 
 import (
+	"context"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"sync"
@@ -13,6 +15,7 @@ import (
 	"github.com/holycan/smart-parking-system/database"
 	"github.com/holycan/smart-parking-system/handlers"
 	"github.com/holycan/smart-parking-system/lock"
+	"github.com/holycan/smart-parking-system/queue"
 	"github.com/holycan/smart-parking-system/services"
 )
 
@@ -24,6 +27,21 @@ func TestFullCheckInFlow(t *testing.T) {
 	router := gin.Default()
 	router.POST("/checkin/:id", handlers.CheckInHandler)
 
+	// CheckInHandler only enqueues a queue.CheckInJob and returns - the
+	// actual spot assignment this test is checking for happens in
+	// handlers.ProcessCheckIn, off a queue.Consume worker loop (see
+	// main.go's "worker" subcommand). Without one running here, every
+	// reservation's ParkingSpaceID stays empty and the race this test
+	// exists to catch (handlers.occupySpotWithRetry's CAS) never runs.
+	workerCtx, cancelWorkers := context.WithCancel(context.Background())
+	defer cancelWorkers()
+	for i, stream := range queue.Partitions() {
+		consumerName := fmt.Sprintf("test-worker-%d", i)
+		go queue.Consume(workerCtx, stream, consumerName, func(job queue.CheckInJob) error {
+			return handlers.ProcessCheckIn(job.UserID, job.ReservationID, job.OriginLat, job.OriginLng)
+		})
+	}
+
 	// Data user & reservasi
 	users := []struct {
 		userID      string
@@ -77,7 +95,7 @@ func TestFullCheckInFlow(t *testing.T) {
 				}
 				mu.Unlock()
 			} else {
-				t.Logf("⚠️ %s gagal diarahkan ke spot mana pun", userID)
+				t.Errorf("❌ %s gagal diarahkan ke spot mana pun", userID)
 			}
 		}(u.userID, u.reservation)
 	}
@@ -0,0 +1,35 @@
+package utils
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/holycan/smart-parking-system/database/errs"
+)
+
+// RespondDBError classifies a database error via errs.Classify and writes
+// the appropriate status code and a consistent {code, message, details}
+// JSON body. Unrecognized errors fall back to a generic 500.
+func RespondDBError(c *gin.Context, err error) {
+	classified := errs.Classify(err)
+
+	var dupErr *errs.DuplicateKeyError
+	var fkErr *errs.ForeignKeyViolationError
+
+	switch {
+	case errors.Is(classified, errs.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"code": "NOT_FOUND", "message": "Resource not found", "details": nil})
+	case errors.As(classified, &dupErr):
+		c.JSON(http.StatusConflict, gin.H{"code": "DUPLICATE_KEY", "message": "Resource already exists", "details": dupErr.Constraint})
+	case errors.As(classified, &fkErr):
+		c.JSON(http.StatusBadRequest, gin.H{"code": "FOREIGN_KEY_VIOLATION", "message": "Referenced resource does not exist", "details": fkErr.Constraint})
+	case errors.Is(classified, errs.ErrCheckViolation):
+		c.JSON(http.StatusBadRequest, gin.H{"code": "CHECK_VIOLATION", "message": "Request violates a data constraint", "details": nil})
+	case errors.Is(classified, errs.ErrSerializationFailure):
+		c.JSON(http.StatusConflict, gin.H{"code": "SERIALIZATION_FAILURE", "message": "Transaction conflict, please retry", "details": nil})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"code": "INTERNAL_ERROR", "message": "An unexpected error occurred", "details": nil})
+	}
+}
@@ -31,9 +31,6 @@ var (
 	// Ngrok URL
 	NgrokURL string
 
-	// Channel untuk buffer request parkir
-	ParkingQueue = make(chan map[string]interface{}, 100)
-
 	// Semaphore untuk membatasi jumlah akses paralel ke spot parkir
 	Semaphore = make(chan struct{}, 50) // Max 5 permintaan yang diproses bersamaan
 )
@@ -0,0 +1,124 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/holycan/smart-parking-system/lock"
+)
+
+const idempotencyKeyTTL = 24 * time.Hour
+
+type idempotencyRecord struct {
+	ResponseStatus int             `json:"response_status"`
+	ResponseBody   json.RawMessage `json:"response_body"`
+	CreatedAt      time.Time       `json:"created_at"`
+}
+
+// responseRecorder buffers the handler's response so it can be replayed
+// verbatim to a future duplicate request.
+type responseRecorder struct {
+	gin.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (w *responseRecorder) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *responseRecorder) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// Idempotency returns a Gin middleware that deduplicates POST requests
+// carrying an Idempotency-Key header. The key is hashed together with the
+// user, method, path, and request body so the same key can't be replayed
+// against a different request. A short redsync lock serializes in-flight
+// duplicates so a slow first request doesn't let a retry through as a
+// second write.
+func Idempotency() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		idempotencyKey := c.GetHeader("Idempotency-Key")
+		if idempotencyKey == "" {
+			c.Next()
+			return
+		}
+
+		bodyBytes, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read request body"})
+			c.Abort()
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(bodyBytes))
+
+		userID, _ := c.Get("userId")
+		bodyHash := sha256.Sum256(bodyBytes)
+		redisKey := fmt.Sprintf("idempotency:%v:%s:%s:%s:%x", userID, c.Request.Method, c.FullPath(), idempotencyKey, bodyHash)
+
+		ctx := context.Background()
+
+		if cached, err := lock.RedisClient.Get(ctx, redisKey).Result(); err == nil {
+			replayRecord(c, cached)
+			c.Abort()
+			return
+		}
+
+		mutex, err := lock.AcquireLock("idempotency-lock:"+redisKey, 10*time.Second)
+		if err != nil {
+			c.JSON(http.StatusConflict, gin.H{"error": "Duplicate request already in flight, please retry shortly"})
+			c.Abort()
+			return
+		}
+		defer lock.ReleaseLock(mutex)
+
+		// Re-check now that we hold the lock: the in-flight request may
+		// have finished and written its result while we were waiting.
+		if cached, err := lock.RedisClient.Get(ctx, redisKey).Result(); err == nil {
+			replayRecord(c, cached)
+			c.Abort()
+			return
+		}
+
+		recorder := &responseRecorder{ResponseWriter: c.Writer, status: http.StatusOK}
+		c.Writer = recorder
+
+		c.Next()
+
+		record := idempotencyRecord{
+			ResponseStatus: recorder.status,
+			ResponseBody:   json.RawMessage(recorder.body.Bytes()),
+			CreatedAt:      time.Now(),
+		}
+
+		encoded, err := json.Marshal(record)
+		if err != nil {
+			return
+		}
+
+		if err := lock.RedisClient.Set(ctx, redisKey, encoded, idempotencyKeyTTL).Err(); err != nil {
+			return
+		}
+	}
+}
+
+func replayRecord(c *gin.Context, cached string) {
+	var record idempotencyRecord
+	if err := json.Unmarshal([]byte(cached), &record); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to replay cached response"})
+		return
+	}
+
+	c.Data(record.ResponseStatus, "application/json; charset=utf-8", record.ResponseBody)
+}
@@ -0,0 +1,120 @@
+// Package broker lets ws.WebSocketManager's fan-out survive running behind
+// multiple API replicas. Without it, a Handle* call only reaches clients
+// whose socket happens to be registered on the same pod, since
+// WebSocketManager.clients is an in-process map. Broker publishes every
+// such event to a Redis Pub/Sub channel; every replica's Init subscribes
+// to that same channel and replays events published by other replicas
+// back through its own Dispatcher, so a reservation update made on pod A
+// still reaches a client connected to pod B.
+//
+// Pub/Sub rather than Streams: these events are already best-effort (see
+// WebSocketManager.broadcastToTopic's non-blocking send, which drops a
+// message rather than block a slow client), and a client that missed
+// messages during a reconnect already has a durable way to catch up via
+// WebSocketManager.Resume's per-topic replay buffer. There's nothing
+// left for this layer to guarantee that durable Streams delivery would
+// add.
+package broker
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+
+	"github.com/google/uuid"
+	goredis "github.com/redis/go-redis/v9"
+)
+
+// channel is the single Redis Pub/Sub channel every replica publishes to
+// and subscribes on. Events are multiplexed onto it by envelope.Kind
+// rather than given one channel per kind, since Redis Pub/Sub channels
+// aren't cheap to fan a growing set of event kinds out across.
+const channel = "ws:broker:events"
+
+// Dispatcher drives a decoded remote event through the local
+// WebSocketManager exactly as if it had originated on this replica - see
+// ws.WebSocketManager.Dispatch.
+type Dispatcher func(kind string, payload json.RawMessage)
+
+// envelope is what actually crosses the wire on channel.
+type envelope struct {
+	// Origin identifies the publishing replica, so its own subscriber
+	// goroutine can ignore the message instead of delivering it to its
+	// local clients a second time - Handle* already did that directly.
+	Origin  string          `json:"origin"`
+	Kind    string          `json:"kind"`
+	Payload json.RawMessage `json:"payload"`
+}
+
+// Broker publishes WebSocketManager events to Redis and relays events
+// published by other replicas back into this process via a Dispatcher.
+type Broker struct {
+	client *goredis.Client
+	origin string
+	sub    *goredis.PubSub
+}
+
+// Current is the process-wide Broker that ws.WebSocketManager's Handle*
+// methods publish through. It defaults to an inert Broker, so Publish is
+// always safe to call even before Init runs (e.g. a single-replica
+// deployment that never calls Init at all) - the same way tracing.Current
+// is always safe to start a span on.
+var Current = &Broker{}
+
+// Init connects Current to client, tags it with a fresh per-process
+// origin ID, and starts the subscriber goroutine that relays other
+// replicas' events to dispatch. Safe to call once at startup.
+func Init(client *goredis.Client, dispatch Dispatcher) {
+	Current.client = client
+	Current.origin = uuid.New().String()
+	Current.sub = client.Subscribe(context.Background(), channel)
+
+	go func() {
+		for msg := range Current.sub.Channel() {
+			var env envelope
+			if err := json.Unmarshal([]byte(msg.Payload), &env); err != nil {
+				log.Printf("broker: dropping unparseable message: %v", err)
+				continue
+			}
+			if env.Origin == Current.origin {
+				continue
+			}
+			dispatch(env.Kind, env.Payload)
+		}
+	}()
+}
+
+// Stop closes the subscriber goroutine started by Init. A no-op before
+// Init has run.
+func Stop() {
+	if Current.sub != nil {
+		Current.sub.Close()
+	}
+}
+
+// Publish marshals payload, tags it with kind and this process's origin
+// ID, and publishes it on channel for every other replica's subscriber
+// goroutine to relay through its own Dispatcher. A no-op before Init has
+// run, so ws.WebSocketManager's Handle* methods don't need an
+// Init-happened check of their own.
+func (b *Broker) Publish(kind string, payload interface{}) {
+	if b.client == nil {
+		return
+	}
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		log.Printf("broker: failed to marshal %s payload: %v", kind, err)
+		return
+	}
+
+	env, err := json.Marshal(envelope{Origin: b.origin, Kind: kind, Payload: data})
+	if err != nil {
+		log.Printf("broker: failed to marshal %s envelope: %v", kind, err)
+		return
+	}
+
+	if err := b.client.Publish(context.Background(), channel, env).Err(); err != nil {
+		log.Printf("broker: failed to publish %s: %v", kind, err)
+	}
+}
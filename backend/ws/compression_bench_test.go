@@ -0,0 +1,66 @@
+package ws
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/json"
+	"testing"
+
+	"github.com/holycan/smart-parking-system/models"
+)
+
+// lotSnapshot builds n ParkingEvents shaped like a full lot's worth of
+// spaces, for benchmarking how much permessage-deflate is worth on a
+// payload this repetitive.
+func lotSnapshot(n int) []models.ParkingEvent {
+	events := make([]models.ParkingEvent, n)
+	for i := range events {
+		events[i] = models.ParkingEvent{
+			ParkingLotID: "lot-1",
+			SpaceID:      "space-1",
+			IsOccupied:   i%2 == 0,
+			IsPaid:       i%3 == 0,
+			Timestamp:    1700000000,
+		}
+	}
+	return events
+}
+
+// BenchmarkSnapshotRaw measures marshaling a 500-space lot snapshot with
+// no compression, for comparison against BenchmarkSnapshotDeflate.
+func BenchmarkSnapshotRaw(b *testing.B) {
+	events := lotSnapshot(500)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := json.Marshal(events); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkSnapshotDeflate measures the same snapshot additionally
+// flate-compressed at config.WSConfig's default CompressionLevel, which
+// is roughly what a client receives once permessage-deflate is
+// negotiated - see Client.writePump.
+func BenchmarkSnapshotDeflate(b *testing.B) {
+	events := lotSnapshot(500)
+	raw, err := json.Marshal(events)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var buf bytes.Buffer
+		fw, err := flate.NewWriter(&buf, defaultCompressionLevel)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if _, err := fw.Write(raw); err != nil {
+			b.Fatal(err)
+		}
+		if err := fw.Close(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
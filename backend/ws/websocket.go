@@ -1,17 +1,25 @@
 package ws
 
 import (
+	"bytes"
+	"context"
+	"encoding/base64"
 	"encoding/json"
 	"log"
 	"net/http"
 	"sync"
 	"time"
 
+	"github.com/andybalholm/brotli"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
 
+	"github.com/holycan/smart-parking-system/dedup"
+	"github.com/holycan/smart-parking-system/internal/metrics"
+	"github.com/holycan/smart-parking-system/middleware"
 	"github.com/holycan/smart-parking-system/models"
+	"github.com/holycan/smart-parking-system/ws/broker"
 )
 
 // Client represents a connected WebSocket client
@@ -21,12 +29,136 @@ type Client struct {
 	Send         chan []byte
 	UserID       string
 	ParkingSpace map[string]models.ParkingEvent
+
+	// compressionThreshold is writePump's copy of
+	// config.WSConfig.CompressionThreshold, set at construction time so
+	// writePump doesn't need to import config - see NewWebSocketManager.
+	compressionThreshold int
+}
+
+// deadlineTimer is a resettable timeout signal, adapted from the pattern
+// netstack's gonet adapter uses for socket read/write deadlines: a single
+// *time.Timer whose firing closes a channel every waiter selects on, with
+// reset swapping in a fresh timer and channel instead of stacking a new
+// live timer on top of whichever one is still pending.
+type deadlineTimer struct {
+	mu      sync.Mutex
+	timer   *time.Timer
+	expired chan struct{}
+}
+
+func newDeadlineTimer() *deadlineTimer {
+	return &deadlineTimer{expired: make(chan struct{})}
+}
+
+// done returns the channel that closes once the current deadline fires.
+func (d *deadlineTimer) done() <-chan struct{} {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.expired
+}
+
+// reset arms the timer to fire after timeout, replacing whatever deadline
+// (and done channel) an earlier reset call set up.
+func (d *deadlineTimer) reset(timeout time.Duration) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.timer != nil {
+		d.timer.Stop()
+	}
+	expired := make(chan struct{})
+	d.expired = expired
+	d.timer = time.AfterFunc(timeout, func() {
+		close(expired)
+	})
+}
+
+// SendCtx enqueues msg onto the client's Send buffer, waiting until ctx is
+// done - including, if ctx carries a deadline, a deadlineTimer armed for
+// it - instead of giving up the instant the buffer is full. Returns
+// ctx.Err() if msg couldn't be enqueued before ctx ended.
+func (client *Client) SendCtx(ctx context.Context, msg []byte) error {
+	select {
+	case client.Send <- msg:
+		return nil
+	default:
+	}
+
+	timer := newDeadlineTimer()
+	if deadline, ok := ctx.Deadline(); ok {
+		timer.reset(time.Until(deadline))
+	}
+
+	select {
+	case client.Send <- msg:
+		return nil
+	case <-timer.done():
+		return context.DeadlineExceeded
+	case <-ctx.Done():
+		return ctx.Err()
+	}
 }
 
-// WebSocketMessage represents a message sent over WebSocket
+// WebSocketMessage represents a message sent over WebSocket. Topic and Seq
+// are set on every message a topicLog assigns a sequence number to - see
+// WebSocketManager.publish - and omitted on messages that predate the
+// topic model and haven't been migrated onto it. Encoding is set only on
+// messages published via WebSocketManager.publishBulk, and names the
+// encoding Payload is wrapped in (currently only "br") on top of the
+// permessage-deflate the transport itself may also apply - see publishBulk.
 type WebSocketMessage struct {
-	Type    string      `json:"type"`
-	Payload interface{} `json:"payload"`
+	Type     string      `json:"type"`
+	Topic    string      `json:"topic,omitempty"`
+	Seq      uint64      `json:"seq,omitempty"`
+	Encoding string      `json:"encoding,omitempty"`
+	Payload  interface{} `json:"payload"`
+}
+
+// Topic identifies a WebSocketManager subscription stream a client can ask
+// to receive by sending a SUBSCRIBE control message naming it. Scoping
+// fan-out to the topics a client actually asked for (one lot, one space)
+// is what lets broadcastToTopic avoid sending a mobile client watching a
+// single lot every update for every space in the system.
+type Topic string
+
+// ParkingLotTopic is the stream of PARKING_UPDATE/GATE_EVENT messages for
+// everything happening at parking lot lotID.
+func ParkingLotTopic(lotID string) Topic {
+	return Topic("parking_lot:" + lotID)
+}
+
+// SpaceTopic is the stream of PARKING_UPDATE/NOTIFICATION_UPDATE messages
+// scoped to one parking space.
+func SpaceTopic(spaceID string) Topic {
+	return Topic("space:" + spaceID)
+}
+
+// UserTopic is the stream of messages addressed to one user. broadcastToUser
+// predates the topic model and still delivers these directly rather than
+// through topics, but the topic name is defined here so SUBSCRIBE/UNSUBSCRIBE
+// callers have a stable name for it if that changes.
+func UserTopic(userID string) Topic {
+	return Topic("user:" + userID)
+}
+
+// GateTopic is the stream of GATE_EVENT messages for one parking lot's gate.
+func GateTopic(lotID string) Topic {
+	return Topic("gate:" + lotID)
+}
+
+// bufferedMessage is one encoded WebSocketMessage held in a topicLog for
+// replay.
+type bufferedMessage struct {
+	Seq  uint64
+	Data []byte
+}
+
+// topicLog is a topic's sequence counter and a bounded ring of its most
+// recent messages, for Client.readPump's RESUME control frame to replay
+// from after a reconnect.
+type topicLog struct {
+	nextSeq  uint64
+	messages []bufferedMessage
 }
 
 // WebSocketManager handles WebSocket connections and broadcasts
@@ -63,30 +195,100 @@ type WebSocketManager struct {
 
 	// Map to track interest clients for each parking space
 	interestClients map[string]map[string]*models.ReservationInfo // parkingSpaceID -> userID -> reservationInfo
+
+	// topics holds each topic's current subscribers, topic -> clientID ->
+	// client. Protected by clientsMutex alongside clients, since
+	// Subscribe/Unsubscribe/broadcastToTopic touch both maps together.
+	topics map[Topic]map[string]*Client
+
+	// topicLogs holds each topic's sequence counter and replay buffer,
+	// protected by its own mutex since publish() runs from the manager's
+	// Start loop while Resume() is called from Client.readPump goroutines.
+	topicLogs      map[Topic]*topicLog
+	topicLogsMutex sync.Mutex
+
+	// replayBufferSize bounds how many messages topicLog.messages keeps
+	// per topic - see config.WSConfig.ReplayBufferSize.
+	replayBufferSize int
+
+	// compressionLevel is the flate level new connections negotiate
+	// permessage-deflate at - see config.WSConfig.CompressionLevel.
+	compressionLevel int
+
+	// compressionThreshold is handed to every Client this manager creates -
+	// see Client.compressionThreshold.
+	compressionThreshold int
+
+	// frameLimiter bounds inbound control frames per connection - see
+	// Client.readPump and config.RateLimitConfig.WSFramesPerSecond. Nil is
+	// treated as unlimited, so a zero-value WebSocketManager (e.g. in an
+	// older test) doesn't start rejecting frames.
+	frameLimiter middleware.Limiter
+
+	// dedup recognizes an event this manager has already enqueued -
+	// a device retry or, once ws/broker relays it from another replica,
+	// the same event arriving twice - so every Handle* entry point can
+	// drop the repeat before it reaches a client twice.
+	dedup *dedup.Deduper
 }
 
-// WebSocket connection upgrader
+// WebSocket connection upgrader. EnableCompression lets the client
+// negotiate the permessage-deflate extension on upgrade; whether a given
+// frame actually gets deflated is still up to writePump calling
+// Conn.EnableWriteCompression per message - see Client.writePump.
 var upgrader = websocket.Upgrader{
 	ReadBufferSize:  1024,
 	WriteBufferSize: 1024,
 	CheckOrigin: func(r *http.Request) bool {
 		return true // Allow all origins in development
 	},
+	EnableCompression: true,
 }
 
-// NewWebSocketManager creates a new WebSocketManager
-func NewWebSocketManager() *WebSocketManager {
+// defaultReplayBufferSize is used when replayBufferSize isn't positive, so
+// a zero-value NewWebSocketManager call (as in an older test) still keeps
+// some replay history instead of none.
+const defaultReplayBufferSize = 1024
+
+// defaultCompressionLevel mirrors config.WSConfig's own default, used when
+// NewWebSocketManager is called with a non-positive level (e.g. an older
+// test that hasn't loaded config at all).
+const defaultCompressionLevel = 5
+
+// defaultDedupEventsPerWindow sizes the bloom filters backing
+// WebSocketManager's dedup; see dedup.New.
+const defaultDedupEventsPerWindow = 10000
+
+// NewWebSocketManager creates a new WebSocketManager. replayBufferSize,
+// compressionLevel and compressionThreshold come from config.Get().WS.
+// frameLimiter bounds inbound frames per connection - see
+// middleware.NewLimiter and config.RateLimitConfig; nil disables the
+// check entirely.
+func NewWebSocketManager(replayBufferSize, compressionLevel, compressionThreshold int, frameLimiter middleware.Limiter) *WebSocketManager {
+	if replayBufferSize <= 0 {
+		replayBufferSize = defaultReplayBufferSize
+	}
+	if compressionLevel <= 0 {
+		compressionLevel = defaultCompressionLevel
+	}
 	return &WebSocketManager{
-		clients:             make(map[string]*Client),
-		reservationAdded:    make(chan models.Reservation, 1000),
-		ReservationUpdates:  make(chan models.Reservation, 1000),
-		parkingSpaceUpdates: make(chan models.ParkingEvent, 1000),
-		notificationUpdates: make(chan models.NotificationEvent, 100),
-		gateEvents:          make(chan models.GateEvent, 100),
-		register:            make(chan *Client),
-		unregister:          make(chan *Client),
-		shutdown:            make(chan struct{}),
-		interestClients:     make(map[string]map[string]*models.ReservationInfo),
+		clients:              make(map[string]*Client),
+		reservationAdded:     make(chan models.Reservation, 1000),
+		ReservationUpdates:   make(chan models.Reservation, 1000),
+		parkingSpaceUpdates:  make(chan models.ParkingEvent, 1000),
+		notificationUpdates:  make(chan models.NotificationEvent, 100),
+		gateEvents:           make(chan models.GateEvent, 100),
+		register:             make(chan *Client),
+		unregister:           make(chan *Client),
+		shutdown:             make(chan struct{}),
+		interestClients:      make(map[string]map[string]*models.ReservationInfo),
+		topics:               make(map[Topic]map[string]*Client),
+		topicLogs:            make(map[Topic]*topicLog),
+		replayBufferSize:     replayBufferSize,
+		compressionLevel:     compressionLevel,
+		compressionThreshold: compressionThreshold,
+		frameLimiter:         frameLimiter,
+		dedup:                dedup.New(defaultDedupEventsPerWindow),
 	}
 }
 
@@ -133,14 +335,18 @@ func (manager *WebSocketManager) HandleWebSocket(c *gin.Context) {
 		log.Printf("Error upgrading to WebSocket: %v", err)
 		return
 	}
+	if err := conn.SetCompressionLevel(manager.compressionLevel); err != nil {
+		log.Printf("Error setting WebSocket compression level: %v", err)
+	}
 
 	clientID := uuid.New().String()
 	client := &Client{
-		ID:           clientID,
-		Conn:         conn,
-		Send:         make(chan []byte, 256),
-		UserID:       userID,
-		ParkingSpace: make(map[string]models.ParkingEvent),
+		ID:                   clientID,
+		Conn:                 conn,
+		Send:                 make(chan []byte, 256),
+		UserID:               userID,
+		ParkingSpace:         make(map[string]models.ParkingEvent),
+		compressionThreshold: manager.compressionThreshold,
 	}
 
 	// Register new client
@@ -151,61 +357,320 @@ func (manager *WebSocketManager) HandleWebSocket(c *gin.Context) {
 	go client.writePump()
 }
 
+// RegisterNotificationClient registers a client with no underlying
+// WebSocket connection, so non-WebSocket consumers (the gRPC
+// NotificationStream RPC) can receive the same per-user broadcasts as
+// WebSocket clients without duplicating the pub-sub wiring below. The
+// caller is responsible for draining Send and calling UnregisterClient
+// when done.
+func (manager *WebSocketManager) RegisterNotificationClient(userID string) *Client {
+	client := &Client{
+		ID:     uuid.New().String(),
+		Send:   make(chan []byte, 256),
+		UserID: userID,
+	}
+	manager.register <- client
+	return client
+}
+
+// UnregisterClient removes client, mirroring what readPump does when a
+// WebSocket connection closes.
+func (manager *WebSocketManager) UnregisterClient(client *Client) {
+	manager.unregister <- client
+}
+
 // registerClient adds a new client to the manager
 func (manager *WebSocketManager) registerClient(client *Client) {
 	manager.clientsMutex.Lock()
 	manager.clients[client.ID] = client
 	manager.clientsMutex.Unlock()
+	metrics.WSConnections.Inc()
 
 	log.Printf("Client registered: %s (User: %s)", client.ID, client.UserID)
 }
 
-// unregisterClient removes a client from the manager
+// unregisterClient removes a client from the manager, sweeping every topic
+// it subscribed to so stale entries don't pin the client (and its closed
+// Send channel) in manager.topics forever.
 func (manager *WebSocketManager) unregisterClient(client *Client) {
 	manager.clientsMutex.Lock()
 	if _, ok := manager.clients[client.ID]; ok {
 		delete(manager.clients, client.ID)
 		close(client.Send)
+		metrics.WSConnections.Dec()
+	}
+	for topic, subscribers := range manager.topics {
+		if _, ok := subscribers[client.ID]; ok {
+			delete(subscribers, client.ID)
+			if len(subscribers) == 0 {
+				delete(manager.topics, topic)
+			}
+		}
 	}
 	manager.clientsMutex.Unlock()
 
 	log.Printf("Client unregistered: %s (User: %s)", client.ID, client.UserID)
 }
 
-// broadcastToUser sends a message to all connections of a specific user
-func (manager *WebSocketManager) broadcastToUser(userID string, message []byte) {
-	manager.clientsMutex.RLock()
-	defer manager.clientsMutex.RUnlock()
+// Subscribe adds client to topic's subscriber set. Safe to call from any
+// goroutine - Client.readPump calls it directly on a SUBSCRIBE control
+// message, outside the manager's own Start loop.
+func (manager *WebSocketManager) Subscribe(client *Client, topic Topic) {
+	manager.clientsMutex.Lock()
+	defer manager.clientsMutex.Unlock()
+
+	if manager.topics[topic] == nil {
+		manager.topics[topic] = make(map[string]*Client)
+	}
+	manager.topics[topic][client.ID] = client
+}
 
+// Unsubscribe removes client from topic's subscriber set, dropping the
+// topic entirely once its last subscriber leaves.
+func (manager *WebSocketManager) Unsubscribe(client *Client, topic Topic) {
+	manager.clientsMutex.Lock()
+	defer manager.clientsMutex.Unlock()
+
+	subscribers, ok := manager.topics[topic]
+	if !ok {
+		return
+	}
+	delete(subscribers, client.ID)
+	if len(subscribers) == 0 {
+		delete(manager.topics, topic)
+	}
+}
+
+// broadcastDeadline bounds how long broadcastToUser/broadcastToTopic/
+// BroadcastToAll give a slow client's Send buffer to drain before giving
+// up on it, instead of disconnecting on the very first send that finds
+// the buffer full.
+const broadcastDeadline = 2 * time.Second
+
+// broadcastToUser sends a message to all connections of a specific user.
+// msgType is the WebSocketMessage.Type already encoded into message, passed
+// separately so this doesn't have to re-decode it just to label the metric.
+// ctx bounds how long a slow client is given to make room - see drainSlow.
+func (manager *WebSocketManager) broadcastToUser(ctx context.Context, userID string, message []byte, msgType string) {
+	manager.clientsMutex.RLock()
+	var slow []*Client
 	for _, client := range manager.clients {
-		if client.UserID == userID {
-			select {
-			case client.Send <- message:
-			default:
-				close(client.Send)
-				delete(manager.clients, client.ID)
-			}
+		if client.UserID != userID {
+			continue
+		}
+		select {
+		case client.Send <- message:
+			metrics.WSMessagesSent.WithLabelValues(msgType).Inc()
+		default:
+			slow = append(slow, client)
+		}
+	}
+	manager.clientsMutex.RUnlock()
+
+	manager.drainSlow(ctx, slow, message, msgType)
+}
+
+// publish assigns the next sequence number for topic, marshals payload into
+// a WebSocketMessage carrying it, and records the encoded result in
+// topic's replay buffer before returning it for the caller to deliver
+// (via broadcastToTopic or broadcastToUser). Returns nil if payload
+// doesn't marshal.
+func (manager *WebSocketManager) publish(topic Topic, msgType string, payload interface{}) []byte {
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		log.Printf("Error marshaling %s payload for topic %s: %v", msgType, topic, err)
+		return nil
+	}
+	return manager.publishEncoded(topic, msgType, "", raw)
+}
+
+// publishBulk brotli-compresses payload's JSON encoding before publishing
+// it, for large, highly repetitive snapshots - e.g. every space in a lot,
+// sent on SUBSCRIBE - where the compression ratio is worth the extra CPU
+// on top of whatever permessage-deflate the transport already applies.
+// The client must base64-decode WebSocketMessage.Payload and
+// brotli-decompress the result before JSON-parsing it; see
+// WebSocketMessage.Encoding.
+func (manager *WebSocketManager) publishBulk(topic Topic, msgType string, payload interface{}) []byte {
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		log.Printf("Error marshaling %s bulk payload for topic %s: %v", msgType, topic, err)
+		return nil
+	}
+
+	var buf bytes.Buffer
+	bw := brotli.NewWriterLevel(&buf, brotli.DefaultCompression)
+	if _, err := bw.Write(raw); err != nil {
+		log.Printf("Error brotli-compressing %s payload for topic %s: %v", msgType, topic, err)
+		return nil
+	}
+	if err := bw.Close(); err != nil {
+		log.Printf("Error closing brotli writer for %s payload on topic %s: %v", msgType, topic, err)
+		return nil
+	}
+
+	encoded, err := json.Marshal(base64.StdEncoding.EncodeToString(buf.Bytes()))
+	if err != nil {
+		log.Printf("Error encoding %s bulk payload for topic %s: %v", msgType, topic, err)
+		return nil
+	}
+
+	return manager.publishEncoded(topic, msgType, "br", encoded)
+}
+
+// publishEncoded does publish and publishBulk's shared sequencing,
+// envelope marshaling, and replay-buffer bookkeeping. rawPayload is
+// already-JSON-encoded (json.RawMessage satisfies json.Marshaler, so it
+// drops straight into WebSocketMessage.Payload without double-encoding).
+func (manager *WebSocketManager) publishEncoded(topic Topic, msgType, encoding string, rawPayload json.RawMessage) []byte {
+	manager.topicLogsMutex.Lock()
+	tlog := manager.topicLogs[topic]
+	if tlog == nil {
+		tlog = &topicLog{}
+		manager.topicLogs[topic] = tlog
+	}
+	tlog.nextSeq++
+	seq := tlog.nextSeq
+	manager.topicLogsMutex.Unlock()
+
+	message := WebSocketMessage{Type: msgType, Topic: string(topic), Seq: seq, Encoding: encoding, Payload: rawPayload}
+	data, err := json.Marshal(message)
+	if err != nil {
+		log.Printf("Error marshaling %s message for topic %s: %v", msgType, topic, err)
+		return nil
+	}
+
+	manager.topicLogsMutex.Lock()
+	tlog.messages = append(tlog.messages, bufferedMessage{Seq: seq, Data: data})
+	if len(tlog.messages) > manager.replayBufferSize {
+		tlog.messages = tlog.messages[len(tlog.messages)-manager.replayBufferSize:]
+	}
+	manager.topicLogsMutex.Unlock()
+
+	return data
+}
+
+// Resume returns the buffered messages for topic with a sequence greater
+// than lastSeq, for Client.readPump to replay to a reconnecting client.
+// ok is false when lastSeq is older than the oldest buffered entry, i.e.
+// the client missed more messages than replayBufferSize retains and needs
+// a full REST refetch instead of a replay.
+func (manager *WebSocketManager) Resume(topic Topic, lastSeq uint64) (missed [][]byte, ok bool) {
+	manager.topicLogsMutex.Lock()
+	defer manager.topicLogsMutex.Unlock()
+
+	tlog := manager.topicLogs[topic]
+	if tlog == nil || len(tlog.messages) == 0 {
+		return nil, true
+	}
+
+	oldest := tlog.messages[0].Seq
+	if lastSeq != 0 && lastSeq < oldest-1 {
+		return nil, false
+	}
+
+	for _, m := range tlog.messages {
+		if m.Seq > lastSeq {
+			missed = append(missed, m.Data)
 		}
 	}
+	return missed, true
 }
 
-// broadcastToAll sends a message to all connected clients
-func (manager *WebSocketManager) BroadcastToAll(message []byte) {
+// broadcastToTopic sends a message to every client subscribed to topic.
+// See broadcastToUser for why msgType is passed alongside the
+// already-encoded message, and for what ctx bounds.
+func (manager *WebSocketManager) broadcastToTopic(ctx context.Context, topic Topic, message []byte, msgType string) {
 	manager.clientsMutex.RLock()
-	defer manager.clientsMutex.RUnlock()
+	var slow []*Client
+	for _, client := range manager.topics[topic] {
+		select {
+		case client.Send <- message:
+			metrics.WSMessagesSent.WithLabelValues(msgType).Inc()
+		default:
+			slow = append(slow, client)
+		}
+	}
+	manager.clientsMutex.RUnlock()
 
+	manager.drainSlow(ctx, slow, message, msgType)
+}
+
+// BroadcastToAll sends a message to all connected clients. See
+// broadcastToUser for why msgType is passed alongside the already-encoded
+// message, and for what ctx bounds.
+func (manager *WebSocketManager) BroadcastToAll(ctx context.Context, message []byte, msgType string) {
+	manager.clientsMutex.RLock()
+	var slow []*Client
 	for _, client := range manager.clients {
 		select {
 		case client.Send <- message:
+			metrics.WSMessagesSent.WithLabelValues(msgType).Inc()
 		default:
-			close(client.Send)
-			delete(manager.clients, client.ID)
+			slow = append(slow, client)
 		}
 	}
+	manager.clientsMutex.RUnlock()
+
+	manager.drainSlow(ctx, slow, message, msgType)
+}
+
+// drainSlow gives every client in slow until ctx is done to make room in
+// its Send buffer for message - collected as a batch by
+// broadcastToUser/broadcastToTopic/BroadcastToAll instead of each
+// disconnecting a client the instant its buffer is found full - and
+// disconnects whichever still haven't by the time ctx fires.
+func (manager *WebSocketManager) drainSlow(ctx context.Context, slow []*Client, message []byte, msgType string) {
+	if len(slow) == 0 {
+		return
+	}
+
+	var wg sync.WaitGroup
+	var deadMu sync.Mutex
+	var dead []*Client
+	for _, client := range slow {
+		wg.Add(1)
+		go func(client *Client) {
+			defer wg.Done()
+			if err := client.SendCtx(ctx, message); err != nil {
+				deadMu.Lock()
+				dead = append(dead, client)
+				deadMu.Unlock()
+				return
+			}
+			metrics.WSMessagesSent.WithLabelValues(msgType).Inc()
+		}(client)
+	}
+	wg.Wait()
+
+	if len(dead) == 0 {
+		return
+	}
+
+	manager.clientsMutex.Lock()
+	defer manager.clientsMutex.Unlock()
+	for _, client := range dead {
+		if _, ok := manager.clients[client.ID]; !ok {
+			continue // already disconnected by another broadcast's drainSlow
+		}
+		close(client.Send)
+		delete(manager.clients, client.ID)
+		metrics.WSConnections.Dec()
+	}
 }
 
 // handleParkingUpdate processes parking status updates
 func (manager *WebSocketManager) HandleParkingUpdate(update models.ParkingEvent) {
+	const msgType = "PARKING_UPDATE"
+	if manager.dedup.Seen(dedup.Fingerprint(msgType, update.SpaceID, "", time.Unix(update.Timestamp, 0))) {
+		metrics.WSDuplicateEventsSuppressed.WithLabelValues(msgType).Inc()
+		return
+	}
+
+	// Published before the local enqueue so every other replica's
+	// broker-subscriber goroutine sees it too - see ws/broker.
+	broker.Current.Publish("PARKING_UPDATE", update)
+
 	select {
 	case manager.parkingSpaceUpdates <- update:
 		log.Println("Sent parking update")
@@ -216,23 +681,36 @@ func (manager *WebSocketManager) HandleParkingUpdate(update models.ParkingEvent)
 
 // handleParkingUpdate processes parking status updates
 func (manager *WebSocketManager) parkingUpdate(update models.ParkingEvent) {
-	message := WebSocketMessage{
-		Type:    "PARKING_UPDATE",
-		Payload: update,
+	const msgType = "PARKING_UPDATE"
+
+	// Scoped to the lot and the individual space, so a client watching one
+	// lot doesn't also receive every other lot's space updates. Each topic
+	// gets its own sequence number, so the two publish calls below are
+	// independent replay streams.
+	ctx, cancel := context.WithTimeout(context.Background(), broadcastDeadline)
+	defer cancel()
+
+	lotTopic := ParkingLotTopic(update.ParkingLotID)
+	if data := manager.publish(lotTopic, msgType, update); data != nil {
+		manager.broadcastToTopic(ctx, lotTopic, data, msgType)
 	}
 
-	data, err := json.Marshal(message)
-	if err != nil {
-		log.Printf("Error marshaling parking update: %v", err)
-		return
+	spaceTopic := SpaceTopic(update.SpaceID)
+	if data := manager.publish(spaceTopic, msgType, update); data != nil {
+		manager.broadcastToTopic(ctx, spaceTopic, data, msgType)
 	}
-
-	// Broadcast to all clients
-	manager.BroadcastToAll(data)
 }
 
 // handleParkingUpdate processes parking status updates
 func (manager *WebSocketManager) HandleReservationAdded(add models.Reservation) {
+	const msgType = "RESERVATION_ADD"
+	if manager.dedup.Seen(dedup.Fingerprint(msgType, add.ParkingSpaceID, add.ID, add.CreatedAt)) {
+		metrics.WSDuplicateEventsSuppressed.WithLabelValues(msgType).Inc()
+		return
+	}
+
+	broker.Current.Publish("RESERVATION_ADD", add)
+
 	select {
 	case manager.reservationAdded <- add:
 		log.Println("Sent reservation add")
@@ -243,23 +721,28 @@ func (manager *WebSocketManager) HandleReservationAdded(add models.Reservation)
 
 // handleParkingUpdate processes parking status updates
 func (manager *WebSocketManager) reservationAdd(update models.Reservation) {
-	message := WebSocketMessage{
-		Type:    "RESERVATION_ADD",
-		Payload: update,
-	}
+	const msgType = "RESERVATION_ADD"
 
-	data, err := json.Marshal(message)
-	if err != nil {
-		log.Printf("Error marshaling reservation add: %v", err)
+	data := manager.publish(UserTopic(update.UserID), msgType, update)
+	if data == nil {
 		return
 	}
 
-	// Broadcast to all clients
-	manager.broadcastToUser(update.UserID, data)
+	ctx, cancel := context.WithTimeout(context.Background(), broadcastDeadline)
+	defer cancel()
+	manager.broadcastToUser(ctx, update.UserID, data, msgType)
 }
 
 // handleParkingUpdate processes parking status updates
 func (manager *WebSocketManager) HandleReservationUpdated(update models.Reservation) {
+	const msgType = "RESERVATION_UPDATE"
+	if manager.dedup.Seen(dedup.Fingerprint(msgType, update.ParkingSpaceID, update.ID, update.UpdatedAt)) {
+		metrics.WSDuplicateEventsSuppressed.WithLabelValues(msgType).Inc()
+		return
+	}
+
+	broker.Current.Publish("RESERVATION_UPDATE", update)
+
 	select {
 	case manager.ReservationUpdates <- update:
 		log.Println("Sent reservation update")
@@ -270,23 +753,28 @@ func (manager *WebSocketManager) HandleReservationUpdated(update models.Reservat
 
 // handleParkingUpdate processes parking status updates
 func (manager *WebSocketManager) reservationUpdate(update models.Reservation) {
-	message := WebSocketMessage{
-		Type:    "RESERVATION_UPDATE",
-		Payload: update,
-	}
+	const msgType = "RESERVATION_UPDATE"
 
-	data, err := json.Marshal(message)
-	if err != nil {
-		log.Printf("Error marshaling reservation update: %v", err)
+	data := manager.publish(UserTopic(update.UserID), msgType, update)
+	if data == nil {
 		return
 	}
 
-	// Broadcast to all clients
-	manager.broadcastToUser(update.UserID, data)
+	ctx, cancel := context.WithTimeout(context.Background(), broadcastDeadline)
+	defer cancel()
+	manager.broadcastToUser(ctx, update.UserID, data, msgType)
 }
 
 // handleParkingUpdate processes parking status updates
 func (manager *WebSocketManager) HandleNotificationUpdate(update models.NotificationEvent) {
+	const msgType = "NOTIFICATION_UPDATE"
+	if manager.dedup.Seen(dedup.Fingerprint(msgType, update.ParkingSpaceId, update.ReservationId, update.CreatedAt)) {
+		metrics.WSDuplicateEventsSuppressed.WithLabelValues(msgType).Inc()
+		return
+	}
+
+	broker.Current.Publish("NOTIFICATION_UPDATE", update)
+
 	select {
 	case manager.notificationUpdates <- update:
 		log.Println("Sent notification update:")
@@ -297,44 +785,132 @@ func (manager *WebSocketManager) HandleNotificationUpdate(update models.Notifica
 
 // handleNotificationUpdate sends notifications to users
 func (manager *WebSocketManager) handleNotificationUpdate(notification models.NotificationEvent) {
-	message := WebSocketMessage{
-		Type:    "NOTIFICATION_UPDATE",
-		Payload: notification,
+	const msgType = "NOTIFICATION_UPDATE"
+
+	ctx, cancel := context.WithTimeout(context.Background(), broadcastDeadline)
+	defer cancel()
+
+	if (notification.Type == "time_limit" || notification.Type == "expired" || notification.Type == "spot_assigned" || notification.Type == "waitlist_promoted") && notification.UserID != "" {
+		if data := manager.publish(UserTopic(notification.UserID), msgType, notification); data != nil {
+			manager.broadcastToUser(ctx, notification.UserID, data, msgType)
+		}
+	} else {
+		spaceTopic := SpaceTopic(notification.ParkingSpaceId)
+		if data := manager.publish(spaceTopic, msgType, notification); data != nil {
+			manager.broadcastToTopic(ctx, spaceTopic, data, msgType)
+		}
 	}
+}
 
-	data, err := json.Marshal(message)
-	if err != nil {
-		log.Printf("Error marshaling notification: %v", err)
+// HandleReservationHistoryEvent broadcasts a reservation audit event to the
+// user who owns that reservation so the frontend timeline updates live
+func (manager *WebSocketManager) HandleReservationHistoryEvent(event models.ReservationHistoryEvent) {
+	const msgType = "RESERVATION_HISTORY"
+	if manager.dedup.Seen(dedup.Fingerprint(msgType, "", event.ReservationID, event.OccurredAt)) {
+		metrics.WSDuplicateEventsSuppressed.WithLabelValues(msgType).Inc()
 		return
 	}
 
-	if (notification.Type == "time_limit" || notification.Type == "expired") && notification.UserID != "" {
-		manager.broadcastToUser(notification.UserID, data)
-	} else {
-		manager.BroadcastToAll(data)
+	broker.Current.Publish("RESERVATION_HISTORY", event)
+	manager.reservationHistoryEvent(event)
+}
+
+// reservationHistoryEvent does the actual delivery for
+// HandleReservationHistoryEvent; split out so Dispatch can drive it
+// directly for events published by another replica, without publishing
+// them right back to broker.
+func (manager *WebSocketManager) reservationHistoryEvent(event models.ReservationHistoryEvent) {
+	const msgType = "RESERVATION_HISTORY"
+
+	data := manager.publish(UserTopic(event.ActorUserID), msgType, event)
+	if data == nil {
+		return
 	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), broadcastDeadline)
+	defer cancel()
+	manager.broadcastToUser(ctx, event.ActorUserID, data, msgType)
 }
 
 // handleGateEvent processes gate events
 func (manager *WebSocketManager) HandleGateEvent(event models.GateEvent) {
+	const msgType = "GATE_EVENT"
+	if manager.dedup.Seen(dedup.Fingerprint(msgType, event.ParkingSpaceID, event.ReservationID, event.Timestamp)) {
+		metrics.WSDuplicateEventsSuppressed.WithLabelValues(msgType).Inc()
+		return
+	}
+
+	broker.Current.Publish("GATE_EVENT", event)
 	manager.gateEvents <- event
 }
 
+// Dispatch drives a remote replica's event - decoded from a broker.Envelope
+// by kind - through this manager's ordinary local delivery path, without
+// re-publishing it to broker (the replica that originated it already
+// did). Wired up as the broker.Dispatcher passed to broker.Init in
+// main.go once utils.WsManager exists.
+func (manager *WebSocketManager) Dispatch(kind string, payload json.RawMessage) {
+	switch kind {
+	case "PARKING_UPDATE":
+		var event models.ParkingEvent
+		if err := json.Unmarshal(payload, &event); err != nil {
+			log.Printf("ws: dispatch: bad %s payload: %v", kind, err)
+			return
+		}
+		manager.parkingUpdate(event)
+	case "RESERVATION_ADD":
+		var event models.Reservation
+		if err := json.Unmarshal(payload, &event); err != nil {
+			log.Printf("ws: dispatch: bad %s payload: %v", kind, err)
+			return
+		}
+		manager.reservationAdd(event)
+	case "RESERVATION_UPDATE":
+		var event models.Reservation
+		if err := json.Unmarshal(payload, &event); err != nil {
+			log.Printf("ws: dispatch: bad %s payload: %v", kind, err)
+			return
+		}
+		manager.reservationUpdate(event)
+	case "NOTIFICATION_UPDATE":
+		var event models.NotificationEvent
+		if err := json.Unmarshal(payload, &event); err != nil {
+			log.Printf("ws: dispatch: bad %s payload: %v", kind, err)
+			return
+		}
+		manager.handleNotificationUpdate(event)
+	case "RESERVATION_HISTORY":
+		var event models.ReservationHistoryEvent
+		if err := json.Unmarshal(payload, &event); err != nil {
+			log.Printf("ws: dispatch: bad %s payload: %v", kind, err)
+			return
+		}
+		manager.reservationHistoryEvent(event)
+	case "GATE_EVENT":
+		var event models.GateEvent
+		if err := json.Unmarshal(payload, &event); err != nil {
+			log.Printf("ws: dispatch: bad %s payload: %v", kind, err)
+			return
+		}
+		manager.gateEvent(event)
+	default:
+		log.Printf("ws: dispatch: unknown kind %q", kind)
+	}
+}
+
 // handleGateEvent processes gate events
 func (manager *WebSocketManager) gateEvent(event models.GateEvent) {
-	message := WebSocketMessage{
-		Type:    "GATE_EVENT",
-		Payload: event,
-	}
+	const msgType = "GATE_EVENT"
 
-	data, err := json.Marshal(message)
-	if err != nil {
-		log.Printf("Error marshaling gate event: %v", err)
+	topic := GateTopic(event.ParkingLotID)
+	data := manager.publish(topic, msgType, event)
+	if data == nil {
 		return
 	}
 
-	// Broadcast to all clients
-	manager.BroadcastToAll(data)
+	ctx, cancel := context.WithTimeout(context.Background(), broadcastDeadline)
+	defer cancel()
+	manager.broadcastToTopic(ctx, topic, data, msgType)
 }
 
 // readPump pumps messages from the WebSocket connection to the manager
@@ -362,28 +938,63 @@ func (client *Client) readPump(manager *WebSocketManager) {
 
 		log.Printf("Received message from client %s: %s", client.ID, string(message))
 
+		if manager.frameLimiter != nil && !manager.frameLimiter.Allow("ws:"+client.ID) {
+			log.Printf("Client %s exceeded its inbound frame budget, closing", client.ID)
+			closeMsg := websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "rate limit exceeded")
+			client.Conn.WriteControl(websocket.CloseMessage, closeMsg, time.Now().Add(5*time.Second))
+			break
+		}
+
 		var wsMsg WebSocketMessage
 		if err := json.Unmarshal(message, &wsMsg); err != nil {
 			log.Printf("Error unmarshaling message: %v", err)
 			continue
 		}
 
-		log.Printf("Received message from client 2 %s: %s", client.ID, string(wsMsg.Type))
-
-		// switch wsMsg.Type {
-		// case "VEHICLE_UPDATE":
-		// 	var update models.VehicleEvent
-		// 	payloadBytes, _ := json.Marshal(wsMsg.Payload)
-		// 	if err := json.Unmarshal(payloadBytes, &update); err != nil {
-		// 		log.Printf("Error unmarshaling VEHICLE_UPDATE payload: %v", err)
-		// 		continue
-		// 	}
+		switch wsMsg.Type {
+		case "SUBSCRIBE", "UNSUBSCRIBE":
+			topic, ok := wsMsg.Payload.(string)
+			if !ok || topic == "" {
+				log.Printf("Invalid %s payload from client %s: %v", wsMsg.Type, client.ID, wsMsg.Payload)
+				continue
+			}
+			if wsMsg.Type == "SUBSCRIBE" {
+				manager.Subscribe(client, Topic(topic))
+			} else {
+				manager.Unsubscribe(client, Topic(topic))
+			}
+		case "RESUME":
+			var req struct {
+				Topic   string `json:"topic"`
+				LastSeq uint64 `json:"lastSeq"`
+			}
+			if err := mapToStruct(wsMsg.Payload, &req); err != nil || req.Topic == "" {
+				log.Printf("Invalid RESUME payload from client %s: %v", client.ID, wsMsg.Payload)
+				continue
+			}
 
-		// 	log.Printf("Received VEHICLE_UPDATE from client %s: %v", client.ID, update)
-		// 	manager.vehicleUpdates <- update
-		// default:
-		// 	log.Printf("Unknown message type: %s", wsMsg.Type)
-		// }
+			missed, ok := manager.Resume(Topic(req.Topic), req.LastSeq)
+			if !ok {
+				gap, err := json.Marshal(WebSocketMessage{Type: "RESUME_GAP", Topic: req.Topic})
+				if err != nil {
+					log.Printf("Error marshaling resume gap for client %s: %v", client.ID, err)
+					continue
+				}
+				select {
+				case client.Send <- gap:
+				default:
+				}
+				continue
+			}
+			for _, data := range missed {
+				select {
+				case client.Send <- data:
+				default:
+				}
+			}
+		default:
+			log.Printf("Unknown message type: %s", wsMsg.Type)
+		}
 	}
 
 }
@@ -406,17 +1017,27 @@ func (client *Client) writePump() {
 				return
 			}
 
+			// Drain whatever else is already queued so they go out as one
+			// frame, same as before - done first so the combined size is
+			// what decides whether this frame is worth deflating.
+			n := len(client.Send)
+			queued := make([][]byte, n)
+			size := len(message)
+			for i := 0; i < n; i++ {
+				queued[i] = <-client.Send
+				size += len(queued[i])
+			}
+
+			client.Conn.EnableWriteCompression(size >= client.compressionThreshold)
+
 			w, err := client.Conn.NextWriter(websocket.TextMessage)
 			if err != nil {
 				return
 			}
 			w.Write(message)
-
-			// Add queued messages to the current WebSocket message
-			n := len(client.Send)
-			for i := 0; i < n; i++ {
+			for _, q := range queued {
 				w.Write([]byte{'\n'})
-				w.Write(<-client.Send)
+				w.Write(q)
 			}
 
 			if err := w.Close(); err != nil {